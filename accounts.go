@@ -0,0 +1,296 @@
+package awsbase
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// defaultAssumeRolesParallelism is how many roles AssumeRoles assumes
+// concurrently by default.
+const defaultAssumeRolesParallelism = 10
+
+// AccountSession is a session of an account whose role was assumed by
+// AssumeRoles.
+type AccountSession struct {
+	// RoleARN is the ARN of the assumed role.
+	RoleARN string
+	// AccountID is the ID of the account of the role.
+	AccountID string
+	// Session is a copy of the base session using Credentials, or nil if the
+	// role could not be assumed.
+	Session *session.Session
+	// Credentials are the credentials of the assumed role.
+	Credentials *awsCredentials.Credentials
+	// Err is the error assuming the role, if any.
+	Err error
+}
+
+// AssumeRoles assumes each of the given roles, e.g. of all accounts of an
+// organization, with the credentials of the session built by GetSession from
+// the Config, which may itself assume a role, e.g. of a hub account. The
+// roles are assumed with the settings of the AssumeRole template, such as its
+// SessionName and ExternalID, whose RoleARN is ignored.
+//
+// At most parallelism roles are assumed concurrently, 10 by default. The
+// sessions are returned in the order of the role ARNs, with the errors of the
+// roles which could not be assumed, and share the HTTP client and handlers of
+// the base session. An error is only returned if the base session cannot be
+// built. Once the context is cancelled, the roles which weren't assumed yet
+// are returned with the error of the context.
+func AssumeRoles(ctx context.Context, c *Config, template AssumeRole, roleARNs []string, parallelism int) ([]AccountSession, error) {
+	c, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := resolveSession(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if parallelism <= 0 {
+		parallelism = defaultAssumeRolesParallelism
+	}
+
+	results := make([]AccountSession, len(roleARNs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism && i < len(roleARNs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				r := template
+				r.RoleARN = roleARNs[i]
+				results[i] = assumeAccountRole(ctx, sess, c, &r)
+			}
+		}()
+	}
+	next := 0
+feed:
+	for ; next < len(roleARNs); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// The roles which weren't handed to a worker once the context was
+	// cancelled aren't assumed.
+	for i := next; i < len(roleARNs); i++ {
+		r := template
+		r.RoleARN = roleARNs[i]
+		results[i] = assumeAccountRole(ctx, sess, c, &r)
+	}
+
+	return results, nil
+}
+
+// assumeAccountRole returns the session of the account of the role described
+// by the AssumeRole settings, assumed with the credentials of the session, or
+// the error of the context once it is cancelled.
+func assumeAccountRole(ctx context.Context, sess *session.Session, c *Config, r *AssumeRole) AccountSession {
+	result := AccountSession{RoleARN: r.RoleARN}
+	if roleARN, err := arn.Parse(r.RoleARN); err == nil {
+		result.AccountID = roleARN.AccountID
+	}
+
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Session, result.Err = assumeRoleSession(ctx, sess, c, r)
+	if result.Session != nil {
+		result.Credentials = result.Session.Config.Credentials
+	}
+	return result
+}
+
+// assumeRoleSession returns a copy of the session which uses the credentials
+// of the role described by the AssumeRole settings, assumed with the
+// credentials of the session, once the role was assumed.
+func assumeRoleSession(ctx context.Context, sess *session.Session, c *Config, r *AssumeRole) (*session.Session, error) {
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid assume role configuration: %w", err)
+	}
+
+	stsConfig, err := stsConfig(c, sess.Config.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	if r.Region != "" {
+		stsConfig.Region = aws.String(r.Region)
+	}
+	if r.StsEndpoint != "" {
+		stsConfig.Endpoint = aws.String(r.StsEndpoint)
+	}
+
+	logger.Printf("[DEBUG] Assuming role %s", r.RoleARN)
+	creds, chain := newAssumeRoleCredentials(sts.New(sess, stsConfig), r, &CredentialsAuditTrail{clock: c.Clock})
+	if err := chain.prime(ctx); err != nil {
+		return nil, fmt.Errorf("error assuming role %s: %w", r.RoleARN, err)
+	}
+
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+// RoleSessions builds sessions which assume roles with the credentials of the
+// session built by GetSession from a Config, and caches them by the role ARN
+// and the other AssumeRole settings, so that multi-account tools don't assume
+// the same role repeatedly. The credentials of the cached sessions are
+// refreshed as they expire. Clients of the sessions can be built with
+// NewClient and the Config.
+//
+// RoleSessions is safe for concurrent use.
+type RoleSessions struct {
+	config *Config
+
+	baseMu sync.Mutex
+	base   *baseSessionEntry
+
+	mu       sync.Mutex
+	sessions map[string]*roleSessionEntry
+}
+
+// baseSessionEntry is the base session being built, or built, by
+// RoleSessions, with the Config whose references to environment variables
+// were expanded.
+type baseSessionEntry struct {
+	done   chan struct{}
+	sess   *session.Session
+	config *Config
+	err    error
+}
+
+// roleSessionEntry is a session being built, or built, by RoleSessions.
+type roleSessionEntry struct {
+	done chan struct{}
+	sess *session.Session
+	err  error
+}
+
+// NewRoleSessions returns RoleSessions which assume roles with the
+// credentials of the Config. The base session is built by the first call of
+// Session.
+func NewRoleSessions(c *Config) *RoleSessions {
+	return &RoleSessions{
+		config:   c,
+		sessions: make(map[string]*roleSessionEntry),
+	}
+}
+
+// Session returns the session which assumes the role described by the
+// AssumeRole settings, assuming it unless a session with equal settings was
+// built already. Sessions which could not be built aren't cached, nor are
+// those with an MFATokenProvider, as functions cannot be compared.
+func (s *RoleSessions) Session(ctx context.Context, r *AssumeRole) (*session.Session, error) {
+	var key strings.Builder
+	if !writeCanonical(&key, reflect.ValueOf(r)) {
+		logger.Printf("[DEBUG] Assume role settings of %s contain functions, not caching session", r.RoleARN)
+		base, c, err := s.baseSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return assumeRoleSession(ctx, base, c, r)
+	}
+
+	s.mu.Lock()
+	entry, ok := s.sessions[key.String()]
+	if !ok {
+		entry = &roleSessionEntry{done: make(chan struct{})}
+		s.sessions[key.String()] = entry
+	}
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if entry.err == nil {
+			logger.Printf("[DEBUG] Using cached session of role %s", r.RoleARN)
+		}
+		return entry.sess, entry.err
+	}
+
+	base, c, err := s.baseSession(ctx)
+	if err == nil {
+		entry.sess, err = assumeRoleSession(ctx, base, c, r)
+	}
+	entry.err = err
+	if err != nil {
+		s.mu.Lock()
+		if s.sessions[key.String()] == entry {
+			delete(s.sessions, key.String())
+		}
+		s.mu.Unlock()
+	}
+	close(entry.done)
+
+	return entry.sess, entry.err
+}
+
+// Reset clears the cached sessions, including the base session, so that the
+// roles are assumed again, e.g. after their policies changed.
+func (s *RoleSessions) Reset() {
+	s.baseMu.Lock()
+	s.base = nil
+	s.baseMu.Unlock()
+
+	s.mu.Lock()
+	s.sessions = make(map[string]*roleSessionEntry)
+	s.mu.Unlock()
+}
+
+// baseSession returns the session built by GetSession from the Config, built
+// once it succeeded, and the Config with references to environment variables
+// expanded. Concurrent callers wait for the session being built, or the
+// cancellation of their context.
+func (s *RoleSessions) baseSession(ctx context.Context) (*session.Session, *Config, error) {
+	s.baseMu.Lock()
+	entry := s.base
+	building := entry == nil
+	if building {
+		entry = &baseSessionEntry{done: make(chan struct{})}
+		s.base = entry
+	}
+	s.baseMu.Unlock()
+
+	if !building {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		return entry.sess, entry.config, entry.err
+	}
+
+	entry.config, entry.err = ExpandConfigEnv(s.config)
+	if entry.err == nil {
+		entry.sess, entry.err = resolveSession(ctx, entry.config)
+	}
+	if entry.err != nil {
+		s.baseMu.Lock()
+		if s.base == entry {
+			s.base = nil
+		}
+		s.baseMu.Unlock()
+	}
+	close(entry.done)
+
+	return entry.sess, entry.config, entry.err
+}
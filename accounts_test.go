@@ -0,0 +1,398 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+// newAssumeRoleServer returns a mock STS server which assumes any role, except
+// those with "Denied" in their name, recording the role ARNs and the maximum
+// number of concurrent requests.
+func newAssumeRoleServer(t *testing.T) (*httptest.Server, func() ([]string, int)) {
+	var mu sync.Mutex
+	var roleARNs []string
+	var inFlight, maxInFlight int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Error parsing request: %s", err)
+		}
+		roleARN := r.PostForm.Get("RoleArn")
+
+		mu.Lock()
+		roleARNs = append(roleARNs, roleARN)
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/xml")
+		if strings.Contains(roleARN, "Denied") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, stsResponse_AssumeRole_accessDenied)
+			return
+		}
+		fmt.Fprint(w, awsmocks.MockStsAssumeRoleValidResponseBody)
+	}))
+
+	return ts, func() ([]string, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return roleARNs, maxInFlight
+	}
+}
+
+func TestAssumeRoles(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	ts, requests := newAssumeRoleServer(t)
+	defer ts.Close()
+
+	roleARNs := []string{
+		"arn:aws:iam::111111111111:role/Inventory",
+		"arn:aws:iam::222222222222:role/Denied",
+		"arn:aws:iam::333333333333:role/Inventory",
+		"arn:aws:iam::444444444444:role/Inventory",
+		"invalid",
+	}
+
+	results, err := AssumeRoles(context.Background(), &Config{
+		AccessKey:            "StaticAccessKey",
+		MaxRetries:           1,
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipCredsValidation:  true,
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	}, AssumeRole{SessionName: "inventory"}, roleARNs, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if len(results) != len(roleARNs) {
+		t.Fatalf("Expected %d results, got %d", len(roleARNs), len(results))
+	}
+	for i, result := range results {
+		if result.RoleARN != roleARNs[i] {
+			t.Errorf("Expected result %d for role %q, got %q", i, roleARNs[i], result.RoleARN)
+		}
+	}
+
+	for _, i := range []int{0, 2, 3} {
+		result := results[i]
+		if result.Err != nil {
+			t.Errorf("Expected no error assuming %s, received error: %s", result.RoleARN, result.Err)
+			continue
+		}
+		if expected := strings.Split(result.RoleARN, ":")[4]; result.AccountID != expected {
+			t.Errorf("Expected account ID %q, got %q", expected, result.AccountID)
+		}
+		value, err := result.Session.Config.Credentials.Get()
+		if err != nil {
+			t.Errorf("Expected no error, received error: %s", err)
+			continue
+		}
+		if value.AccessKeyID != awsmocks.MockStsAssumeRoleAccessKey {
+			t.Errorf("Expected access key %q, got %q", awsmocks.MockStsAssumeRoleAccessKey, value.AccessKeyID)
+		}
+		if result.Credentials != result.Session.Config.Credentials {
+			t.Error("Expected credentials of the session")
+		}
+	}
+
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "AccessDenied") {
+		t.Errorf("Expected access denied error, got %v", results[1].Err)
+	}
+	if results[1].Session != nil {
+		t.Error("Expected no session for denied role")
+	}
+	if results[4].Err == nil || !strings.Contains(results[4].Err.Error(), "invalid assume role configuration") {
+		t.Errorf("Expected invalid assume role configuration error, got %v", results[4].Err)
+	}
+
+	assumed, maxInFlight := requests()
+	if len(assumed) != 4 {
+		t.Errorf("Expected 4 sts:AssumeRole calls, got %d", len(assumed))
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent sts:AssumeRole calls, got %d", maxInFlight)
+	}
+}
+
+func TestAssumeRoles_invalidBaseSession(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	_, err := AssumeRoles(context.Background(), &Config{
+		AccessKey:             "StaticAccessKey",
+		CredentialSourceOrder: []CredentialSource{"vault"},
+		Region:                "us-east-1",
+		SecretKey:             "StaticSecretKey",
+		SkipCredsValidation:   true,
+		SkipMetadataApiCheck:  true,
+	}, AssumeRole{}, []string{"arn:aws:iam::111111111111:role/Inventory"}, 0)
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+}
+
+func TestAssumeRoles_cancelled(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	var requests int32
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client closing the connection once it
+		// has read the request.
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Error parsing request: %s", err)
+		}
+		if atomic.AddInt32(&requests, 1) > 1 {
+			t.Errorf("Unexpected sts:AssumeRole request for %s after the context was cancelled", r.PostForm.Get("RoleArn"))
+			return
+		}
+		close(started)
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	results, err := AssumeRoles(ctx, &Config{
+		AccessKey:            "StaticAccessKey",
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipCredsValidation:  true,
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	}, AssumeRole{SessionName: "inventory"}, []string{
+		"arn:aws:iam::111111111111:role/Inventory",
+		"arn:aws:iam::222222222222:role/Inventory",
+		"arn:aws:iam::333333333333:role/Inventory",
+	}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if results[0].Err == nil {
+		t.Error("Expected error, received none")
+	}
+	for _, result := range results[1:] {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Expected context error for %s, got %v", result.RoleARN, result.Err)
+		}
+		if expected := strings.Split(result.RoleARN, ":")[4]; result.AccountID != expected {
+			t.Errorf("Expected account ID %q, got %q", expected, result.AccountID)
+		}
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Error("Expected sts:AssumeRole request to be cancelled with the context")
+	}
+}
+
+func TestAssumeRoles_cancelledBaseSession(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{
+		awsmocks.MockStsGetCallerIdentityValidEndpoint,
+	}, nil)
+	defer servers.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := AssumeRoles(ctx, &Config{
+		AccessKey:            staticAccessKey,
+		Region:               "us-east-1",
+		SecretKey:            staticSecretKey,
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          servers.StsEndpoint(),
+	}, AssumeRole{SessionName: "inventory"}, []string{"arn:aws:iam::111111111111:role/Inventory"}, 0)
+	if err == nil {
+		t.Fatal("Expected error validating the base session with a cancelled context, received none")
+	}
+}
+
+func TestRoleSessions(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	ts, requests := newAssumeRoleServer(t)
+	defer ts.Close()
+
+	sessions := NewRoleSessions(&Config{
+		AccessKey:            "StaticAccessKey",
+		MaxRetries:           1,
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipCredsValidation:  true,
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	})
+	ctx := context.Background()
+
+	inventory := &AssumeRole{
+		RoleARN:     "arn:aws:iam::111111111111:role/Inventory",
+		SessionName: "inventory",
+	}
+	var wg sync.WaitGroup
+	results := make([]*session.Session, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := sessions.Session(ctx, &AssumeRole{
+				RoleARN:     inventory.RoleARN,
+				SessionName: inventory.SessionName,
+			})
+			if err != nil {
+				t.Errorf("Expected no error, received error: %s", err)
+			}
+			results[i] = sess
+		}(i)
+	}
+	wg.Wait()
+
+	for _, sess := range results[1:] {
+		if sess != results[0] {
+			t.Error("Expected cached session for equal settings")
+		}
+	}
+	if assumed, _ := requests(); len(assumed) != 1 {
+		t.Errorf("Expected 1 sts:AssumeRole call, got %d", len(assumed))
+	}
+
+	audit, err := sessions.Session(ctx, &AssumeRole{
+		RoleARN:     inventory.RoleARN,
+		SessionName: "audit",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if audit == results[0] {
+		t.Error("Expected separate session for other settings")
+	}
+
+	denied := &AssumeRole{RoleARN: "arn:aws:iam::222222222222:role/Denied"}
+	for i := 0; i < 2; i++ {
+		if _, err := sessions.Session(ctx, denied); err == nil {
+			t.Error("Expected error, received none")
+		}
+	}
+	if assumed, _ := requests(); len(assumed) != 4 {
+		t.Errorf("Expected failed sts:AssumeRole calls to be retried, got %d calls", len(assumed))
+	}
+
+	sessions.Reset()
+	sess, err := sessions.Session(ctx, inventory)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if sess == results[0] {
+		t.Error("Expected new session after reset")
+	}
+	if assumed, _ := requests(); len(assumed) != 5 {
+		t.Errorf("Expected 5 sts:AssumeRole calls, got %d", len(assumed))
+	}
+}
+
+func TestRoleSessions_cancelledBaseSession(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Error parsing request: %s", err)
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		if r.PostForm.Get("Action") != "GetCallerIdentity" {
+			fmt.Fprint(w, awsmocks.MockStsAssumeRoleValidResponseBody)
+			return
+		}
+
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+		fmt.Fprint(w, awsmocks.MockStsGetCallerIdentityValidResponseBody)
+	}))
+	defer ts.Close()
+
+	sessions := NewRoleSessions(&Config{
+		AccessKey:            staticAccessKey,
+		MaxRetries:           1,
+		Region:               "us-east-1",
+		SecretKey:            staticSecretKey,
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	})
+	inventory := &AssumeRole{
+		RoleARN:     "arn:aws:iam::111111111111:role/Inventory",
+		SessionName: "inventory",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := sessions.Session(ctx, inventory); err == nil {
+		t.Fatal("Expected error building the base session with a cancelled context, received none")
+	}
+
+	// Callers don't block each other while the base session is built.
+	built := make(chan error)
+	go func() {
+		_, err := sessions.Session(context.Background(), inventory)
+		built <- err
+	}()
+	<-started
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := sessions.Session(ctx, &AssumeRole{RoleARN: inventory.RoleARN}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context error while the base session is built, got %v", err)
+	}
+
+	close(release)
+	if err := <-built; err != nil {
+		t.Errorf("Expected no error, received error: %s", err)
+	}
+}
@@ -0,0 +1,176 @@
+package awsbase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+)
+
+// Names of the credential providers, as reported by
+// CredentialsAuditTrail.ProviderName and the AWS Go SDK credentials value.
+const (
+	AssumeRoleProviderName  = stscreds.ProviderName
+	EC2RoleProviderName     = ec2rolecreds.ProviderName
+	ECSProviderName         = endpointcreds.ProviderName
+	EnvProviderName         = awsCredentials.EnvProviderName
+	ProcessProviderName     = processcreds.ProviderName
+	SharedCredsProviderName = awsCredentials.SharedCredsProviderName
+	StaticProviderName      = awsCredentials.StaticProviderName
+	WebIdentityProviderName = stscreds.WebIdentityProviderName
+)
+
+// CredentialsAttempt records a single credential provider being consulted.
+type CredentialsAttempt struct {
+	// Provider is the type of credential provider, e.g. EnvProvider.
+	Provider string
+	// Description is a human-readable description of the credential source,
+	// e.g. shared credentials profile "prod".
+	Description string
+	// ProviderName is the provider name reported by the AWS Go SDK on success.
+	ProviderName string
+	// Err is the error returned by the provider, if any.
+	Err  error
+	Time time.Time
+}
+
+// CredentialsAuditTrail records which credential providers were consulted, in
+// order, and which ones supplied credentials. Providers are consulted lazily,
+// so the trail grows as credentials are retrieved and refreshed.
+type CredentialsAuditTrail struct {
+	mu       sync.Mutex
+	attempts []CredentialsAttempt
+	clock    Clock
+}
+
+// Attempts returns the recorded attempts in the order they were made.
+func (t *CredentialsAuditTrail) Attempts() []CredentialsAttempt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attempts := make([]CredentialsAttempt, len(t.attempts))
+	copy(attempts, t.attempts)
+	return attempts
+}
+
+// Sources returns the descriptions of the most recent credential sources that
+// supplied credentials, outermost last, e.g. the shared credentials profile
+// followed by the assumed role.
+func (t *CredentialsAuditTrail) Sources() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sources []string
+	seen := make(map[string]bool)
+	for i := len(t.attempts) - 1; i >= 0; i-- {
+		attempt := t.attempts[i]
+		if attempt.Err != nil || seen[attempt.Provider] {
+			continue
+		}
+		seen[attempt.Provider] = true
+		sources = append([]string{attempt.Description}, sources...)
+	}
+	return sources
+}
+
+// ProviderName returns the name of the credential provider which most recently
+// supplied credentials, e.g. AssumeRoleProviderName, or an empty string if no
+// credentials have been supplied.
+func (t *CredentialsAuditTrail) ProviderName() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := len(t.attempts) - 1; i >= 0; i-- {
+		if t.attempts[i].Err == nil {
+			return t.attempts[i].ProviderName
+		}
+	}
+	return ""
+}
+
+// String returns a summary suitable for display to end users, e.g.
+// authenticated via shared credentials profile "prod" → assumed role arn:aws:iam::123456789012:role/example
+func (t *CredentialsAuditTrail) String() string {
+	sources := t.Sources()
+	if len(sources) == 0 {
+		return "not authenticated"
+	}
+	return "authenticated via " + strings.Join(sources, " → ")
+}
+
+func (t *CredentialsAuditTrail) record(attempt CredentialsAttempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts = append(t.attempts, attempt)
+}
+
+// wrap returns a provider which records each retrieval in the audit trail.
+func (t *CredentialsAuditTrail) wrap(provider awsCredentials.Provider, description string) awsCredentials.Provider {
+	return &auditedProvider{
+		Provider:    provider,
+		description: description,
+		trail:       t,
+	}
+}
+
+type auditedProvider struct {
+	awsCredentials.Provider
+	description string
+	trail       *CredentialsAuditTrail
+}
+
+func (p *auditedProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+func (p *auditedProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	value, err := retrieveWithContext(ctx, p.Provider)
+	p.trail.record(CredentialsAttempt{
+		Provider:     providerType(p.Provider),
+		Description:  p.description,
+		ProviderName: value.ProviderName,
+		Err:          err,
+		Time:         resolveClock(p.trail.clock).Now(),
+	})
+	return value, err
+}
+
+// ExpiresAt returns the expiry time of the credentials of the provider, or the
+// zero time if it doesn't report one.
+func (p *auditedProvider) ExpiresAt() time.Time {
+	if expirer, ok := p.Provider.(awsCredentials.Expirer); ok {
+		return expirer.ExpiresAt()
+	}
+	return time.Time{}
+}
+
+func providerType(provider awsCredentials.Provider) string {
+	if wrapper, ok := provider.(interface {
+		unwrapProvider() awsCredentials.Provider
+	}); ok {
+		provider = wrapper.unwrapProvider()
+	}
+	name := fmt.Sprintf("%T", provider)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// sharedCredentialsProfile returns the shared credentials profile the AWS Go
+// SDK will use for the configured profile.
+func sharedCredentialsProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if profile = os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
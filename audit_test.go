@@ -0,0 +1,94 @@
+package awsbase
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestGetCredentialsWithAuditTrail(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Run("static credentials", func(t *testing.T) {
+		creds, trail, err := GetCredentialsWithAuditTrail(&Config{
+			AccessKey:            "accessKey",
+			SecretKey:            "secretKey",
+			SkipMetadataApiCheck: true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, received error: %s", err)
+		}
+		if _, err := creds.Get(); err != nil {
+			t.Fatalf("Expected no error getting credentials, received error: %s", err)
+		}
+
+		expected := "authenticated via static credentials"
+		if actual := trail.String(); actual != expected {
+			t.Fatalf("Expected %q, got %q", expected, actual)
+		}
+		if actual := trail.ProviderName(); actual != StaticProviderName {
+			t.Fatalf("Expected provider name %q, got %q", StaticProviderName, actual)
+		}
+	})
+
+	t.Run("shared credentials", func(t *testing.T) {
+		file, err := ioutil.TempFile(os.TempDir(), "terraform_aws_cred")
+		if err != nil {
+			t.Fatalf("Error writing temporary credentials file: %s", err)
+		}
+		_, err = file.WriteString(credentialsFileContents)
+		if err != nil {
+			t.Fatalf("Error writing temporary credentials to file: %s", err)
+		}
+		err = file.Close()
+		if err != nil {
+			t.Fatalf("Error closing temporary credentials file: %s", err)
+		}
+
+		defer os.Remove(file.Name())
+
+		creds, trail, err := GetCredentialsWithAuditTrail(&Config{
+			CredsFilename:        file.Name(),
+			Profile:              "myprofile",
+			SkipMetadataApiCheck: true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, received error: %s", err)
+		}
+		if _, err := creds.Get(); err != nil {
+			t.Fatalf("Expected no error getting credentials, received error: %s", err)
+		}
+
+		attempts := trail.Attempts()
+		if len(attempts) != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+		}
+		for i, provider := range []string{"StaticProvider", "EnvProvider", "SharedCredentialsProvider"} {
+			if attempts[i].Provider != provider {
+				t.Errorf("Expected attempt %d to be %s, got %s", i, provider, attempts[i].Provider)
+			}
+		}
+
+		expected := `authenticated via shared credentials profile "myprofile"`
+		if actual := trail.String(); actual != expected {
+			t.Fatalf("Expected %q, got %q", expected, actual)
+		}
+		if actual := trail.ProviderName(); actual != SharedCredsProviderName {
+			t.Fatalf("Expected provider name %q, got %q", SharedCredsProviderName, actual)
+		}
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		_, trail, err := GetCredentialsWithAuditTrail(&Config{
+			SkipMetadataApiCheck: true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, received error: %s", err)
+		}
+
+		if actual := trail.ProviderName(); actual != "" {
+			t.Fatalf("Expected no provider name, got %q", actual)
+		}
+	})
+}
@@ -1,10 +1,14 @@
 package awsbase
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,50 +19,85 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/hashicorp/go-cleanhttp"
-	"github.com/hashicorp/go-multierror"
 )
 
-func GetAccountIDAndPartition(iamconn *iam.IAM, stsconn *sts.STS, authProviderName string) (string, string, error) {
+const (
+	// defaultMetadataApiCheckAttempts is the default number of attempts to
+	// check the availability of the EC2 metadata API.
+	defaultMetadataApiCheckAttempts = 3
+	// metadataApiCheckBaseBackoff is the delay before the second availability
+	// check attempt, doubled for each subsequent attempt.
+	metadataApiCheckBaseBackoff = 100 * time.Millisecond
+
+	// metadataTimeoutEnvVar sets the timeout of EC2 metadata API requests as
+	// a duration, e.g. 500ms.
+	metadataTimeoutEnvVar = "AWS_METADATA_TIMEOUT"
+	// metadataServiceTimeoutEnvVar sets the timeout of EC2 metadata API
+	// requests in seconds, as in the AWS CLI and boto3.
+	metadataServiceTimeoutEnvVar = "AWS_METADATA_SERVICE_TIMEOUT"
+	// metadataServiceNumAttemptsEnvVar sets the number of attempts of EC2
+	// metadata API requests, as in the AWS CLI and boto3.
+	metadataServiceNumAttemptsEnvVar = "AWS_METADATA_SERVICE_NUM_ATTEMPTS"
+)
+
+// GetAccountIDAndPartition gets the account ID and partition for the
+// credentials, trying each available method in turn. The IAM and STS clients
+// are interfaces so that fakes can be used in testing. If all methods fail, the
+// error is Diagnostics describing the failure of each.
+func GetAccountIDAndPartition(iamconn iamiface.IAMAPI, stsconn stsiface.STSAPI, authProviderName string) (string, string, error) {
 	var accountID, partition string
-	var err, errors error
+	var err error
+	var diags Diagnostics
 
 	if authProviderName == ec2rolecreds.ProviderName {
 		accountID, partition, err = GetAccountIDAndPartitionFromEC2Metadata()
+		diags = diags.Append(DiagnosticSeverityError, "EC2 metadata", err, "check that the EC2 metadata API is reachable and an instance profile is attached")
 	} else {
 		accountID, partition, err = GetAccountIDAndPartitionFromIAMGetUser(iamconn)
+		diags = diags.Append(DiagnosticSeverityError, "iam:GetUser", err, "allow iam:GetUser for the credentials")
 	}
 	if accountID != "" {
 		return accountID, partition, nil
 	}
-	errors = multierror.Append(errors, err)
 
 	accountID, partition, err = GetAccountIDAndPartitionFromSTSGetCallerIdentity(stsconn)
 	if accountID != "" {
 		return accountID, partition, nil
 	}
-	errors = multierror.Append(errors, err)
+	diags = diags.Append(DiagnosticSeverityError, "sts:GetCallerIdentity", err, "check that the STS endpoint is reachable and the credentials are valid")
 
 	accountID, partition, err = GetAccountIDAndPartitionFromIAMListRoles(iamconn)
 	if accountID != "" {
 		return accountID, partition, nil
 	}
-	errors = multierror.Append(errors, err)
+	diags = diags.Append(DiagnosticSeverityError, "iam:ListRoles", err, "allow iam:ListRoles for the credentials")
 
-	return accountID, partition, errors
+	return accountID, partition, diags.ErrorOrNil()
 }
 
 func GetAccountIDAndPartitionFromEC2Metadata() (string, string, error) {
-	log.Println("[DEBUG] Trying to get account information via EC2 Metadata")
+	logger.Println("[DEBUG] Trying to get account information via EC2 Metadata")
 
 	cfg := &aws.Config{}
 	setOptionalEndpoint(cfg)
+	if timeout, ok := metadataApiTimeout(); ok {
+		cfg.HTTPClient = &http.Client{Timeout: timeout}
+	}
+	if attempts, ok := metadataApiAttempts(); ok {
+		cfg.MaxRetries = aws.Int(attempts - 1)
+	}
 	sess, err := session.NewSession(cfg)
 	if err != nil {
-		return "", "", fmt.Errorf("error creating EC2 Metadata session: %s", err)
+		return "", "", fmt.Errorf("error creating EC2 Metadata session: %w", err)
 	}
 
 	metadataClient := ec2metadata.New(sess)
@@ -67,83 +106,172 @@ func GetAccountIDAndPartitionFromEC2Metadata() (string, string, error) {
 		// We can end up here if there's an issue with the instance metadata service
 		// or if we're getting credentials from AdRoll's Hologram (in which case IAMInfo will
 		// error out).
-		err = fmt.Errorf("failed getting account information via EC2 Metadata IAM information: %s", err)
-		log.Printf("[DEBUG] %s", err)
+		err = fmt.Errorf("failed getting account information via EC2 Metadata IAM information: %w", err)
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	return parseAccountIDAndPartitionFromARN(info.InstanceProfileArn)
 }
 
-func GetAccountIDAndPartitionFromIAMGetUser(iamconn *iam.IAM) (string, string, error) {
-	log.Println("[DEBUG] Trying to get account information via iam:GetUser")
+func GetAccountIDAndPartitionFromIAMGetUser(iamconn iamiface.IAMAPI) (string, string, error) {
+	logger.Println("[DEBUG] Trying to get account information via iam:GetUser")
 
-	output, err := iamconn.GetUser(&iam.GetUserInput{})
+	var output *iam.GetUserOutput
+	err := retryOnThrottle(aws.BackgroundContext(), nil, iamconn, "iam:GetUser", func() (err error) {
+		output, err = iamconn.GetUser(&iam.GetUserInput{})
+		return err
+	})
 	if err != nil {
 		// AccessDenied and ValidationError can be raised
 		// if credentials belong to federated profile, so we ignore these
-		if awsErr, ok := err.(awserr.Error); ok {
-			switch awsErr.Code() {
-			case "AccessDenied", "InvalidClientTokenId", "ValidationError":
-				return "", "", nil
-			}
+		if ErrCodeEquals(err, "AccessDenied", "InvalidClientTokenId", "ValidationError") {
+			return "", "", nil
 		}
-		err = fmt.Errorf("failed getting account information via iam:GetUser: %s", err)
-		log.Printf("[DEBUG] %s", err)
+		err = wrapRequestError(err, "failed getting account information via iam:GetUser")
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	if output == nil || output.User == nil {
 		err = errors.New("empty iam:GetUser response")
-		log.Printf("[DEBUG] %s", err)
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	return parseAccountIDAndPartitionFromARN(aws.StringValue(output.User.Arn))
 }
 
-func GetAccountIDAndPartitionFromIAMListRoles(iamconn *iam.IAM) (string, string, error) {
-	log.Println("[DEBUG] Trying to get account information via iam:ListRoles")
+func GetAccountIDAndPartitionFromIAMListRoles(iamconn iamiface.IAMAPI) (string, string, error) {
+	logger.Println("[DEBUG] Trying to get account information via iam:ListRoles")
 
-	output, err := iamconn.ListRoles(&iam.ListRolesInput{
-		MaxItems: aws.Int64(int64(1)),
+	var output *iam.ListRolesOutput
+	err := retryOnThrottle(aws.BackgroundContext(), nil, iamconn, "iam:ListRoles", func() (err error) {
+		output, err = iamconn.ListRoles(&iam.ListRolesInput{
+			MaxItems: aws.Int64(int64(1)),
+		})
+		return err
 	})
 	if err != nil {
-		err = fmt.Errorf("failed getting account information via iam:ListRoles: %s", err)
-		log.Printf("[DEBUG] %s", err)
+		err = wrapRequestError(err, "failed getting account information via iam:ListRoles")
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	if output == nil || len(output.Roles) < 1 {
 		err = fmt.Errorf("empty iam:ListRoles response")
-		log.Printf("[DEBUG] %s", err)
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	return parseAccountIDAndPartitionFromARN(aws.StringValue(output.Roles[0].Arn))
 }
 
-func GetAccountIDAndPartitionFromSTSGetCallerIdentity(stsconn *sts.STS) (string, string, error) {
-	log.Println("[DEBUG] Trying to get account information via sts:GetCallerIdentity")
+// GetAccountAlias gets the alias of the account of the credentials via
+// iam:ListAccountAliases, returning an empty string if the account has no
+// alias.
+func GetAccountAlias(iamconn iamiface.IAMAPI) (string, error) {
+	logger.Println("[DEBUG] Trying to get account alias via iam:ListAccountAliases")
+
+	var output *iam.ListAccountAliasesOutput
+	err := retryOnThrottle(aws.BackgroundContext(), nil, iamconn, "iam:ListAccountAliases", func() (err error) {
+		output, err = iamconn.ListAccountAliases(&iam.ListAccountAliasesInput{})
+		return err
+	})
+	if err != nil {
+		err = wrapRequestError(err, "failed getting account alias via iam:ListAccountAliases")
+		logger.Printf("[DEBUG] %s", err)
+		return "", err
+	}
+
+	if output == nil || len(output.AccountAliases) < 1 {
+		return "", nil
+	}
+
+	return aws.StringValue(output.AccountAliases[0]), nil
+}
+
+// GetCanonicalUserID gets the canonical user ID of the account of the
+// credentials, e.g. for S3 bucket ACL grants, via the owner of the
+// s3:ListBuckets response. The S3 client can be built with NewS3Client.
+func GetCanonicalUserID(s3conn s3iface.S3API) (string, error) {
+	logger.Println("[DEBUG] Trying to get canonical user ID via s3:ListBuckets")
 
-	output, err := stsconn.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	var output *s3.ListBucketsOutput
+	err := retryOnThrottle(aws.BackgroundContext(), nil, s3conn, "s3:ListBuckets", func() (err error) {
+		output, err = s3conn.ListBuckets(&s3.ListBucketsInput{})
+		return err
+	})
 	if err != nil {
-		return "", "", fmt.Errorf("error calling sts:GetCallerIdentity: %s", err)
+		err = wrapRequestError(err, "failed getting canonical user ID via s3:ListBuckets")
+		logger.Printf("[DEBUG] %s", err)
+		return "", err
+	}
+
+	if output == nil || output.Owner == nil || aws.StringValue(output.Owner.ID) == "" {
+		err = errors.New("empty s3:ListBuckets owner")
+		logger.Printf("[DEBUG] %s", err)
+		return "", err
+	}
+
+	return aws.StringValue(output.Owner.ID), nil
+}
+
+func GetAccountIDAndPartitionFromSTSGetCallerIdentity(stsconn stsiface.STSAPI) (string, string, error) {
+	return accountIDAndPartitionFromSTSGetCallerIdentity(aws.BackgroundContext(), nil, stsconn, func() (*sts.GetCallerIdentityOutput, error) {
+		return stsconn.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	})
+}
+
+// accountIDAndPartitionFromSTSGetCallerIdentity is
+// GetAccountIDAndPartitionFromSTSGetCallerIdentity with the sts:GetCallerIdentity
+// call of the client, e.g. made with the context.
+func accountIDAndPartitionFromSTSGetCallerIdentity(ctx context.Context, clock Clock, stsconn stsiface.STSAPI, getCallerIdentity func() (*sts.GetCallerIdentityOutput, error)) (string, string, error) {
+	logger.Println("[DEBUG] Trying to get account information via sts:GetCallerIdentity")
+
+	var output *sts.GetCallerIdentityOutput
+	err := retryOnThrottle(ctx, clock, stsconn, "sts:GetCallerIdentity", func() (err error) {
+		output, err = getCallerIdentity()
+		return err
+	})
+	if err != nil {
+		return "", "", wrapRequestError(err, "error calling sts:GetCallerIdentity")
 	}
 
 	if output == nil || output.Arn == nil {
 		err = errors.New("empty sts:GetCallerIdentity response")
-		log.Printf("[DEBUG] %s", err)
+		logger.Printf("[DEBUG] %s", err)
 		return "", "", err
 	}
 
 	return parseAccountIDAndPartitionFromARN(aws.StringValue(output.Arn))
 }
 
+// partitionForRegion returns the partition ID for the region. Regions not yet
+// known to the AWS Go SDK are matched by their partition's naming pattern,
+// e.g. cn-* regions belong to the aws-cn partition.
+func partitionForRegion(region string) string {
+	if region == "" {
+		return ""
+	}
+
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return p.ID()
+	}
+
+	// The STS endpoint is resolved for every region matching a partition's
+	// region pattern.
+	if endpoint, err := endpoints.DefaultResolver().EndpointFor(sts.EndpointsID, region); err == nil {
+		return endpoint.PartitionID
+	}
+
+	return ""
+}
+
 func parseAccountIDAndPartitionFromARN(inputARN string) (string, string, error) {
 	arn, err := arn.Parse(inputARN)
 	if err != nil {
-		return "", "", fmt.Errorf("error parsing ARN (%s): %s", inputARN, err)
+		return "", "", fmt.Errorf("error parsing ARN (%s): %w", inputARN, err)
 	}
 	return arn.AccountID, arn.Partition, nil
 }
@@ -151,156 +279,747 @@ func parseAccountIDAndPartitionFromARN(inputARN string) (string, string, error)
 // This function is responsible for reading credentials from the
 // environment in the case that they're not explicitly specified
 // in the Terraform configuration.
+//
+// With StrictCredentialSources set, an error is returned if more than one
+// credential source is configured or has credentials, which requires
+// retrieving credentials from every source, including the EC2 metadata API.
+//
+// The format of static credentials is checked with CheckStaticCredentials
+// before any other source is consulted.
 func GetCredentials(c *Config) (*awsCredentials.Credentials, error) {
-	// build a chain provider, lazy-evaluated by aws-sdk
-	providers := []awsCredentials.Provider{
-		&awsCredentials.StaticProvider{Value: awsCredentials.Value{
-			AccessKeyID:     c.AccessKey,
-			SecretAccessKey: c.SecretKey,
-			SessionToken:    c.Token,
-		}},
-		&awsCredentials.EnvProvider{},
-		&awsCredentials.SharedCredentialsProvider{
-			Filename: c.CredsFilename,
-			Profile:  c.Profile,
-		},
-	}
-
-	// Build isolated HTTP client to avoid issues with globally-shared settings
-	client := cleanhttp.DefaultClient()
+	return GetCredentialsWithContext(context.Background(), c)
+}
 
-	// Keep the default timeout (100ms) low as we don't want to wait in non-EC2 environments
-	client.Timeout = 100 * time.Millisecond
+// GetCredentialsWithContext is GetCredentials with a context for the requests
+// made to check the credentials, such as to the EC2 metadata API and STS.
+// When tracing is configured, the GetCredentials span is a child of the span
+// of the context.
+func GetCredentialsWithContext(ctx context.Context, c *Config) (*awsCredentials.Credentials, error) {
+	expanded, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, err
+	}
 
-	const userTimeoutEnvVar = "AWS_METADATA_TIMEOUT"
-	userTimeout := os.Getenv(userTimeoutEnvVar)
-	if userTimeout != "" {
-		newTimeout, err := time.ParseDuration(userTimeout)
-		if err == nil {
-			if newTimeout.Nanoseconds() > 0 {
-				client.Timeout = newTimeout
-			} else {
-				log.Printf("[WARN] Non-positive value of %s (%s) is meaningless, ignoring", userTimeoutEnvVar, newTimeout.String())
-			}
-		} else {
-			log.Printf("[WARN] Error converting %s to time.Duration: %s", userTimeoutEnvVar, err)
+	creds, _, err := getCredentialsWithAuditTrail(ctx, expanded)
+	return creds, err
+}
+
+// GetCredentialsWithAuditTrail returns the same credentials as GetCredentials
+// along with an audit trail recording which credential providers were consulted
+// and which supplied the credentials.
+//
+// When MemoizeCredentials is set, the credentials and audit trail are cached
+// for the lifetime of the process and returned for subsequent calls with an
+// equal Config and AWS_ environment variables, so that the credential chain
+// is resolved and refreshed once. Configs containing functions, e.g.
+// CredentialsProviderFunc, are not memoized.
+//
+// The audit trail is returned for errors, too, and is empty if the Config
+// could not be resolved, e.g. for references to unset environment variables.
+func GetCredentialsWithAuditTrail(c *Config) (*awsCredentials.Credentials, *CredentialsAuditTrail, error) {
+	expanded, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, &CredentialsAuditTrail{clock: c.Clock}, err
+	}
+
+	return getCredentialsWithAuditTrail(context.Background(), expanded)
+}
+
+// getCredentialsWithAuditTrail is GetCredentialsWithAuditTrail for a Config
+// whose references to environment variables were expanded.
+func getCredentialsWithAuditTrail(ctx context.Context, c *Config) (*awsCredentials.Credentials, *CredentialsAuditTrail, error) {
+	var cacheKey string
+	if c.MemoizeCredentials {
+		var ok bool
+		if cacheKey, ok = configHash(c); !ok {
+			logger.Print("[DEBUG] Config contains functions, not memoizing credentials")
+		} else if entry, ok := cachedCredentials(cacheKey); ok {
+			logger.Print("[DEBUG] Using memoized credentials")
+			return entry.creds, entry.trail, nil
 		}
 	}
 
-	log.Printf("[INFO] Setting AWS metadata API timeout to %s", client.Timeout.String())
+	ctx, span := startSpan(ctx, c, "GetCredentials")
+	trail := &CredentialsAuditTrail{clock: c.Clock}
+	creds, err := getCredentials(ctx, c, trail)
+	endSpan(span, err)
+
+	if err == nil && cacheKey != "" {
+		cacheCredentials(cacheKey, creds, trail)
+	}
+	return creds, trail, err
+}
+
+func getCredentials(ctx context.Context, c *Config, trail *CredentialsAuditTrail) (*awsCredentials.Credentials, error) {
+	if err := c.CheckStaticCredentials(); err != nil {
+		return nil, err
+	}
+
+	order, err := credentialSourceOrder(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCredentialSourceConflicts(c, order); err != nil {
+		return nil, err
+	}
+
+	staticProvider := &awsCredentials.StaticProvider{Value: awsCredentials.Value{
+		AccessKeyID:     c.AccessKey,
+		SecretAccessKey: c.SecretKey,
+		SessionToken:    c.Token,
+	}}
+	envProvider := &awsCredentials.EnvProvider{}
+	sharedCredentialsProvider := newSharedCredentialsProvider(c)
+
+	// build a chain provider, lazy-evaluated by aws-sdk, of the providers of
+	// the credential sources in their order
+	providers := map[CredentialSource]awsCredentials.Provider{
+		CredentialSourceStatic: trail.wrap(staticProvider, "static credentials"),
+	}
+
+	localProviders := map[CredentialSource]awsCredentials.Provider{
+		CredentialSourceStatic: staticProvider,
+	}
+
+	if c.CredentialsProviderFunc != nil {
+		funcProvider := &FuncCredentialsProvider{
+			Func:  c.CredentialsProviderFunc,
+			Clock: c.Clock,
+		}
+		providers[CredentialSourceFunction] = trail.wrap(funcProvider, "credentials function")
+	}
+
+	if c.WatchedCredsFilename != "" {
+		fileProvider := &FileCredentialsProvider{Filename: c.WatchedCredsFilename}
+		providers[CredentialSourceWatchedFile] = trail.wrap(fileProvider, fmt.Sprintf("credentials file %q", c.WatchedCredsFilename))
+		localProviders[CredentialSourceWatchedFile] = fileProvider
+	}
+
+	if c.KeychainService != "" {
+		keychainProvider := &KeychainCredentialsProvider{
+			Service: c.KeychainService,
+			User:    c.KeychainUser,
+			Clock:   c.Clock,
+		}
+		providers[CredentialSourceKeychain] = trail.wrap(keychainProvider, fmt.Sprintf("OS credential store service %q", c.KeychainService))
+	}
+
+	if c.CredentialProcess != nil {
+		processProvider := &credentialProcessProvider{
+			process: c.CredentialProcess,
+			clock:   c.Clock,
+		}
+		providers[CredentialSourceProcess] = trail.wrap(processProvider, fmt.Sprintf("credential process %q", c.CredentialProcess.Command))
+	}
+
+	if c.IgnoreEnvCredentials {
+		logger.Print("[DEBUG] Ignoring credentials of environment variables")
+	} else {
+		providers[CredentialSourceEnvironment] = trail.wrap(envProvider, "environment variables")
+		localProviders[CredentialSourceEnvironment] = envProvider
+	}
+
+	if c.IgnoreSharedCredentialsFile {
+		logger.Print("[DEBUG] Ignoring credentials of shared credentials files")
+	} else {
+		providers[CredentialSourceSharedCredentials] = trail.wrap(sharedCredentialsProvider, fmt.Sprintf("shared credentials profile %q", sharedCredentialsProfile(c.Profile)))
+		localProviders[CredentialSourceSharedCredentials] = sharedCredentialsProvider
+	}
+
+	// Build isolated HTTP transport to avoid issues with globally-shared settings.
+	// The transport is shared by all internal AWS API calls so that connections
+	// are reused.
+	transport := cleanhttp.DefaultPooledTransport()
+	if err := configureProxy(c, transport); err != nil {
+		return nil, err
+	}
+	if err := configureDefaultsMode(c, transport); err != nil {
+		return nil, err
+	}
+
+	stsRegionalEndpoint, _, err := defaultsModeEndpoints(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build a single internal session for the EC2 metadata and STS clients.
+	internalSession, err := session.NewSession(&aws.Config{
+		EndpointResolver:    c.EndpointResolver,
+		HTTPClient:          &http.Client{Transport: transport},
+		STSRegionalEndpoint: stsRegionalEndpoint,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating internal AWS session: %w", err)
+	}
+	addHandlers(c, &internalSession.Handlers)
+
+	if c.RolesAnywhere != nil {
+		if err := c.RolesAnywhere.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid IAM Roles Anywhere configuration: %w", err)
+		}
+		rolesAnywhereProvider := &rolesAnywhereProvider{
+			settings: c.RolesAnywhere,
+			client:   internalSession.Config.HTTPClient,
+			clock:    c.Clock,
+		}
+		providers[CredentialSourceRolesAnywhere] = trail.wrap(rolesAnywhereProvider, fmt.Sprintf("IAM Roles Anywhere role %s", c.RolesAnywhere.RoleARN))
+	}
+
+	// Keep the default timeout (100ms) low as we don't want to wait in non-EC2 environments
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   100 * time.Millisecond,
+	}
+
+	if timeout, ok := metadataApiTimeout(); ok {
+		client.Timeout = timeout
+	}
+
+	logger.Printf("[INFO] Setting AWS metadata API timeout to %s", client.Timeout.String())
 	cfg := &aws.Config{
 		HTTPClient: client,
 	}
 	usedEndpoint := setOptionalEndpoint(cfg)
 
+	// Add the web identity provider, e.g. for Kubernetes IAM roles for service
+	// accounts, if the relevant env variables are set
+	webIdentityTokenFile := os.Getenv(webIdentityTokenFileEnvVar)
+	webIdentityRoleARN := os.Getenv(AssumeRoleARNEnvVar)
+	webIdentityConfigured := webIdentityTokenFile != "" && webIdentityRoleARN != ""
+	if webIdentityConfigured {
+		stsConfig, err := stsConfig(c, internalSession.Config.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		stsConfig.Region = aws.String(c.Region)
+
+		webIdentityProvider := stscreds.NewWebIdentityRoleProviderWithOptions(
+			sts.New(internalSession, stsConfig),
+			webIdentityRoleARN,
+			os.Getenv(AssumeRoleSessionNameEnvVar),
+			stscreds.FetchTokenPath(webIdentityTokenFile),
+		)
+		webIdentityProvider.Expiry.CurrentTime = resolveClock(c.Clock).Now
+		providers[CredentialSourceWebIdentity] = trail.wrap(webIdentityProvider, fmt.Sprintf("web identity role %s", webIdentityRoleARN))
+		logger.Print("[INFO] Web identity token file detected, WebIdentityRoleProvider added to auth chain")
+	}
+
 	// Add the default AWS provider for ECS Task Roles if the relevant env variable is set
 	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); len(uri) > 0 {
-		providers = append(providers, defaults.RemoteCredProvider(*cfg, defaults.Handlers()))
-		log.Print("[INFO] ECS container credentials detected, RemoteCredProvider added to auth chain")
+		providers[CredentialSourceContainer] = trail.wrap(defaults.RemoteCredProvider(*cfg, defaults.Handlers()), "ECS container credentials")
+		logger.Print("[INFO] ECS container credentials detected, RemoteCredProvider added to auth chain")
 	}
 
 	if !c.SkipMetadataApiCheck {
 		// Real AWS should reply to a simple metadata request.
 		// We check it actually does to ensure something else didn't just
 		// happen to be listening on the same IP:Port
-		ec2Session, err := session.NewSession(cfg)
+		metadataClientConfig := &aws.Config{}
+		if attempts, ok := metadataApiAttempts(); ok {
+			metadataClientConfig.MaxRetries = aws.Int(attempts - 1)
+		}
+		metadataClient := ec2metadata.New(internalSession, cfg, metadataClientConfig)
 
-		if err != nil {
-			return nil, fmt.Errorf("error creating EC2 Metadata session: %s", err)
+		// Probe the metadata API concurrently with the local credential sources
+		// which take precedence over it in the chain, so that the probe timeout
+		// is only paid when no local credentials are found.
+		probeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		attempts := c.MetadataApiCheckAttempts
+		if attempts <= 0 {
+			attempts = defaultMetadataApiCheckAttempts
+			if envAttempts, ok := metadataApiAttempts(); ok {
+				attempts = envAttempts
+			}
 		}
+		probeClient := ec2metadata.New(internalSession, cfg, &aws.Config{MaxRetries: aws.Int(0)})
+		checkTimeout := metadataApiCheckTimeout(client.Timeout, attempts)
 
-		metadataClient := ec2metadata.New(ec2Session)
-		if metadataClient.Available() {
-			providers = append(providers, &ec2rolecreds.EC2RoleProvider{
+		metadataAvailable := make(chan bool, 1)
+		if available, ok := cachedMetadataApiAvailable(usedEndpoint); ok {
+			logger.Printf("[DEBUG] Using cached AWS metadata API check result: %t", available)
+			metadataAvailable <- available
+		} else {
+			go func() {
+				metadataAvailable <- sharedMetadataApiAvailable(probeCtx, usedEndpoint, func(ctx context.Context) bool {
+					// Attempts may make several requests, e.g. for a session
+					// token, so the check as a whole is bounded, too.
+					ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+					defer cancel()
+
+					return metadataApiAvailable(ctx, probeClient, attempts)
+				})
+			}()
+		}
+
+		// In strict mode, the metadata API is checked for credentials, too.
+		if !c.StrictCredentialSources && localCredentialsAvailable(orderedProviders(order, localProviders, CredentialSourceEC2InstanceProfile)...) {
+			cancel()
+			logger.Print("[INFO] Local credentials found, skipping AWS metadata API check")
+		} else if !c.StrictCredentialSources && webIdentityConfigured && credentialSourcePrecedes(order, CredentialSourceWebIdentity, CredentialSourceEC2InstanceProfile) {
+			cancel()
+			logger.Print("[INFO] Web identity credentials configured, skipping AWS metadata API check")
+		} else if <-metadataAvailable {
+			ec2RoleProvider := &ec2rolecreds.EC2RoleProvider{
 				Client: metadataClient,
-			})
-			log.Print("[INFO] AWS EC2 instance detected via default metadata" +
+			}
+			ec2RoleProvider.Expiry.CurrentTime = resolveClock(c.Clock).Now
+			providers[CredentialSourceEC2InstanceProfile] = trail.wrap(&ec2RoleTokenRetryProvider{ec2RoleProvider}, "EC2 instance profile")
+			logger.Print("[INFO] AWS EC2 instance detected via default metadata" +
 				" API endpoint, EC2RoleProvider added to the auth chain")
 		} else {
 			if usedEndpoint == "" {
 				usedEndpoint = "default location"
 			}
-			log.Printf("[INFO] Ignoring AWS metadata API endpoint at %s "+
+			logger.With(logFields{"endpoint": usedEndpoint}).Printf("[INFO] Ignoring AWS metadata API endpoint at %s "+
 				"as it doesn't return any instance-id", usedEndpoint)
 		}
 	}
 
+	if c.StrictCredentialSources {
+		if err := checkSingleCredentialSource(orderedProviders(order, providers, "")); err != nil {
+			return nil, err
+		}
+	}
+
 	// This is the "normal" flow (i.e. not assuming a role)
-	if c.AssumeRoleARN == "" {
-		return awsCredentials.NewChainCredentials(providers), nil
+	assumeRole := ResolveAssumeRole(c)
+	if c.FederationToken != nil {
+		if assumeRole != nil {
+			return nil, errors.New("federation token cannot be configured with assume role")
+		}
+		return getFederationTokenCredentials(ctx, c, internalSession, orderedProviders(order, providers, ""), trail)
+	}
+	if assumeRole == nil {
+		creds, chain := newChainCredentials(orderedProviders(order, providers, ""), false, c.Clock)
+		c.CredentialsRefresher.start(creds, chain, c.Clock)
+		return creds, nil
+	}
+
+	if err := assumeRole.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid assume role configuration: %w", err)
 	}
 
 	// Otherwise we need to construct and STS client with the main credentials, and verify
 	// that we can assume the defined role.
-	log.Printf("[INFO] Attempting to AssumeRole %s (SessionName: %q, ExternalId: %q, Policy: %q)",
-		c.AssumeRoleARN, c.AssumeRoleSessionName, c.AssumeRoleExternalID, c.AssumeRolePolicy)
+	logger.Printf("[INFO] Attempting to AssumeRole %s (SessionName: %q, ExternalId: %q, Policy: %q)",
+		assumeRole.RoleARN, assumeRole.SessionName, assumeRole.ExternalID, assumeRole.Policy)
 
-	creds := awsCredentials.NewChainCredentials(providers)
-	cp, err := creds.Get()
+	creds, _ := newChainCredentials(orderedProviders(order, providers, ""), false, c.Clock)
+	cp, err := creds.GetWithContext(ctx)
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoCredentialProviders" {
+		if ErrCodeEquals(err, "NoCredentialProviders") {
 			return nil, errors.New(`No valid credential sources found for AWS Provider.
   Please see https://terraform.io/docs/providers/aws/index.html for more information on
   providing credentials for the AWS Provider`)
 		}
 
-		return nil, fmt.Errorf("Error loading credentials for AWS Provider: %s", err)
-	}
-
-	log.Printf("[INFO] AWS Auth provider used: %q", cp.ProviderName)
-
-	awsConfig := &aws.Config{
-		Credentials: creds,
-		Region:      aws.String(c.Region),
-		MaxRetries:  aws.Int(c.MaxRetries),
-		HTTPClient:  cleanhttp.DefaultClient(),
+		return nil, fmt.Errorf("Error loading credentials for AWS Provider: %w", err)
 	}
 
-	assumeRoleSession, err := session.NewSession(awsConfig)
+	logger.With(logFields{"provider": cp.ProviderName}).Printf("[INFO] AWS Auth provider used: %q", cp.ProviderName)
 
+	stsConfig, err := stsConfig(c, internalSession.Config.HTTPClient)
 	if err != nil {
-		return nil, fmt.Errorf("error creating assume role session: %s", err)
+		return nil, err
 	}
-
-	stsclient := sts.New(assumeRoleSession)
-	assumeRoleProvider := &stscreds.AssumeRoleProvider{
-		Client:  stsclient,
-		RoleARN: c.AssumeRoleARN,
-	}
-	if c.AssumeRoleSessionName != "" {
-		assumeRoleProvider.RoleSessionName = c.AssumeRoleSessionName
+	stsConfig.Credentials = creds
+	stsConfig.Region = aws.String(c.Region)
+	stsConfig.MaxRetries = aws.Int(c.MaxRetries)
+	if assumeRole.Region != "" {
+		stsConfig.Region = aws.String(assumeRole.Region)
 	}
-	if c.AssumeRoleExternalID != "" {
-		assumeRoleProvider.ExternalID = aws.String(c.AssumeRoleExternalID)
+	if assumeRole.StsEndpoint != "" {
+		stsConfig.Endpoint = aws.String(assumeRole.StsEndpoint)
 	}
-	if c.AssumeRolePolicy != "" {
-		assumeRoleProvider.Policy = aws.String(c.AssumeRolePolicy)
+	stsclient := sts.New(internalSession, stsConfig)
+
+	if c.StsConnectivityCheck {
+		if err := checkConnectivity(ctx, stsclient.Config.HTTPClient, stsclient.Endpoint); err != nil {
+			return nil, fmt.Errorf("error checking STS connectivity: %w", err)
+		}
 	}
 
-	providers = []awsCredentials.Provider{assumeRoleProvider}
+	assumeRoleCreds, assumeRoleChain := newAssumeRoleCredentials(stsclient, assumeRole, trail)
+	_, err = assumeRoleCreds.GetWithContext(ctx)
+	if err != nil && assumeRole.TagsOptional && len(assumeRole.Tags) > 0 && tagSessionDenied(err) {
+		logger.Printf("[WARN] Session tags denied assuming role %s (sts:TagSession), assuming role without session tags", assumeRole.RoleARN)
 
-	assumeRoleCreds := awsCredentials.NewChainCredentials(providers)
-	_, err = assumeRoleCreds.Get()
+		untaggedAssumeRole := *assumeRole
+		untaggedAssumeRole.Tags = nil
+		untaggedAssumeRole.TransitiveTagKeys = nil
+
+		assumeRoleCreds, assumeRoleChain = newAssumeRoleCredentials(stsclient, &untaggedAssumeRole, trail)
+		_, err = assumeRoleCreds.GetWithContext(ctx)
+	}
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoCredentialProviders" {
+		if ErrCodeEquals(err, "NoCredentialProviders") {
 			return nil, fmt.Errorf("The role %q cannot be assumed.\n\n"+
 				"  There are a number of possible causes of this - the most common are:\n"+
 				"    * The credentials used in order to assume the role are invalid\n"+
 				"    * The credentials do not have appropriate permission to assume the role\n"+
 				"    * The role ARN is not valid",
-				c.AssumeRoleARN)
+				assumeRole.RoleARN)
 		}
 
-		return nil, fmt.Errorf("Error loading credentials for AWS Provider: %s", err)
+		return nil, fmt.Errorf("Error loading credentials for AWS Provider: %w", err)
 	}
 
+	c.CredentialsRefresher.start(assumeRoleCreds, assumeRoleChain, c.Clock)
 	return assumeRoleCreds, nil
 }
 
+// newAssumeRoleCredentials returns credentials which assume the role described
+// by the given AssumeRole settings, recording retrievals in the audit trail,
+// along with their chain provider. Errors of the provider are kept in those of
+// the credentials, so that causes such as denied session tags can be detected.
+func newAssumeRoleCredentials(client stsiface.STSAPI, r *AssumeRole, trail *CredentialsAuditTrail) (*awsCredentials.Credentials, *expiringChainProvider) {
+	return newChainCredentials([]awsCredentials.Provider{
+		trail.wrap(&assumeRoleRetryProvider{newAssumeRoleProvider(client, r, trail.clock), trail.clock}, fmt.Sprintf("assumed role %s", r.RoleARN)),
+	}, true, trail.clock)
+}
+
+// tagSessionDenied returns whether the error, possibly batched by a provider
+// chain, is an sts:AssumeRole error due to sts:TagSession being denied.
+func tagSessionDenied(err error) bool {
+	if batchedErr, ok := err.(awserr.BatchedErrors); ok {
+		for _, origErr := range batchedErr.OrigErrs() {
+			if tagSessionDenied(origErr) {
+				return true
+			}
+		}
+	}
+
+	return ErrMessageContains(err, "AccessDenied", "sts:TagSession")
+}
+
+// newAssumeRoleProvider returns a credentials provider which assumes the role
+// described by the given AssumeRole settings.
+func newAssumeRoleProvider(client stsiface.STSAPI, r *AssumeRole, clock Clock) *stscreds.AssumeRoleProvider {
+	provider := &stscreds.AssumeRoleProvider{
+		Client:  client,
+		RoleARN: r.RoleARN,
+	}
+	provider.Expiry.CurrentTime = resolveClock(clock).Now
+	provider.ExpiryWindow = r.ResolveExpiryWindow()
+	if r.Duration > 0 {
+		provider.Duration = r.Duration
+	}
+	if r.ExternalID != "" {
+		provider.ExternalID = aws.String(r.ExternalID)
+	}
+	if r.MFASerialNumber != "" {
+		provider.SerialNumber = aws.String(r.MFASerialNumber)
+		provider.TokenProvider = r.MFATokenProvider
+	}
+	if r.Policy != "" {
+		provider.Policy = aws.String(r.Policy)
+	}
+	for _, policyARN := range r.PolicyARNs {
+		provider.PolicyArns = append(provider.PolicyArns, &sts.PolicyDescriptorType{Arn: aws.String(policyARN)})
+	}
+	if r.SessionName != "" {
+		provider.RoleSessionName = r.SessionName
+	}
+	if r.SourceIdentity != "" {
+		provider.SourceIdentity = aws.String(r.SourceIdentity)
+	}
+
+	// Sort the tag keys so that requests are deterministic.
+	tagKeys := make([]string, 0, len(r.Tags))
+	for key := range r.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		provider.Tags = append(provider.Tags, &sts.Tag{Key: aws.String(key), Value: aws.String(r.Tags[key])})
+	}
+	if len(r.TransitiveTagKeys) > 0 {
+		provider.TransitiveTagKeys = aws.StringSlice(r.TransitiveTagKeys)
+	}
+
+	return provider
+}
+
+// assumeRoleRetryProvider retries sts:AssumeRole calls of an AssumeRole
+// provider which failed with transient errors, e.g. throttling, so that a
+// single failure doesn't abort the initialization of the application, if the
+// STS client doesn't retry them itself.
+type assumeRoleRetryProvider struct {
+	*stscreds.AssumeRoleProvider
+	clock Clock
+}
+
+func (p *assumeRoleRetryProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+func (p *assumeRoleRetryProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	var value awsCredentials.Value
+	err := retryOnTransientError(ctx, p.clock, p.Client, fmt.Sprintf("sts:AssumeRole of %s", p.RoleARN), func() (err error) {
+		value, err = p.AssumeRoleProvider.RetrieveWithContext(ctx)
+		return err
+	})
+	return value, err
+}
+
+func (p *assumeRoleRetryProvider) unwrapProvider() awsCredentials.Provider {
+	return p.AssumeRoleProvider
+}
+
+// metadataApiAvailable checks the availability of the EC2 metadata API up to
+// the given number of attempts, with exponential backoff between attempts, so
+// that transient failures, e.g. at instance boot, are tolerated.
+func metadataApiAvailable(ctx context.Context, client *ec2metadata.EC2Metadata, attempts int) bool {
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(metadataApiCheckBaseBackoff << uint(attempt-1)):
+			}
+		}
+
+		_, err := client.GetMetadataWithContext(ctx, "instance-id")
+		if metadataTokenRequired(err) {
+			// The client re-enables session tokens after a 401 response, so
+			// the request is retried with a session token (IMDSv2).
+			logger.Print("[DEBUG] AWS metadata API requires a session token, retrying with session token")
+			_, err = client.GetMetadataWithContext(ctx, "instance-id")
+		}
+		if err == nil {
+			return true
+		}
+		logger.Printf("[DEBUG] AWS metadata API check attempt %d of %d failed", attempt+1, attempts)
+	}
+	return false
+}
+
+// metadataApiCheckTimeout returns the timeout of the availability check of the
+// EC2 metadata API: the request timeout for each of the attempts, and the
+// backoff between them.
+func metadataApiCheckTimeout(requestTimeout time.Duration, attempts int) time.Duration {
+	timeout := requestTimeout * time.Duration(attempts)
+	for attempt := 1; attempt < attempts; attempt++ {
+		timeout += metadataApiCheckBaseBackoff << uint(attempt-1)
+	}
+	return timeout
+}
+
+// metadataApiTimeout returns the timeout of EC2 metadata API requests set by
+// the AWS_METADATA_TIMEOUT environment variable or, if it is not set, by the
+// AWS_METADATA_SERVICE_TIMEOUT environment variable.
+func metadataApiTimeout() (time.Duration, bool) {
+	var timeout time.Duration
+	var envVar string
+
+	if value := os.Getenv(metadataTimeoutEnvVar); value != "" {
+		envVar = metadataTimeoutEnvVar
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil {
+			logger.Printf("[WARN] Error converting %s to time.Duration: %s", envVar, err)
+			return 0, false
+		}
+	} else if value := os.Getenv(metadataServiceTimeoutEnvVar); value != "" {
+		envVar = metadataServiceTimeoutEnvVar
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			logger.Printf("[WARN] Error converting %s to a number of seconds: %s", envVar, err)
+			return 0, false
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	} else {
+		return 0, false
+	}
+
+	if timeout <= 0 {
+		logger.Printf("[WARN] Non-positive value of %s (%s) is meaningless, ignoring", envVar, timeout.String())
+		return 0, false
+	}
+	return timeout, true
+}
+
+// metadataApiAttempts returns the number of attempts of EC2 metadata API
+// requests set by the AWS_METADATA_SERVICE_NUM_ATTEMPTS environment variable.
+func metadataApiAttempts() (int, bool) {
+	value := os.Getenv(metadataServiceNumAttemptsEnvVar)
+	if value == "" {
+		return 0, false
+	}
+
+	attempts, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Printf("[WARN] Error converting %s to an integer: %s", metadataServiceNumAttemptsEnvVar, err)
+		return 0, false
+	}
+	if attempts <= 0 {
+		logger.Printf("[WARN] Non-positive value of %s (%d) is meaningless, ignoring", metadataServiceNumAttemptsEnvVar, attempts)
+		return 0, false
+	}
+	return attempts, true
+}
+
+// metadataTokenRequired returns whether the error is a 401 response of the
+// EC2 metadata API to a request without a session token, e.g. after the
+// client fell back to IMDSv1 because a session token request failed.
+func metadataTokenRequired(err error) bool {
+	return anyCause(err, func(err error) bool {
+		requestFailure, ok := err.(awserr.RequestFailure)
+		return ok && requestFailure.StatusCode() == http.StatusUnauthorized
+	})
+}
+
+// ec2RoleTokenRetryProvider retries retrievals of EC2 instance profile
+// credentials which failed with a 401 response, as the EC2 metadata client
+// re-enables session tokens (IMDSv2) after such responses but doesn't retry
+// them, e.g. after falling back to IMDSv1 because a session token request
+// failed.
+type ec2RoleTokenRetryProvider struct {
+	*ec2rolecreds.EC2RoleProvider
+}
+
+func (p *ec2RoleTokenRetryProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+func (p *ec2RoleTokenRetryProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	value, err := p.EC2RoleProvider.RetrieveWithContext(ctx)
+	if metadataTokenRequired(err) {
+		logger.Print("[DEBUG] AWS metadata API requires a session token, retrying with session token")
+		value, err = p.EC2RoleProvider.RetrieveWithContext(ctx)
+	}
+	return value, err
+}
+
+func (p *ec2RoleTokenRetryProvider) unwrapProvider() awsCredentials.Provider {
+	return p.EC2RoleProvider
+}
+
+// metadataApiCheckResults caches the results of metadata API checks by
+// endpoint for the lifetime of the process, so that repeated GetCredentials
+// calls on machines without a metadata API don't each wait for the check.
+var metadataApiCheckResults = struct {
+	sync.Mutex
+	available map[string]bool
+}{available: make(map[string]bool)}
+
+func cachedMetadataApiAvailable(endpoint string) (bool, bool) {
+	metadataApiCheckResults.Lock()
+	defer metadataApiCheckResults.Unlock()
+
+	available, ok := metadataApiCheckResults.available[endpoint]
+	return available, ok
+}
+
+func cacheMetadataApiAvailable(endpoint string, available bool) {
+	metadataApiCheckResults.Lock()
+	defer metadataApiCheckResults.Unlock()
+
+	metadataApiCheckResults.available[endpoint] = available
+}
+
+// metadataApiCheckFlight is a metadata API check shared by concurrent
+// GetCredentials calls, which is cancelled once no call waits for it.
+type metadataApiCheckFlight struct {
+	done      chan struct{}
+	available bool
+	waiters   int
+	cancel    context.CancelFunc
+}
+
+// metadataApiCheckFlights are the metadata API checks in flight by endpoint,
+// so that concurrent GetCredentials calls, e.g. at startup, make a single
+// check rather than each probing the metadata API.
+var metadataApiCheckFlights = struct {
+	sync.Mutex
+	flights map[string]*metadataApiCheckFlight
+}{flights: make(map[string]*metadataApiCheckFlight)}
+
+// sharedMetadataApiAvailable returns the result of the check of the metadata
+// API endpoint in flight, or of a new check if none is. It returns false if
+// the context is done first. Only completed checks are cached, not those
+// cancelled once other credentials are found by all waiting calls.
+func sharedMetadataApiAvailable(ctx context.Context, endpoint string, check func(context.Context) bool) bool {
+	metadataApiCheckFlights.Lock()
+	flight, ok := metadataApiCheckFlights.flights[endpoint]
+	if !ok {
+		// The check outlives the cancellation of the context, if it has other
+		// waiters, but keeps its values, e.g. the span of the caller.
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		flight = &metadataApiCheckFlight{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		metadataApiCheckFlights.flights[endpoint] = flight
+
+		go func() {
+			defer cancel()
+
+			available := check(flightCtx)
+			if flightCtx.Err() == nil {
+				cacheMetadataApiAvailable(endpoint, available)
+			}
+
+			metadataApiCheckFlights.Lock()
+			if metadataApiCheckFlights.flights[endpoint] == flight {
+				delete(metadataApiCheckFlights.flights, endpoint)
+			}
+			flight.available = available
+			metadataApiCheckFlights.Unlock()
+			close(flight.done)
+		}()
+	} else {
+		logger.Print("[DEBUG] Waiting for AWS metadata API check in flight")
+	}
+	flight.waiters++
+	metadataApiCheckFlights.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.available
+	case <-ctx.Done():
+		metadataApiCheckFlights.Lock()
+		defer metadataApiCheckFlights.Unlock()
+
+		flight.waiters--
+		if flight.waiters == 0 {
+			flight.cancel()
+			// Later calls start a new check rather than waiting for the
+			// cancelled one.
+			if metadataApiCheckFlights.flights[endpoint] == flight {
+				delete(metadataApiCheckFlights.flights, endpoint)
+			}
+		}
+		return false
+	}
+}
+
+// ResetMetadataApiCheck clears the cached results of EC2 metadata API checks,
+// so that the next GetCredentials call checks the availability of the metadata
+// API again, e.g. after the network configuration changed.
+func ResetMetadataApiCheck() {
+	metadataApiCheckResults.Lock()
+	defer metadataApiCheckResults.Unlock()
+
+	metadataApiCheckResults.available = make(map[string]bool)
+}
+
+// localCredentialsAvailable returns whether any of the providers, which must
+// not make network calls, can supply credentials.
+func localCredentialsAvailable(providers ...awsCredentials.Provider) bool {
+	for _, provider := range providers {
+		if _, err := provider.Retrieve(); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func setOptionalEndpoint(cfg *aws.Config) string {
 	endpoint := os.Getenv("AWS_METADATA_URL")
 	if endpoint != "" {
-		log.Printf("[INFO] Setting custom metadata endpoint: %q", endpoint)
+		logger.With(logFields{"endpoint": endpoint}).Printf("[INFO] Setting custom metadata endpoint: %q", endpoint)
 		cfg.Endpoint = aws.String(endpoint)
 		return endpoint
 	}
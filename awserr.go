@@ -1,37 +1,154 @@
 package awsbase
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
 )
 
 // IsAWSErr returns true if the error matches all these conditions:
-//  * err is of type awserr.Error
+//  * err is of type awserr.Error, or wraps one
 //  * Error.Code() matches code
 //  * Error.Message() contains message
 func IsAWSErr(err error, code string, message string) bool {
-	awsErr, ok := err.(awserr.Error)
+	return ErrMessageContains(err, code, message)
+}
 
-	if !ok {
+// IsAWSErrExtended returns true if the error matches all these conditions:
+//  * err is of type awserr.Error, or wraps one
+//  * Error.Code() matches code
+//  * Error.Message() contains message
+//  * Error.OrigErr() contains origErrMessage
+func IsAWSErrExtended(err error, code string, message string, origErrMessage string) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || !IsAWSErr(awsErr, code, message) {
 		return false
 	}
+	return awsErr.OrigErr() != nil && strings.Contains(awsErr.OrigErr().Error(), origErrMessage)
+}
 
-	if awsErr.Code() != code {
+// ErrCodeEquals returns true if the error is of type awserr.Error, or wraps
+// one, whose Code() matches any of the codes.
+func ErrCodeEquals(err error, codes ...string) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
 		return false
 	}
 
-	return strings.Contains(awsErr.Message(), message)
+	for _, code := range codes {
+		if awsErr.Code() == code {
+			return true
+		}
+	}
+	return false
 }
 
-// IsAWSErrExtended returns true if the error matches all these conditions:
-//  * err is of type awserr.Error
-//  * Error.Code() matches code
-//  * Error.Message() contains message
-//  * Error.OrigErr() contains origErrMessage
-func IsAWSErrExtended(err error, code string, message string, origErrMessage string) bool {
-	if !IsAWSErr(err, code, message) {
+// ErrMessageContains returns true if the error is of type awserr.Error, or
+// wraps one, whose Code() matches code and whose Message() contains message.
+func ErrMessageContains(err error, code string, message string) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
 		return false
 	}
-	return strings.Contains(err.(awserr.Error).OrigErr().Error(), origErrMessage)
+	return awsErr.Code() == code && strings.Contains(awsErr.Message(), message)
+}
+
+// RequestError is returned when an AWS API request made while resolving
+// account or credential information fails, carrying the details AWS support
+// needs to investigate the failure.
+type RequestError struct {
+	// Message describes what was being attempted.
+	Message string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// RequestID is the AWS request ID of the failed request.
+	RequestID string
+	// Err is the underlying AWS Go SDK error.
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// wrapRequestError returns a *RequestError if err is an AWS request failure,
+// otherwise an error with the message prepended.
+func wrapRequestError(err error, message string) error {
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		return &RequestError{
+			Message:    message,
+			StatusCode: requestFailure.StatusCode(),
+			RequestID:  requestFailure.RequestID(),
+			Err:        err,
+		}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// SSOTokenError is returned when credentials cannot be resolved because the
+// cached AWS SSO token of the profile has expired or is absent.
+type SSOTokenError struct {
+	// Profile is the shared configuration profile using AWS SSO.
+	Profile string
+	// SSOSession is the sso-session the profile references, if any.
+	SSOSession string
+	// TokenCachePath is the path of the cached AWS SSO token, which is keyed
+	// by the sso-session, or by the start URL for legacy SSO profiles.
+	TokenCachePath string
+	// Err is the underlying AWS Go SDK error.
+	Err error
+}
+
+func (e *SSOTokenError) Error() string {
+	if e.SSOSession != "" {
+		return fmt.Sprintf("the AWS SSO session %q for profile %q has expired or is invalid, "+
+			"run `aws sso login --sso-session %s` to sign in again: %s", e.SSOSession, e.Profile, e.SSOSession, e.Err)
+	}
+	return fmt.Sprintf("the AWS SSO session for profile %q has expired or is invalid, "+
+		"run `aws sso login --profile %s` to sign in again: %s", e.Profile, e.Profile, e.Err)
+}
+
+func (e *SSOTokenError) Unwrap() error {
+	return e.Err
+}
+
+// ssoTokenError returns a *SSOTokenError if err was caused by an expired or
+// absent AWS SSO token, otherwise nil. The expiry of cached tokens is checked
+// against the Clock.
+func ssoTokenError(err error, profile string, clock Clock) error {
+	if err == nil {
+		return nil
+	}
+
+	config, configErr := loadSSOConfig(profile)
+	if configErr != nil {
+		config = nil
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == ssocreds.ErrCodeSSOProviderInvalidToken {
+		err = awsErr
+	} else if config == nil || config.SessionName == "" || config.cachedTokenValid(clock) {
+		// The AWS Go SDK doesn't report invalid tokens of profiles using an
+		// sso-session with an error code, so their cached token is checked.
+		return nil
+	}
+
+	ssoErr := &SSOTokenError{
+		Profile: profile,
+		Err:     err,
+	}
+	if config != nil {
+		ssoErr.SSOSession = config.SessionName
+		ssoErr.TokenCachePath, _ = config.tokenCachePath()
+	}
+	return ssoErr
 }
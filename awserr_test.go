@@ -0,0 +1,99 @@
+package awsbase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestErrCodeEquals(t *testing.T) {
+	var testCases = []struct {
+		Description string
+		Err         error
+		Codes       []string
+		Expected    bool
+	}{
+		{
+			Description: "nil error",
+			Codes:       []string{"AccessDenied"},
+		},
+		{
+			Description: "other error",
+			Err:         errors.New("AccessDenied"),
+			Codes:       []string{"AccessDenied"},
+		},
+		{
+			Description: "matching code",
+			Err:         awserr.New("AccessDenied", "denied", nil),
+			Codes:       []string{"InvalidClientTokenId", "AccessDenied"},
+			Expected:    true,
+		},
+		{
+			Description: "other code",
+			Err:         awserr.New("ValidationError", "invalid", nil),
+			Codes:       []string{"AccessDenied"},
+		},
+		{
+			Description: "wrapped matching code",
+			Err:         fmt.Errorf("error calling sts:GetCallerIdentity: %w", awserr.New("AccessDenied", "denied", nil)),
+			Codes:       []string{"AccessDenied"},
+			Expected:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			if got := ErrCodeEquals(testCase.Err, testCase.Codes...); got != testCase.Expected {
+				t.Errorf("Expected %t, got %t", testCase.Expected, got)
+			}
+		})
+	}
+}
+
+func TestErrMessageContains(t *testing.T) {
+	var testCases = []struct {
+		Description string
+		Err         error
+		Code        string
+		Message     string
+		Expected    bool
+	}{
+		{
+			Description: "nil error",
+			Code:        "AccessDenied",
+		},
+		{
+			Description: "matching message",
+			Err:         awserr.New("AccessDenied", "not authorized to perform: sts:TagSession", nil),
+			Code:        "AccessDenied",
+			Message:     "sts:TagSession",
+			Expected:    true,
+		},
+		{
+			Description: "other message",
+			Err:         awserr.New("AccessDenied", "not authorized to perform: sts:AssumeRole", nil),
+			Code:        "AccessDenied",
+			Message:     "sts:TagSession",
+		},
+		{
+			Description: "wrapped matching message",
+			Err:         &RequestError{Message: "failed", Err: awserr.New("AccessDenied", "not authorized to perform: sts:TagSession", nil)},
+			Code:        "AccessDenied",
+			Message:     "sts:TagSession",
+			Expected:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			if got := ErrMessageContains(testCase.Err, testCase.Code, testCase.Message); got != testCase.Expected {
+				t.Errorf("Expected %t, got %t", testCase.Expected, got)
+			}
+			if got := IsAWSErr(testCase.Err, testCase.Code, testCase.Message); got != testCase.Expected {
+				t.Errorf("Expected IsAWSErr %t, got %t", testCase.Expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package awsmocks
+
+const (
+	// MockIamGetUserAccountID is the account ID returned by MockIamGetUserValidEndpoint.
+	MockIamGetUserAccountID = `111111111111`
+	// MockIamGetUserPartition is the partition of the ARN returned by MockIamGetUserValidEndpoint.
+	MockIamGetUserPartition = `aws`
+
+	// MockIamListRolesAccountID is the account ID returned by MockIamListRolesValidEndpoint.
+	MockIamListRolesAccountID = `444444444444`
+	// MockIamListRolesPartition is the partition of the ARN returned by MockIamListRolesValidEndpoint.
+	MockIamListRolesPartition = `aws`
+)
+
+const MockIamGetUserValidResponseBody = `<GetUserResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <GetUserResult>
+    <User>
+      <UserId>AIDACKCEVSQ6C2EXAMPLE</UserId>
+      <Path>/division_abc/subdivision_xyz/</Path>
+      <UserName>Bob</UserName>
+      <Arn>arn:aws:iam::111111111111:user/division_abc/subdivision_xyz/Bob</Arn>
+      <CreateDate>2013-10-02T17:01:44Z</CreateDate>
+      <PasswordLastUsed>2014-10-10T14:37:51Z</PasswordLastUsed>
+    </User>
+  </GetUserResult>
+  <ResponseMetadata>
+    <RequestId>7a62c49f-347e-4fc4-9331-6e8eEXAMPLE</RequestId>
+  </ResponseMetadata>
+</GetUserResponse>`
+
+const MockIamGetUserUnauthorizedResponseBody = `<ErrorResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>User: arn:aws:iam::123456789012:user/Bob is not authorized to perform: iam:GetUser on resource: arn:aws:iam::123456789012:user/Bob</Message>
+  </Error>
+  <RequestId>7a62c49f-347e-4fc4-9331-6e8eEXAMPLE</RequestId>
+</ErrorResponse>`
+
+const MockIamListRolesValidResponseBody = `<ListRolesResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <ListRolesResult>
+    <IsTruncated>true</IsTruncated>
+    <Marker>AWceSSsKsazQ4IEplT9o4hURCzBs00iavlEvEXAMPLE</Marker>
+    <Roles>
+      <member>
+        <Path>/</Path>
+        <AssumeRolePolicyDocument>%7B%22Version%22%3A%222008-10-17%22%2C%22Statement%22%3A%5B%7B%22Sid%22%3A%22%22%2C%22Effect%22%3A%22Allow%22%2C%22Principal%22%3A%7B%22Service%22%3A%22ec2.amazonaws.com%22%7D%2C%22Action%22%3A%22sts%3AAssumeRole%22%7D%5D%7D</AssumeRolePolicyDocument>
+        <RoleId>AROACKCEVSQ6C2EXAMPLE</RoleId>
+        <RoleName>elasticbeanstalk-role</RoleName>
+        <Arn>arn:aws:iam::444444444444:role/elasticbeanstalk-role</Arn>
+        <CreateDate>2013-10-02T17:01:44Z</CreateDate>
+      </member>
+    </Roles>
+  </ListRolesResult>
+  <ResponseMetadata>
+    <RequestId>7a62c49f-347e-4fc4-9331-6e8eEXAMPLE</RequestId>
+  </ResponseMetadata>
+</ListRolesResponse>`
+
+var MockIamGetUserValidEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=GetUser&Version=2010-05-08",
+	},
+	Response: &MockResponse{
+		StatusCode:  200,
+		Body:        MockIamGetUserValidResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+var MockIamGetUserUnauthorizedEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=GetUser&Version=2010-05-08",
+	},
+	Response: &MockResponse{
+		StatusCode:  403,
+		Body:        MockIamGetUserUnauthorizedResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+var MockIamListRolesValidEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=ListRoles&MaxItems=1&Version=2010-05-08",
+	},
+	Response: &MockResponse{
+		StatusCode:  200,
+		Body:        MockIamListRolesValidResponseBody,
+		ContentType: "text/xml",
+	},
+}
@@ -0,0 +1,160 @@
+package awsmocks
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type MetadataEndpoint struct {
+	Uri  string `json:"uri"`
+	Body string `json:"body"`
+}
+
+const (
+	// MockEc2MetadataIamInfoAccountID is the account ID of the instance
+	// profile returned by MockEc2MetadataIamInfoEndpoint.
+	MockEc2MetadataIamInfoAccountID = `000000000000`
+	// MockEc2MetadataIamInfoPartition is the partition of the instance
+	// profile returned by MockEc2MetadataIamInfoEndpoint.
+	MockEc2MetadataIamInfoPartition = `aws`
+	// MockEc2MetadataToken is the session token issued by the mock EC2
+	// metadata server.
+	MockEc2MetadataToken = `Ec2MetadataApiToken`
+)
+
+var MockEc2MetadataInstanceIdEndpoint = &MetadataEndpoint{
+	Uri:  "/latest/meta-data/instance-id",
+	Body: "mock-instance-id",
+}
+
+var MockEc2MetadataSecurityCredentialsEndpoints = []*MetadataEndpoint{
+	{
+		Uri:  "/latest/meta-data/iam/security-credentials/",
+		Body: "test_role",
+	},
+	{
+		Uri:  "/latest/meta-data/iam/security-credentials/test_role",
+		Body: "{\"Code\":\"Success\",\"LastUpdated\":\"2015-12-11T17:17:25Z\",\"Type\":\"AWS-HMAC\",\"AccessKeyId\":\"somekey\",\"SecretAccessKey\":\"somesecret\",\"Token\":\"sometoken\"}",
+	},
+}
+
+var MockEc2MetadataIamInfoEndpoint = &MetadataEndpoint{
+	Uri:  "/latest/meta-data/iam/info",
+	Body: "{\"Code\": \"Success\",\"LastUpdated\": \"2016-03-17T12:27:32Z\",\"InstanceProfileArn\": \"arn:aws:iam::000000000000:instance-profile/my-instance-profile\",\"InstanceProfileId\": \"AIPAABCDEFGHIJKLMN123\"}",
+}
+
+var MockEc2MetadataInstanceIdentityDocumentEndpoint = &MetadataEndpoint{
+	Uri:  "/latest/dynamic/instance-identity/document",
+	Body: "{\"accountId\": \"000000000000\",\"architecture\": \"x86_64\",\"availabilityZone\": \"us-east-1a\",\"imageId\": \"ami-12345678\",\"instanceId\": \"mock-instance-id\",\"instanceType\": \"t2.micro\",\"pendingTime\": \"2016-03-17T12:27:32Z\",\"privateIp\": \"10.0.0.1\",\"region\": \"us-east-1\",\"version\": \"2017-09-30\"}",
+}
+
+// EC2MetadataServerOptions configures the behaviour of the mock EC2 metadata
+// server.
+type EC2MetadataServerOptions struct {
+	// RequireToken enforces IMDSv2, responding 401 to requests without a
+	// valid session token.
+	RequireToken bool
+
+	// DisableToken simulates an IMDSv1-only environment, responding 404 to
+	// session token requests.
+	DisableToken bool
+
+	// UnavailableTokenResponses is the number of session token requests which
+	// receive a 404 response before session tokens are issued, e.g. to
+	// simulate clients falling back to IMDSv1 when tokens are required.
+	UnavailableTokenResponses int
+
+	// UnauthorizedResponses is the number of metadata requests which receive
+	// a 401 response before requests are served normally.
+	UnauthorizedResponses int
+
+	// Delay is added before every response, e.g. to exceed client timeouts.
+	Delay time.Duration
+}
+
+// NewEC2MetadataServer establishes a httptest server to mock out the internal
+// AWS EC2 metadata service. Session tokens are issued, but not required.
+// Requests which do not match any endpoint receive a 400 response.
+func NewEC2MetadataServer(endpoints []*MetadataEndpoint) *httptest.Server {
+	return NewEC2MetadataServerWithOptions(endpoints, EC2MetadataServerOptions{})
+}
+
+// NewEC2MetadataServerWithOptions establishes a httptest server to mock out
+// the internal AWS EC2 metadata service with the given options.
+func NewEC2MetadataServerWithOptions(endpoints []*MetadataEndpoint, options EC2MetadataServerOptions) *httptest.Server {
+	var mu sync.Mutex
+	unauthorizedResponses := options.UnauthorizedResponses
+	unavailableTokenResponses := options.UnavailableTokenResponses
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if options.Delay > 0 {
+			time.Sleep(options.Delay)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("Server", "MockEC2")
+		log.Printf("[DEBUG] Mocker server received request to %q", r.RequestURI)
+
+		if r.RequestURI == "/latest/api/token" {
+			mu.Lock()
+			unavailable := unavailableTokenResponses > 0
+			if unavailable {
+				unavailableTokenResponses--
+			}
+			mu.Unlock()
+
+			switch {
+			case options.DisableToken, unavailable:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method != http.MethodPut:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			case r.Header.Get("X-Forwarded-For") != "":
+				w.WriteHeader(http.StatusForbidden)
+			case r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds") == "":
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds"))
+				fmt.Fprint(w, MockEc2MetadataToken)
+			}
+			return
+		}
+
+		if token := r.Header.Get("X-Aws-Ec2-Metadata-Token"); token != "" && token != MockEc2MetadataToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if options.RequireToken && r.Header.Get("X-Aws-Ec2-Metadata-Token") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		unauthorized := unauthorizedResponses > 0
+		if unauthorized {
+			unauthorizedResponses--
+		}
+		mu.Unlock()
+		if unauthorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		for _, e := range endpoints {
+			if r.RequestURI == e.Uri {
+				fmt.Fprintln(w, e.Body)
+				return
+			}
+		}
+		w.WriteHeader(400)
+	}))
+}
+
+// EC2MetadataURL returns the value to use for the AWS_METADATA_URL
+// environment variable to direct EC2 metadata requests to the server.
+func EC2MetadataURL(ts *httptest.Server) string {
+	return ts.URL + "/latest"
+}
@@ -0,0 +1,99 @@
+package awsmocks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestNewEC2MetadataServerWithOptions(t *testing.T) {
+	var testCases = []struct {
+		Description   string
+		Options       EC2MetadataServerOptions
+		DisableIMDSv1 bool
+		Timeout       time.Duration
+		ExpectedError bool
+	}{
+		{
+			Description: "IMDSv2",
+			Options:     EC2MetadataServerOptions{RequireToken: true},
+		},
+		{
+			Description:   "IMDSv2 required by client",
+			Options:       EC2MetadataServerOptions{RequireToken: true},
+			DisableIMDSv1: true,
+		},
+		{
+			Description: "IMDSv1 fallback",
+			Options:     EC2MetadataServerOptions{DisableToken: true},
+		},
+		{
+			Description:   "IMDSv1 not allowed by client",
+			Options:       EC2MetadataServerOptions{DisableToken: true},
+			DisableIMDSv1: true,
+			ExpectedError: true,
+		},
+		{
+			Description:   "Unauthorized responses",
+			Options:       EC2MetadataServerOptions{RequireToken: true, UnauthorizedResponses: 10},
+			ExpectedError: true,
+		},
+		{
+			Description:   "Timeout",
+			Options:       EC2MetadataServerOptions{Delay: 100 * time.Millisecond},
+			Timeout:       10 * time.Millisecond,
+			ExpectedError: true,
+		},
+	}
+
+	endpoints := []*MetadataEndpoint{MockEc2MetadataIamInfoEndpoint, MockEc2MetadataInstanceIdentityDocumentEndpoint}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			ts := NewEC2MetadataServerWithOptions(endpoints, testCase.Options)
+			defer ts.Close()
+
+			cfg := &aws.Config{
+				Endpoint:   aws.String(EC2MetadataURL(ts)),
+				MaxRetries: aws.Int(0),
+			}
+			if testCase.DisableIMDSv1 {
+				cfg.EC2MetadataEnableFallback = aws.Bool(false)
+			}
+			if testCase.Timeout > 0 {
+				cfg.HTTPClient = ts.Client()
+				cfg.HTTPClient.Timeout = testCase.Timeout
+			}
+			sess, err := session.NewSession(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := ec2metadata.New(sess)
+
+			info, err := client.IAMInfo()
+			if err != nil && !testCase.ExpectedError {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if err == nil && testCase.ExpectedError {
+				t.Fatal("Expected error, received none")
+			}
+			if err != nil {
+				return
+			}
+			if info.InstanceProfileID != "AIPAABCDEFGHIJKLMN123" {
+				t.Errorf("Unexpected instance profile ID: %s", info.InstanceProfileID)
+			}
+
+			document, err := client.GetInstanceIdentityDocument()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if document.AccountID != MockEc2MetadataIamInfoAccountID {
+				t.Errorf("Unexpected account ID: %s", document.AccountID)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package awsmocks provides in-process mock servers for the AWS APIs used
+// during authentication, so authentication flows can be tested without
+// real AWS credentials or network access.
+package awsmocks
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// MockRequestID is the request ID returned by mocked AWS API responses.
+const MockRequestID = "1b206dd1-f9a8-11e5-becf-051c60f11c4a"
+
+type MockEndpoint struct {
+	Request  *MockRequest
+	Response *MockResponse
+}
+
+type MockRequest struct {
+	Method string
+	Uri    string
+	Body   string
+}
+
+type MockResponse struct {
+	StatusCode  int
+	Body        string
+	ContentType string
+}
+
+// NewServer establishes a httptest server to simulate behaviour of a real
+// AWS API server. Requests which do not match any endpoint receive a 400
+// response.
+func NewServer(svcName string, endpoints []*MockEndpoint) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		if _, err := buf.ReadFrom(r.Body); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprintf(w, "Error reading from HTTP Request Body: %s", err)
+			return
+		}
+		requestBody := buf.String()
+
+		log.Printf("[DEBUG] Received %s API %q request to %q: %s",
+			svcName, r.Method, r.RequestURI, requestBody)
+
+		for _, e := range endpoints {
+			if r.Method == e.Request.Method && r.RequestURI == e.Request.Uri && requestBody == e.Request.Body {
+				log.Printf("[DEBUG] Mocked %s API responding with %d: %s",
+					svcName, e.Response.StatusCode, e.Response.Body)
+
+				w.Header().Set("Content-Type", e.Response.ContentType)
+				w.Header().Set("X-Amzn-Requestid", MockRequestID)
+				w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				w.WriteHeader(e.Response.StatusCode)
+
+				fmt.Fprintln(w, e.Response.Body)
+				return
+			}
+		}
+
+		w.WriteHeader(400)
+	}))
+}
+
+// Servers groups mock IAM, STS, and EC2 metadata servers for testing
+// complete authentication flows.
+type Servers struct {
+	IAM         *httptest.Server
+	STS         *httptest.Server
+	EC2Metadata *httptest.Server
+}
+
+// NewServers establishes mock IAM, STS, and EC2 metadata servers with the
+// given endpoints. Call Close when finished.
+func NewServers(iamEndpoints, stsEndpoints []*MockEndpoint, ec2MetadataEndpoints []*MetadataEndpoint) *Servers {
+	return &Servers{
+		IAM:         NewServer("IAM", iamEndpoints),
+		STS:         NewServer("STS", stsEndpoints),
+		EC2Metadata: NewEC2MetadataServer(ec2MetadataEndpoints),
+	}
+}
+
+// IamEndpoint returns the endpoint to use as the IAM endpoint.
+func (s *Servers) IamEndpoint() string {
+	return s.IAM.URL
+}
+
+// StsEndpoint returns the endpoint to use as the STS endpoint.
+func (s *Servers) StsEndpoint() string {
+	return s.STS.URL
+}
+
+// EC2MetadataEndpoint returns the endpoint to use as the AWS_METADATA_URL
+// environment variable.
+func (s *Servers) EC2MetadataEndpoint() string {
+	return EC2MetadataURL(s.EC2Metadata)
+}
+
+// Close shuts down all servers.
+func (s *Servers) Close() {
+	s.IAM.Close()
+	s.STS.Close()
+	s.EC2Metadata.Close()
+}
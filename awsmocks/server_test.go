@@ -0,0 +1,33 @@
+package awsmocks
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServer_dateHeader(t *testing.T) {
+	// The Date header is in GMT regardless of the local time zone, as
+	// http.ParseTime expects.
+	local := time.Local
+	time.Local = time.FixedZone("UTC-7", -7*60*60)
+	defer func() { time.Local = local }()
+
+	ts := NewServer("STS", []*MockEndpoint{MockStsGetCallerIdentityValidEndpoint})
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/", "application/x-www-form-urlencoded", strings.NewReader(MockStsGetCallerIdentityValidEndpoint.Request.Body))
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		t.Fatalf("Error parsing Date header %q: %s", resp.Header.Get("Date"), err)
+	}
+	if skew := time.Since(serverTime); skew < -time.Minute || skew > time.Minute {
+		t.Errorf("Expected Date header close to the current time, got %s (skew %s)", resp.Header.Get("Date"), skew)
+	}
+}
@@ -0,0 +1,310 @@
+package awsmocks
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+const (
+	// MockStsAssumeRoleArn is the role ARN matched by MockStsAssumeRoleValidEndpoint.
+	MockStsAssumeRoleArn = `arn:aws:iam::555555555555:role/AssumeRole`
+	// MockStsAssumeRoleSessionName is the session name matched by MockStsAssumeRoleValidEndpoint.
+	MockStsAssumeRoleSessionName = `AssumeRoleSessionName`
+	// MockStsAssumeRoleAccessKey is the access key returned by MockStsAssumeRoleValidEndpoint.
+	MockStsAssumeRoleAccessKey = `AssumeRoleAccessKey`
+	// MockStsAssumeRoleSecretKey is the secret key returned by MockStsAssumeRoleValidEndpoint.
+	MockStsAssumeRoleSecretKey = `AssumeRoleSecretKey`
+	// MockStsAssumeRoleSessionToken is the session token returned by MockStsAssumeRoleValidEndpoint.
+	MockStsAssumeRoleSessionToken = `AssumeRoleSessionToken`
+
+	// MockStsAssumeRoleWithWebIdentitySessionName is the session name matched by MockStsAssumeRoleWithWebIdentityValidEndpoint.
+	MockStsAssumeRoleWithWebIdentitySessionName = `AssumeRoleWithWebIdentitySessionName`
+	// MockStsAssumeRoleWithWebIdentityToken is the web identity token matched by MockStsAssumeRoleWithWebIdentityValidEndpoint.
+	MockStsAssumeRoleWithWebIdentityToken = `WebIdentityToken`
+	// MockStsAssumeRoleWithWebIdentityAccessKey is the access key returned by MockStsAssumeRoleWithWebIdentityValidEndpoint.
+	MockStsAssumeRoleWithWebIdentityAccessKey = `AssumeRoleWithWebIdentityAccessKey`
+
+	// MockStsGetCallerIdentityAccountID is the account ID returned by MockStsGetCallerIdentityValidEndpoint.
+	MockStsGetCallerIdentityAccountID = `222222222222`
+	// MockStsGetCallerIdentityPartition is the partition of the ARN returned by MockStsGetCallerIdentityValidEndpoint.
+	MockStsGetCallerIdentityPartition = `aws`
+)
+
+const MockStsAssumeRoleValidResponseBody = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::555555555555:assumed-role/AssumeRole/AssumeRoleSessionName</Arn>
+      <AssumedRoleId>ARO123EXAMPLE123:AssumeRoleSessionName</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>AssumeRoleAccessKey</AccessKeyId>
+      <SecretAccessKey>AssumeRoleSecretKey</SecretAccessKey>
+      <SessionToken>AssumeRoleSessionToken</SessionToken>
+      <Expiration>2099-12-31T23:59:59Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`
+
+const MockStsAssumeRoleWithWebIdentityValidResponseBody = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <SubjectFromWebIdentityToken>system:serviceaccount:default:example</SubjectFromWebIdentityToken>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::555555555555:assumed-role/AssumeRole/AssumeRoleWithWebIdentitySessionName</Arn>
+      <AssumedRoleId>ARO123EXAMPLE123:AssumeRoleWithWebIdentitySessionName</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>AssumeRoleWithWebIdentityAccessKey</AccessKeyId>
+      <SecretAccessKey>AssumeRoleWithWebIdentitySecretKey</SecretAccessKey>
+      <SessionToken>AssumeRoleWithWebIdentitySessionToken</SessionToken>
+      <Expiration>2099-12-31T23:59:59Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</AssumeRoleWithWebIdentityResponse>`
+
+const MockStsGetCallerIdentityValidResponseBody = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+   <Arn>arn:aws:iam::222222222222:user/Alice</Arn>
+    <UserId>AKIAI44QH8DHBEXAMPLE</UserId>
+    <Account>222222222222</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</GetCallerIdentityResponse>`
+
+const MockStsGetCallerIdentityUnauthorizedResponseBody = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>User: arn:aws:iam::123456789012:user/Bob is not authorized to perform: sts:GetCallerIdentity</Message>
+  </Error>
+  <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+</ErrorResponse>`
+
+var MockStsAssumeRoleValidEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=AssumeRole&DurationSeconds=900&RoleArn=arn%3Aaws%3Aiam%3A%3A555555555555%3Arole%2FAssumeRole&RoleSessionName=AssumeRoleSessionName&Version=2011-06-15",
+	},
+	Response: &MockResponse{
+		StatusCode:  200,
+		Body:        MockStsAssumeRoleValidResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+var MockStsAssumeRoleWithWebIdentityValidEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=AssumeRoleWithWebIdentity&RoleArn=arn%3Aaws%3Aiam%3A%3A555555555555%3Arole%2FAssumeRole&RoleSessionName=AssumeRoleWithWebIdentitySessionName&Version=2011-06-15&WebIdentityToken=WebIdentityToken",
+	},
+	Response: &MockResponse{
+		StatusCode:  200,
+		Body:        MockStsAssumeRoleWithWebIdentityValidResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+var MockStsGetCallerIdentityValidEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=GetCallerIdentity&Version=2011-06-15",
+	},
+	Response: &MockResponse{
+		StatusCode:  200,
+		Body:        MockStsGetCallerIdentityValidResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+var MockStsGetCallerIdentityUnauthorizedEndpoint = &MockEndpoint{
+	Request: &MockRequest{
+		Method: "POST",
+		Uri:    "/",
+		Body:   "Action=GetCallerIdentity&Version=2011-06-15",
+	},
+	Response: &MockResponse{
+		StatusCode:  403,
+		Body:        MockStsGetCallerIdentityUnauthorizedResponseBody,
+		ContentType: "text/xml",
+	},
+}
+
+// AssumeRoleResponse builds sts:AssumeRole mock responses. Zero values are
+// replaced with defaults matching MockStsAssumeRoleValidEndpoint.
+type AssumeRoleResponse struct {
+	RoleArn         string
+	RoleSessionName string
+	DurationSeconds int
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// Body returns the XML response body.
+func (r AssumeRoleResponse) Body() string {
+	r = r.withDefaults()
+
+	assumedRoleArn := r.RoleArn
+	roleName := r.RoleArn
+	if parsedArn, err := arn.Parse(r.RoleArn); err == nil {
+		roleName = parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+		assumedRoleArn = arn.ARN{
+			Partition: parsedArn.Partition,
+			Service:   "sts",
+			AccountID: parsedArn.AccountID,
+			Resource:  fmt.Sprintf("assumed-role/%s/%s", roleName, r.RoleSessionName),
+		}.String()
+	}
+
+	return fmt.Sprintf(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <AssumedRoleUser>
+      <Arn>%s</Arn>
+      <AssumedRoleId>ARO123EXAMPLE123:%s</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>%s</AccessKeyId>
+      <SecretAccessKey>%s</SecretAccessKey>
+      <SessionToken>%s</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`,
+		xmlEscape(assumedRoleArn), xmlEscape(r.RoleSessionName), xmlEscape(r.AccessKeyID),
+		xmlEscape(r.SecretAccessKey), xmlEscape(r.SessionToken), r.Expiration.UTC().Format(time.RFC3339))
+}
+
+// Endpoint returns a mock endpoint matching the sts:AssumeRole request made by
+// the AWS Go SDK for the role ARN, session name, and duration.
+func (r AssumeRoleResponse) Endpoint() *MockEndpoint {
+	r = r.withDefaults()
+
+	return &MockEndpoint{
+		Request: &MockRequest{
+			Method: "POST",
+			Uri:    "/",
+			Body: url.Values{
+				"Action":          []string{"AssumeRole"},
+				"DurationSeconds": []string{strconv.Itoa(r.DurationSeconds)},
+				"RoleArn":         []string{r.RoleArn},
+				"RoleSessionName": []string{r.RoleSessionName},
+				"Version":         []string{"2011-06-15"},
+			}.Encode(),
+		},
+		Response: &MockResponse{
+			StatusCode:  200,
+			Body:        r.Body(),
+			ContentType: "text/xml",
+		},
+	}
+}
+
+func (r AssumeRoleResponse) withDefaults() AssumeRoleResponse {
+	if r.RoleArn == "" {
+		r.RoleArn = MockStsAssumeRoleArn
+	}
+	if r.RoleSessionName == "" {
+		r.RoleSessionName = MockStsAssumeRoleSessionName
+	}
+	if r.DurationSeconds == 0 {
+		r.DurationSeconds = 900
+	}
+	if r.AccessKeyID == "" {
+		r.AccessKeyID = MockStsAssumeRoleAccessKey
+	}
+	if r.SecretAccessKey == "" {
+		r.SecretAccessKey = MockStsAssumeRoleSecretKey
+	}
+	if r.SessionToken == "" {
+		r.SessionToken = MockStsAssumeRoleSessionToken
+	}
+	if r.Expiration.IsZero() {
+		r.Expiration = time.Now().Add(time.Duration(r.DurationSeconds) * time.Second)
+	}
+	return r
+}
+
+// GetCallerIdentityResponse builds sts:GetCallerIdentity mock responses. Zero
+// values are replaced with defaults matching MockStsGetCallerIdentityValidEndpoint.
+type GetCallerIdentityResponse struct {
+	Account string
+	Arn     string
+	UserID  string
+}
+
+// Body returns the XML response body.
+func (r GetCallerIdentityResponse) Body() string {
+	r = r.withDefaults()
+
+	return fmt.Sprintf(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>%s</Arn>
+    <UserId>%s</UserId>
+    <Account>%s</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</GetCallerIdentityResponse>`, xmlEscape(r.Arn), xmlEscape(r.UserID), xmlEscape(r.Account))
+}
+
+// Endpoint returns a mock endpoint matching the sts:GetCallerIdentity request
+// made by the AWS Go SDK.
+func (r GetCallerIdentityResponse) Endpoint() *MockEndpoint {
+	return &MockEndpoint{
+		Request: &MockRequest{
+			Method: "POST",
+			Uri:    "/",
+			Body:   "Action=GetCallerIdentity&Version=2011-06-15",
+		},
+		Response: &MockResponse{
+			StatusCode:  200,
+			Body:        r.Body(),
+			ContentType: "text/xml",
+		},
+	}
+}
+
+func (r GetCallerIdentityResponse) withDefaults() GetCallerIdentityResponse {
+	if r.Account == "" && r.Arn != "" {
+		if parsedArn, err := arn.Parse(r.Arn); err == nil {
+			r.Account = parsedArn.AccountID
+		}
+	}
+	if r.Account == "" {
+		r.Account = MockStsGetCallerIdentityAccountID
+	}
+	if r.Arn == "" {
+		r.Arn = fmt.Sprintf("arn:aws:iam::%s:user/Alice", r.Account)
+	}
+	if r.UserID == "" {
+		r.UserID = "AKIAI44QH8DHBEXAMPLE"
+	}
+	return r
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package awsmocks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestAssumeRoleResponse(t *testing.T) {
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	response := AssumeRoleResponse{
+		RoleArn:         "arn:aws-cn:iam::666666666666:role/Example",
+		RoleSessionName: "ExampleSession",
+		DurationSeconds: 3600,
+		AccessKeyID:     "ExampleAccessKey",
+		Expiration:      expiration,
+	}
+
+	ts := NewServer("STS", []*MockEndpoint{response.Endpoint()})
+	defer ts.Close()
+
+	output, err := newStsClient(t, ts.URL).AssumeRole(&sts.AssumeRoleInput{
+		DurationSeconds: aws.Int64(3600),
+		RoleArn:         aws.String(response.RoleArn),
+		RoleSessionName: aws.String(response.RoleSessionName),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if expected, actual := "arn:aws-cn:sts::666666666666:assumed-role/Example/ExampleSession", aws.StringValue(output.AssumedRoleUser.Arn); actual != expected {
+		t.Errorf("Expected assumed role ARN %q, got %q", expected, actual)
+	}
+	if expected, actual := "ExampleAccessKey", aws.StringValue(output.Credentials.AccessKeyId); actual != expected {
+		t.Errorf("Expected access key %q, got %q", expected, actual)
+	}
+	if expected, actual := MockStsAssumeRoleSecretKey, aws.StringValue(output.Credentials.SecretAccessKey); actual != expected {
+		t.Errorf("Expected secret key %q, got %q", expected, actual)
+	}
+	if actual := aws.TimeValue(output.Credentials.Expiration); !actual.Equal(expiration) {
+		t.Errorf("Expected expiration %s, got %s", expiration, actual)
+	}
+}
+
+func TestGetCallerIdentityResponse(t *testing.T) {
+	response := GetCallerIdentityResponse{
+		Arn: "arn:aws-us-gov:iam::777777777777:user/Bob",
+	}
+
+	ts := NewServer("STS", []*MockEndpoint{response.Endpoint()})
+	defer ts.Close()
+
+	output, err := newStsClient(t, ts.URL).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if expected, actual := "777777777777", aws.StringValue(output.Account); actual != expected {
+		t.Errorf("Expected account %q, got %q", expected, actual)
+	}
+	if expected, actual := response.Arn, aws.StringValue(output.Arn); actual != expected {
+		t.Errorf("Expected ARN %q, got %q", expected, actual)
+	}
+}
+
+func newStsClient(t *testing.T, endpoint string) *sts.STS {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String("us-east-1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sts.New(sess)
+}
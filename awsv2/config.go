@@ -0,0 +1,599 @@
+package awsv2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	awsbase "github.com/hashicorp/aws-sdk-go-base"
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// GetAwsConfig attempts to return a valid AWS Go SDK v2 configuration,
+// resolving credentials from static credentials, environment variables,
+// shared configuration profiles, web identity, and the container and EC2
+// metadata API credential endpoints, and assuming roles, as
+// awsbase.GetSession does. The DynamoDB, IAM, S3, and STS endpoints of the
+// Config apply to clients built from the configuration.
+//
+// The other credential sources of awsbase.GetSession are not supported, and
+// an error is returned if any of them is configured, so that the identity
+// doesn't differ from that of the session: CredentialSourceOrder,
+// StrictCredentialSources, CredentialProcess, WatchedCredsFilename, the
+// keychain, CredentialsProviderFunc, RolesAnywhere, and FederationToken.
+//
+// Settings specific to AWS Go SDK v1 sessions are not applied, e.g.
+// EndpointResolver, the S3 client settings other than S3UseARNRegion, the
+// signing overrides such as StsSigningRegion, and request handlers such as
+// OnRequest, Metrics, and TracerProvider.
+func GetAwsConfig(ctx context.Context, c *awsbase.Config) (aws.Config, error) {
+	c, err := awsbase.ExpandConfigEnv(c)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if c.Region != "" {
+		if err := awsbase.ValidateRegionFormat(c.Region); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	if err := c.CheckStaticCredentials(); err != nil {
+		return aws.Config{}, err
+	}
+
+	if settings := unsupportedCredentialSettings(c); len(settings) > 0 {
+		return aws.Config{}, fmt.Errorf("credential settings not supported for AWS Go SDK v2 configuration: %s", strings.Join(settings, ", "))
+	}
+
+	httpClient := c.HTTPClient
+	var buildableClient *awshttp.BuildableClient
+	if httpClient == nil && caBundleConfigured(ctx, c) {
+		// The AWS Go SDK v2 only adds CA bundles, e.g. of AWS_CA_BUNDLE, to
+		// buildable HTTP clients.
+		var transportErr error
+		buildableClient = awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+			transportErr = configureTransport(c, transport)
+			if c.Insecure {
+				if transport.TLSClientConfig == nil {
+					transport.TLSClientConfig = &tls.Config{}
+				}
+				transport.TLSClientConfig.InsecureSkipVerify = true
+			}
+		})
+		if transportErr != nil {
+			return aws.Config{}, transportErr
+		}
+	} else if httpClient == nil {
+		httpClient = cleanhttp.DefaultClient()
+		if err := configureTransport(c, httpClient.Transport.(*http.Transport)); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	if c.Insecure && httpClient != nil {
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if ok {
+			// Clone the transport, as it may be shared with the caller.
+			transport = transport.Clone()
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.InsecureSkipVerify = true
+
+			insecureClient := *httpClient
+			insecureClient.Transport = transport
+			httpClient = &insecureClient
+		} else {
+			awsbase.Logf("[WARN] Unable to disable TLS certificate verification for HTTP client transport %T", httpClient.Transport)
+		}
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(c.Region),
+		config.WithAPIOptions(userAgentOptions(c.UserAgentProducts)),
+	}
+
+	if buildableClient != nil {
+		loadOptions = append(loadOptions, config.WithHTTPClient(buildableClient))
+	} else {
+		loadOptions = append(loadOptions, config.WithHTTPClient(httpClient))
+	}
+
+	if c.AccessKey != "" || c.SecretKey != "" {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, c.Token),
+		))
+	}
+
+	if c.Profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(c.Profile))
+	}
+
+	if c.IgnoreSharedCredentialsFile {
+		// An empty list of files, unlike none, overrides the default file.
+		loadOptions = append(loadOptions, config.WithSharedCredentialsFiles([]string{}))
+	} else if filenames := awsbase.ResolveSharedCredentialsFilenames(c); len(filenames) > 0 {
+		loadOptions = append(loadOptions, config.WithSharedCredentialsFiles(filenames))
+	}
+
+	if resolver := endpointResolver(c); resolver != nil {
+		loadOptions = append(loadOptions, config.WithEndpointResolverWithOptions(resolver))
+	}
+
+	if c.DefaultsMode != "" {
+		loadOptions = append(loadOptions, config.WithDefaultsMode(aws.DefaultsMode(c.DefaultsMode)))
+	}
+
+	if c.MaxRetries > 0 {
+		loadOptions = append(loadOptions, config.WithRetryMaxAttempts(c.MaxRetries+1))
+	}
+
+	if c.RetryQuota != nil {
+		loadOptions = append(loadOptions, config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.RateLimiter = c.RetryQuota
+			})
+		}))
+	}
+
+	if c.SkipMetadataApiCheck {
+		loadOptions = append(loadOptions, config.WithEC2IMDSClientEnableState(imds.ClientDisabled))
+	} else if endpoint := os.Getenv("AWS_METADATA_URL"); endpoint != "" {
+		awsbase.Logf("[INFO] Setting custom metadata endpoint: %q", endpoint)
+		loadOptions = append(loadOptions, config.WithEC2IMDSEndpoint(endpoint))
+	}
+
+	if c.S3UseARNRegion {
+		loadOptions = append(loadOptions, config.WithS3UseARNRegion(true))
+	}
+
+	if c.DebugLogging {
+		loadOptions = append(loadOptions,
+			config.WithClientLogMode(aws.LogRequestWithBody|aws.LogResponseWithBody|aws.LogRetries),
+			config.WithLogger(DebugLogger{}),
+		)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("Error loading AWS configuration: %w", err)
+	}
+
+	if buildableClient, ok := cfg.HTTPClient.(*awshttp.BuildableClient); ok {
+		// Service clients would replace the dialer of a buildable client, e.g.
+		// the one configured by DialContext.
+		cfg.HTTPClient = buildableClient.Freeze()
+	}
+	if client, ok := cfg.HTTPClient.(*http.Client); ok {
+		httpClient = client
+	}
+
+	stsHTTPClient, err := stsHTTPClient(httpClient, c)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	// The credentials of environment variables are only used if neither
+	// static credentials nor a profile are configured.
+	if c.IgnoreEnvCredentials && envCredentialsSet() && c.AccessKey == "" && c.SecretKey == "" && c.Profile == "" {
+		provider, err := credentialsWithoutEnv(ctx, cfg, c, stsHTTPClient, loadOptions)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("Error loading AWS configuration: %w", err)
+		}
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	cp, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("Error loading credentials for AWS Provider: %w", err)
+	}
+
+	awsbase.Logf("[INFO] AWS Auth provider used: %q", cp.Source)
+
+	if assumeRole := awsbase.ResolveAssumeRole(c); assumeRole != nil {
+		if err := assumeRole.Validate(); err != nil {
+			return aws.Config{}, fmt.Errorf("invalid assume role configuration: %w", err)
+		}
+
+		awsbase.Logf("[INFO] Attempting to AssumeRole %s (SessionName: %q, ExternalId: %q, Policy: %q)",
+			assumeRole.RoleARN, assumeRole.SessionName, assumeRole.ExternalID, assumeRole.Policy)
+
+		stsClient := stsClient(cfg, c, stsHTTPClient, func(o *sts.Options) {
+			if assumeRole.Region != "" {
+				o.Region = assumeRole.Region
+			}
+			if assumeRole.StsEndpoint != "" {
+				o.BaseEndpoint = aws.String(assumeRole.StsEndpoint)
+			}
+		})
+		assumeRoleProvider := stscreds.NewAssumeRoleProvider(stsClient, assumeRole.RoleARN, assumeRoleOptions(assumeRole))
+		cfg.Credentials = aws.NewCredentialsCache(assumeRoleProvider, credentialsCacheOptions(assumeRole))
+
+		_, err := cfg.Credentials.Retrieve(ctx)
+		if err != nil && assumeRole.TagsOptional && len(assumeRole.Tags) > 0 && tagSessionDenied(err) {
+			awsbase.Logf("[WARN] Session tags denied assuming role %s (sts:TagSession), assuming role without session tags", assumeRole.RoleARN)
+
+			untaggedAssumeRole := *assumeRole
+			untaggedAssumeRole.Tags = nil
+			untaggedAssumeRole.TransitiveTagKeys = nil
+
+			assumeRoleProvider = stscreds.NewAssumeRoleProvider(stsClient, assumeRole.RoleARN, assumeRoleOptions(&untaggedAssumeRole))
+			cfg.Credentials = aws.NewCredentialsCache(assumeRoleProvider, credentialsCacheOptions(assumeRole))
+			_, err = cfg.Credentials.Retrieve(ctx)
+		}
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("The role %q cannot be assumed: %w", assumeRole.RoleARN, err)
+		}
+	}
+
+	if !c.SkipCredsValidation {
+		if _, err := stsClient(cfg, c, stsHTTPClient).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+			return aws.Config{}, fmt.Errorf("error validating provider credentials: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// unsupportedCredentialSettings returns the names of the credential settings
+// of the Config which GetAwsConfig doesn't support.
+func unsupportedCredentialSettings(c *awsbase.Config) []string {
+	var settings []string
+	if len(c.CredentialSourceOrder) > 0 {
+		settings = append(settings, "CredentialSourceOrder")
+	}
+	if c.StrictCredentialSources {
+		settings = append(settings, "StrictCredentialSources")
+	}
+	if c.CredentialProcess != nil {
+		settings = append(settings, "CredentialProcess")
+	}
+	if c.WatchedCredsFilename != "" {
+		settings = append(settings, "WatchedCredsFilename")
+	}
+	if c.KeychainService != "" {
+		settings = append(settings, "KeychainService")
+	}
+	if c.CredentialsProviderFunc != nil {
+		settings = append(settings, "CredentialsProviderFunc")
+	}
+	if c.RolesAnywhere != nil {
+		settings = append(settings, "RolesAnywhere")
+	}
+	if c.FederationToken != nil {
+		settings = append(settings, "FederationToken")
+	}
+	return settings
+}
+
+// credentialsWithoutEnv returns a provider of the credentials the AWS Go SDK v2
+// resolves when no credentials are set by environment variables: those of web
+// identity federation configured by environment variables, then those of the
+// shared configuration profile if it exists, or else of the container and EC2
+// metadata API credential endpoints.
+func credentialsWithoutEnv(ctx context.Context, cfg aws.Config, c *awsbase.Config, stsHTTPClient *http.Client, loadOptions []func(*config.LoadOptions) error) (aws.CredentialsProvider, error) {
+	var providers chainProvider
+
+	if tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && roleARN != "" {
+		providers = append(providers, stscreds.NewWebIdentityRoleProvider(stsClient(cfg, c, stsHTTPClient), roleARN, stscreds.IdentityTokenFile(tokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+		}))
+	}
+
+	// A selected profile takes precedence over the credentials of
+	// environment variables, and falls back to the credential endpoints
+	// itself.
+	profileCfg, err := config.LoadDefaultConfig(ctx, append(loadOptions, config.WithSharedConfigProfile(envProfile()))...)
+	var notExistErr config.SharedConfigProfileNotExistError
+	switch {
+	case err == nil:
+		return append(providers, profileCfg.Credentials), nil
+	case !errors.As(err, &notExistErr):
+		return nil, err
+	}
+
+	if endpoint := containerCredentialsEndpoint(); endpoint != "" {
+		providers = append(providers, endpointcreds.New(endpoint, func(o *endpointcreds.Options) {
+			o.AuthorizationToken = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+		}))
+	}
+	if !c.SkipMetadataApiCheck {
+		providers = append(providers, ec2rolecreds.New(func(o *ec2rolecreds.Options) {
+			o.Client = imds.NewFromConfig(cfg)
+		}))
+	}
+	return providers, nil
+}
+
+// containerCredentialsEndpoint returns the container credentials endpoint of
+// the AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+// AWS_CONTAINER_CREDENTIALS_FULL_URI environment variables, or an empty
+// string if neither is set.
+func containerCredentialsEndpoint() string {
+	if relativeURI := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relativeURI != "" {
+		return "http://169.254.170.2" + relativeURI
+	}
+	return os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+}
+
+// chainProvider retrieves credentials from the first of its providers which
+// has them.
+type chainProvider []aws.CredentialsProvider
+
+func (p chainProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var errs []error
+	for _, provider := range p {
+		creds, err := provider.Retrieve(ctx)
+		if err == nil {
+			return creds, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return aws.Credentials{}, errors.New("no credential providers")
+	}
+	return aws.Credentials{}, errors.Join(errs...)
+}
+
+// credentialsCacheOptions returns a function which applies the expiry window
+// of the given AssumeRole settings to the options of a credentials cache.
+func credentialsCacheOptions(r *awsbase.AssumeRole) func(*aws.CredentialsCacheOptions) {
+	return func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = r.ResolveExpiryWindow()
+	}
+}
+
+// assumeRoleOptions returns a function which applies the given AssumeRole
+// settings to the options of an AssumeRole credentials provider.
+func assumeRoleOptions(r *awsbase.AssumeRole) func(*stscreds.AssumeRoleOptions) {
+	return func(o *stscreds.AssumeRoleOptions) {
+		if r.Duration > 0 {
+			o.Duration = r.Duration
+		}
+		if r.ExternalID != "" {
+			o.ExternalID = aws.String(r.ExternalID)
+		}
+		if r.MFASerialNumber != "" {
+			o.SerialNumber = aws.String(r.MFASerialNumber)
+			o.TokenProvider = r.MFATokenProvider
+		}
+		if r.Policy != "" {
+			o.Policy = aws.String(r.Policy)
+		}
+		for _, policyARN := range r.PolicyARNs {
+			o.PolicyARNs = append(o.PolicyARNs, types.PolicyDescriptorType{Arn: aws.String(policyARN)})
+		}
+		if r.SessionName != "" {
+			o.RoleSessionName = r.SessionName
+		}
+		if r.SourceIdentity != "" {
+			o.SourceIdentity = aws.String(r.SourceIdentity)
+		}
+
+		// Sort the tag keys so that requests are deterministic.
+		tagKeys := make([]string, 0, len(r.Tags))
+		for key := range r.Tags {
+			tagKeys = append(tagKeys, key)
+		}
+		sort.Strings(tagKeys)
+		for _, key := range tagKeys {
+			o.Tags = append(o.Tags, types.Tag{Key: aws.String(key), Value: aws.String(r.Tags[key])})
+		}
+		o.TransitiveTagKeys = r.TransitiveTagKeys
+	}
+}
+
+// configureTransport configures the given transport, built by this package,
+// with the proxy, defaults mode timeouts, and dialer of the Config.
+func configureTransport(c *awsbase.Config, transport *http.Transport) error {
+	proxy, err := awsbase.NewProxyFunc(c)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = proxy
+	if c.ProxyNegotiateTokenProvider != nil {
+		transport.GetProxyConnectHeader = awsbase.ProxyNegotiateConnectHeader(c.ProxyNegotiateTokenProvider)
+	}
+	if c.DefaultsMode != "" && c.DefaultsMode != awsbase.DefaultsModeLegacy {
+		if err := configureDefaultsMode(aws.DefaultsMode(c.DefaultsMode), transport); err != nil {
+			return err
+		}
+	}
+	return configureDialer(c, transport)
+}
+
+// caBundleConfigured returns whether a CA bundle is configured for the AWS Go
+// SDK v2, by the AWS_CA_BUNDLE environment variable or the ca_bundle setting
+// of the shared configuration profile.
+func caBundleConfigured(ctx context.Context, c *awsbase.Config) bool {
+	if os.Getenv("AWS_CA_BUNDLE") != "" {
+		return true
+	}
+
+	profile := c.Profile
+	if profile == "" {
+		profile = envProfile()
+	}
+	sharedConfig, err := config.LoadSharedConfigProfile(ctx, profile)
+	return err == nil && sharedConfig.CustomCABundle != ""
+}
+
+// configureDialer configures the given transport, built by this package, to
+// dial connections as configured by the Config, with the connect timeout of
+// its defaults mode, and to authenticate to HTTPS proxies with its proxy
+// client certificate.
+func configureDialer(c *awsbase.Config, transport *http.Transport) error {
+	var connectTimeout time.Duration
+	if c.DefaultsMode != "" && c.DefaultsMode != awsbase.DefaultsModeLegacy {
+		if settings, err := defaults.GetModeConfiguration(aws.DefaultsMode(c.DefaultsMode)); err == nil {
+			connectTimeout, _ = settings.GetConnectTimeout()
+		}
+	}
+
+	dial, err := awsbase.NewDialContext(c, connectTimeout)
+	if err != nil {
+		return err
+	}
+	if dial != nil {
+		transport.DialContext = dial
+	}
+	return awsbase.ConfigureProxyClientCertificate(c, transport)
+}
+
+// configureDefaultsMode configures the given transport, built by this package,
+// with the timeouts of the defaults mode, which the AWS Go SDK v2 only applies
+// to HTTP clients it builds itself.
+func configureDefaultsMode(mode aws.DefaultsMode, transport *http.Transport) error {
+	settings, err := defaults.GetModeConfiguration(mode)
+	if err != nil {
+		return fmt.Errorf("invalid defaults mode %q", mode)
+	}
+
+	if connectTimeout, ok := settings.GetConnectTimeout(); ok {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if tlsNegotiationTimeout, ok := settings.GetTLSNegotiationTimeout(); ok {
+		transport.TLSHandshakeTimeout = tlsNegotiationTimeout
+	}
+
+	return nil
+}
+
+// tagSessionDenied returns whether the error is an sts:AssumeRole error due to
+// sts:TagSession being denied.
+func tagSessionDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied" && strings.Contains(apiErr.ErrorMessage(), "sts:TagSession")
+}
+
+// stsHTTPClient returns the HTTP client for STS clients, which presents the
+// STS client certificate of the Config, if any, and times out calls after the
+// STS call timeout of the Config.
+func stsHTTPClient(httpClient *http.Client, c *awsbase.Config) (*http.Client, error) {
+	if c.StsClientCertFilename != "" || c.StsClientKeyFilename != "" {
+		if c.StsClientCertFilename == "" || c.StsClientKeyFilename == "" {
+			return nil, errors.New("both STS client certificate and key files must be set")
+		}
+
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("error configuring STS client: unable to configure client certificate for HTTP client transport %T", httpClient.Transport)
+		}
+
+		certificate, err := tls.LoadX509KeyPair(c.StsClientCertFilename, c.StsClientKeyFilename)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring STS client: error loading client certificate: %w", err)
+		}
+
+		transport = transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+
+		certificateClient := *httpClient
+		certificateClient.Transport = transport
+		httpClient = &certificateClient
+	}
+
+	if c.StsCallTimeout > 0 {
+		timeoutClient := *httpClient
+		timeoutClient.Timeout = c.StsCallTimeout
+		httpClient = &timeoutClient
+	}
+
+	return httpClient, nil
+}
+
+func stsClient(cfg aws.Config, c *awsbase.Config, httpClient *http.Client, optFns ...func(*sts.Options)) *sts.Client {
+	optFns = append([]func(*sts.Options){func(o *sts.Options) {
+		// The endpoint resolver of the configuration would take precedence
+		// over the StsEndpoint of AssumeRole.
+		o.EndpointResolver = nil
+		o.HTTPClient = httpClient
+		if c.StsEndpoint != "" {
+			o.BaseEndpoint = aws.String(c.StsEndpoint)
+		}
+	}}, optFns...)
+	return sts.NewFromConfig(cfg, optFns...)
+}
+
+// endpointResolver returns a resolver of the service endpoints of the Config,
+// e.g. IamEndpoint, or nil if none are set. Other services are resolved by the
+// AWS Go SDK v2.
+func endpointResolver(c *awsbase.Config) aws.EndpointResolverWithOptions {
+	// The keys are the service IDs of the AWS Go SDK v2 service clients.
+	endpoints := make(map[string]string)
+	for serviceID, endpoint := range map[string]string{
+		"DynamoDB":    c.DynamoDBEndpoint,
+		"IAM":         c.IamEndpoint,
+		"S3":          c.S3Endpoint,
+		sts.ServiceID: c.StsEndpoint,
+	} {
+		if endpoint != "" {
+			endpoints[serviceID] = endpoint
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		if endpoint, ok := endpoints[service]; ok {
+			return aws.Endpoint{URL: endpoint, SigningRegion: region}, nil
+		}
+		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+	})
+}
+
+func userAgentOptions(products []*awsbase.UserAgentProduct) []func(*middleware.Stack) error {
+	apiOptions := make([]func(*middleware.Stack) error, 0, len(products))
+	for _, product := range products {
+		apiOptions = append(apiOptions, awsmiddleware.AddUserAgentKeyValue(product.Name, product.Version))
+		for _, extra := range product.Extra {
+			apiOptions = append(apiOptions, awsmiddleware.AddUserAgentKey(extra))
+		}
+	}
+	return apiOptions
+}
+
+// envCredentialsSet returns whether credentials are set by environment
+// variables, as read by the AWS SDK.
+func envCredentialsSet() bool {
+	return os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_ACCESS_KEY") != ""
+}
+
+// envProfile returns the shared configuration profile of the AWS_PROFILE
+// environment variable, or the default profile.
+func envProfile() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
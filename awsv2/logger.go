@@ -0,0 +1,12 @@
+package awsv2
+
+import (
+	"github.com/aws/smithy-go/logging"
+	awsbase "github.com/hashicorp/aws-sdk-go-base"
+)
+
+type DebugLogger struct{}
+
+func (l DebugLogger) Logf(classification logging.Classification, format string, v ...interface{}) {
+	awsbase.Logf("[DEBUG] [aws-sdk-go-v2] "+format, v...)
+}
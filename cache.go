@@ -0,0 +1,212 @@
+package awsbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// memoizedCredentials caches the credentials built by GetCredentials for
+// Configs with MemoizeCredentials set, keyed by configHash, for the lifetime
+// of the process.
+var memoizedCredentials = struct {
+	sync.Mutex
+	entries map[string]memoizedCredentialsEntry
+}{entries: make(map[string]memoizedCredentialsEntry)}
+
+type memoizedCredentialsEntry struct {
+	creds *awsCredentials.Credentials
+	trail *CredentialsAuditTrail
+}
+
+func cachedCredentials(key string) (memoizedCredentialsEntry, bool) {
+	memoizedCredentials.Lock()
+	defer memoizedCredentials.Unlock()
+
+	entry, ok := memoizedCredentials.entries[key]
+	return entry, ok
+}
+
+func cacheCredentials(key string, creds *awsCredentials.Credentials, trail *CredentialsAuditTrail) {
+	memoizedCredentials.Lock()
+	defer memoizedCredentials.Unlock()
+
+	memoizedCredentials.entries[key] = memoizedCredentialsEntry{
+		creds: creds,
+		trail: trail,
+	}
+}
+
+// ResetCredentialsCache clears the credentials memoized for Configs with
+// MemoizeCredentials set, so that the next GetCredentials call resolves them
+// again, e.g. after the shared credentials file changed.
+func ResetCredentialsCache() {
+	memoizedCredentials.Lock()
+	defer memoizedCredentials.Unlock()
+
+	memoizedCredentials.entries = make(map[string]memoizedCredentialsEntry)
+}
+
+// memoizedSessions caches the sessions built by GetSession for Configs with
+// MemoizeSession set, keyed by configHash, for the lifetime of the process.
+var memoizedSessions = struct {
+	sync.Mutex
+	entries map[string]*session.Session
+}{entries: make(map[string]*session.Session)}
+
+func cachedSession(key string) (*session.Session, bool) {
+	memoizedSessions.Lock()
+	defer memoizedSessions.Unlock()
+
+	sess, ok := memoizedSessions.entries[key]
+	return sess, ok
+}
+
+func cacheSession(key string, sess *session.Session) {
+	memoizedSessions.Lock()
+	defer memoizedSessions.Unlock()
+
+	memoizedSessions.entries[key] = sess
+}
+
+// ResetSessionCache clears the sessions memoized for Configs with
+// MemoizeSession set, so that the next GetSession call builds them again.
+func ResetSessionCache() {
+	memoizedSessions.Lock()
+	defer memoizedSessions.Unlock()
+
+	memoizedSessions.entries = make(map[string]*session.Session)
+}
+
+// configHash returns a canonical hash of the Config and the AWS_ environment
+// variables, which also determine the credentials resolved for it. Pointers
+// to structs with only exported fields, e.g. AssumeRole, are hashed by value,
+// while other pointers, e.g. RetryQuota, are hashed by identity, as they
+// carry state. Functions cannot be compared, so false is returned for Configs
+// containing them.
+func configHash(c *Config) (string, bool) {
+	h := sha256.New()
+
+	if !writeCanonical(h, reflect.ValueOf(c)) {
+		return "", false
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "AWS_") {
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		fmt.Fprintf(h, "\n%s", kv)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// writeCanonical writes a canonical representation of the value, returning
+// false if it contains a function.
+func writeCanonical(w io.Writer, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		fmt.Fprint(w, "nil")
+	case reflect.Func:
+		if !v.IsNil() {
+			return false
+		}
+		fmt.Fprint(w, "nil")
+	case reflect.Ptr:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return true
+		}
+		if v.Elem().Kind() == reflect.Struct && exportedFieldsOnly(v.Elem().Type()) {
+			fmt.Fprint(w, "&")
+			return writeCanonical(w, v.Elem())
+		}
+		fmt.Fprintf(w, "%s(%#x)", v.Type(), v.Pointer())
+	case reflect.Chan, reflect.UnsafePointer:
+		fmt.Fprintf(w, "%s(%#x)", v.Type(), v.Pointer())
+	case reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return true
+		}
+		fmt.Fprintf(w, "%s:", v.Elem().Type())
+		return writeCanonical(w, v.Elem())
+	case reflect.Struct:
+		if !exportedFieldsOnly(v.Type()) {
+			fmt.Fprintf(w, "%#v", v.Interface())
+			return true
+		}
+		fmt.Fprintf(w, "%s{", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(w, "%s:", v.Type().Field(i).Name)
+			if !writeCanonical(w, v.Field(i)) {
+				return false
+			}
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "}")
+	case reflect.Map:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return true
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for _, key := range v.MapKeys() {
+			var b strings.Builder
+			if !writeCanonical(&b, key) {
+				return false
+			}
+			keys = append(keys, b.String())
+			values[b.String()] = v.MapIndex(key)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "map[")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s:", key)
+			if !writeCanonical(w, values[key]) {
+				return false
+			}
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return true
+		}
+		fmt.Fprint(w, "[")
+		for i := 0; i < v.Len(); i++ {
+			if !writeCanonical(w, v.Index(i)) {
+				return false
+			}
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	default:
+		fmt.Fprintf(w, "%#v", v.Interface())
+	}
+	return true
+}
+
+func exportedFieldsOnly(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package awsbase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func TestConfigHash(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	newConfig := func() *Config {
+		return &Config{
+			AccessKey: "StaticAccessKey",
+			AssumeRole: &AssumeRole{
+				RoleARN: "arn:aws:iam::555555555555:role/AssumeRole",
+				Tags:    map[string]string{"Project": "test", "Team": "test"},
+			},
+			Region:            "us-east-1",
+			SecretKey:         "StaticSecretKey",
+			ServiceMaxRetries: map[string]int{"s3": 5, "sts": 3},
+		}
+	}
+
+	hash := func(c *Config) string {
+		t.Helper()
+		key, ok := configHash(c)
+		if !ok {
+			t.Fatal("Expected Config to be hashed")
+		}
+		return key
+	}
+
+	key := hash(newConfig())
+	if other := hash(newConfig()); other != key {
+		t.Errorf("Expected equal Configs to have equal hashes")
+	}
+
+	c := newConfig()
+	c.AssumeRole.RoleARN = "arn:aws:iam::555555555555:role/Other"
+	if hash(c) == key {
+		t.Errorf("Expected Configs with different roles to have different hashes")
+	}
+
+	c = newConfig()
+	c.RetryQuota = NewRetryQuota(DefaultRetryQuotaCapacity)
+	other := newConfig()
+	other.RetryQuota = NewRetryQuota(DefaultRetryQuotaCapacity)
+	if hash(c) == hash(other) {
+		t.Errorf("Expected Configs with different retry quotas to have different hashes")
+	}
+
+	t.Setenv("AWS_PROFILE", "other")
+	if hash(newConfig()) == key {
+		t.Errorf("Expected Configs with different environments to have different hashes")
+	}
+
+	c = newConfig()
+	c.AssumeRole.MFATokenProvider = func() (string, error) { return "", nil }
+	if _, ok := configHash(c); ok {
+		t.Errorf("Expected Config with function not to be hashed")
+	}
+}
+
+func TestAWSGetCredentials_memoized(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+	defer ResetCredentialsCache()
+
+	newConfig := func() *Config {
+		return &Config{
+			AccessKey:            "StaticAccessKey",
+			MemoizeCredentials:   true,
+			SecretKey:            "StaticSecretKey",
+			SkipMetadataApiCheck: true,
+		}
+	}
+
+	creds, err := GetCredentials(newConfig())
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+
+	other, err := GetCredentials(newConfig())
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if other != creds {
+		t.Error("Expected memoized credentials to be returned")
+	}
+
+	ResetCredentialsCache()
+	other, err = GetCredentials(newConfig())
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if other == creds {
+		t.Error("Expected credentials to be resolved again after reset")
+	}
+
+	c := newConfig()
+	c.CredentialsProviderFunc = func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+		return awsCredentials.Value{}, time.Time{}, nil
+	}
+	creds, err = GetCredentials(c)
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if other, _ := GetCredentials(c); other == creds {
+		t.Error("Expected credentials of Config with function not to be memoized")
+	}
+}
+
+func TestGetSession_memoized(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+	defer ResetSessionCache()
+
+	newConfig := func() *Config {
+		return &Config{
+			AccessKey:            "StaticAccessKey",
+			MemoizeSession:       true,
+			Region:               "us-east-1",
+			SecretKey:            "StaticSecretKey",
+			SkipCredsValidation:  true,
+			SkipMetadataApiCheck: true,
+		}
+	}
+
+	sess, err := GetSession(newConfig())
+	if err != nil {
+		t.Fatalf("Error getting session: %s", err)
+	}
+
+	other, err := GetSession(newConfig())
+	if err != nil {
+		t.Fatalf("Error getting session: %s", err)
+	}
+	if other.Config.Credentials != sess.Config.Credentials {
+		t.Error("Expected copy of memoized session to be returned")
+	}
+
+	// Changes to the handlers of a returned session don't affect others.
+	sendHandlers := other.Handlers.Send.Len()
+	other.Handlers.Send.PushBackNamed(request.NamedHandler{Name: "test.Handler", Fn: func(*request.Request) {}})
+	other, err = GetSession(newConfig())
+	if err != nil {
+		t.Fatalf("Error getting session: %s", err)
+	}
+	if other.Handlers.Send.Len() != sendHandlers {
+		t.Errorf("Expected %d send handlers, got %d", sendHandlers, other.Handlers.Send.Len())
+	}
+
+	c := newConfig()
+	c.Region = "us-west-2"
+	if other, _ := GetSession(c); other.Config.Credentials == sess.Config.Credentials {
+		t.Error("Expected session of other Config not to be returned")
+	}
+
+	ResetSessionCache()
+	other, err = GetSession(newConfig())
+	if err != nil {
+		t.Fatalf("Error getting session: %s", err)
+	}
+	if other.Config.Credentials == sess.Config.Credentials {
+		t.Error("Expected session to be built again after reset")
+	}
+
+	c = newConfig()
+	c.OnRequest = func(*request.Request) {}
+	sess, err = GetSession(c)
+	if err != nil {
+		t.Fatalf("Error getting session: %s", err)
+	}
+	if other, _ := GetSession(c); other.Config.Credentials == sess.Config.Credentials {
+		t.Error("Expected session of Config with function not to be memoized")
+	}
+}
@@ -0,0 +1,64 @@
+package awsbase
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ClientConfig returns the configuration of clients of the service with the
+// given endpoints ID, e.g. s3.EndpointsID, with the settings of the Config for
+// the service, such as its endpoint, applied. Signing overrides are applied by
+// the handlers of the session, built by GetSession from the Config, whose HTTP
+// client is the base of service-specific HTTP clients.
+//
+// Clients built with the session and the configuration, e.g.
+// ec2.New(sess, config), are consistent with those used by this package.
+func (c *Config) ClientConfig(sess *session.Session, serviceKey string) (*aws.Config, error) {
+	c, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch serviceKey {
+	case dynamodb.EndpointsID:
+		return endpointConfig(c.DynamoDBEndpoint), nil
+	case iam.EndpointsID:
+		return endpointConfig(c.IamEndpoint), nil
+	case s3.EndpointsID:
+		return s3Config(c), nil
+	case sts.EndpointsID:
+		return stsConfig(c, sess.Config.HTTPClient)
+	default:
+		return &aws.Config{}, nil
+	}
+}
+
+// NewClient returns a client of the service with the given endpoints ID, built
+// by the constructor of the service package, e.g.
+//
+//	conn, err := awsbase.NewClient(sess, c, ec2.EndpointsID, ec2.New)
+//
+// with the configuration returned by ClientConfig.
+func NewClient[T any](sess *session.Session, c *Config, serviceKey string, newClient func(client.ConfigProvider, ...*aws.Config) T) (T, error) {
+	config, err := c.ClientConfig(sess, serviceKey)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return newClient(sess, config), nil
+}
+
+// endpointConfig returns a configuration with the given endpoint, if set.
+func endpointConfig(endpoint string) *aws.Config {
+	config := &aws.Config{}
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	return config
+}
@@ -0,0 +1,165 @@
+package awsbase
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestConfigClientConfig(t *testing.T) {
+	t.Setenv("TEST_ENDPOINT_DOMAIN", "example.com")
+	t.Setenv("TEST_LITERAL_ENDPOINT", "https://sts.${TEST_ENDPOINT_DOMAIN}")
+
+	testCases := []struct {
+		Description      string
+		Config           *Config
+		ServiceKey       string
+		ExpectedEndpoint string
+	}{
+		{
+			Description: "no endpoint",
+			Config:      &Config{},
+			ServiceKey:  iam.EndpointsID,
+		},
+		{
+			Description: "dynamodb endpoint",
+			Config: &Config{
+				DynamoDBEndpoint: "http://localhost:8000",
+			},
+			ServiceKey:       dynamodb.EndpointsID,
+			ExpectedEndpoint: "http://localhost:8000",
+		},
+		{
+			Description: "iam endpoint",
+			Config: &Config{
+				IamEndpoint: "https://iam.example.com",
+			},
+			ServiceKey:       iam.EndpointsID,
+			ExpectedEndpoint: "https://iam.example.com",
+		},
+		{
+			Description: "sts endpoint",
+			Config: &Config{
+				StsEndpoint: "https://sts.example.com",
+			},
+			ServiceKey:       sts.EndpointsID,
+			ExpectedEndpoint: "https://sts.example.com",
+		},
+		{
+			Description: "dynamodb endpoint from environment variable",
+			Config: &Config{
+				DynamoDBEndpoint: "http://${TEST_ENDPOINT_DOMAIN}:8000",
+			},
+			ServiceKey:       dynamodb.EndpointsID,
+			ExpectedEndpoint: "http://example.com:8000",
+		},
+		{
+			Description: "iam endpoint from environment variable",
+			Config: &Config{
+				IamEndpoint: "https://iam.${TEST_ENDPOINT_DOMAIN}",
+			},
+			ServiceKey:       iam.EndpointsID,
+			ExpectedEndpoint: "https://iam.example.com",
+		},
+		{
+			Description: "s3 endpoint from environment variable",
+			Config: &Config{
+				S3Endpoint: "https://s3.${TEST_ENDPOINT_DOMAIN}",
+			},
+			ServiceKey:       s3.EndpointsID,
+			ExpectedEndpoint: "https://s3.example.com",
+		},
+		{
+			Description: "sts endpoint from environment variable",
+			Config: &Config{
+				StsEndpoint: "https://sts.${TEST_ENDPOINT_DOMAIN}",
+			},
+			ServiceKey:       sts.EndpointsID,
+			ExpectedEndpoint: "https://sts.example.com",
+		},
+		{
+			Description: "environment variable containing a reference",
+			Config: &Config{
+				StsEndpoint: "${TEST_LITERAL_ENDPOINT}",
+			},
+			ServiceKey:       sts.EndpointsID,
+			ExpectedEndpoint: "https://sts.${TEST_ENDPOINT_DOMAIN}",
+		},
+		{
+			Description: "other service",
+			Config: &Config{
+				IamEndpoint: "https://iam.example.com",
+			},
+			ServiceKey: ec2.EndpointsID,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Region:      aws.String("us-west-2"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := testCase.Config.ClientConfig(sess, testCase.ServiceKey)
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if endpoint := aws.StringValue(config.Endpoint); endpoint != testCase.ExpectedEndpoint {
+				t.Errorf("Expected endpoint %q, got %q", testCase.ExpectedEndpoint, endpoint)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Region:      aws.String("us-west-2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := NewClient(sess, &Config{IamEndpoint: "https://iam.example.com"}, iam.EndpointsID, iam.New)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if conn.Endpoint != "https://iam.example.com" {
+		t.Errorf("Expected endpoint %q, got %q", "https://iam.example.com", conn.Endpoint)
+	}
+
+	if _, err := NewClient(sess, &Config{StsClientCertFilename: "cert.pem"}, sts.EndpointsID, sts.New); err == nil {
+		t.Error("Expected error for incomplete STS client certificate, received none")
+	}
+
+	t.Setenv("TEST_LITERAL_ENDPOINT", "https://sts.${TEST_ENDPOINT_DOMAIN}")
+	stsConn, err := NewClient(sess, &Config{StsEndpoint: "${TEST_LITERAL_ENDPOINT}"}, sts.EndpointsID, sts.New)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if stsConn.Endpoint != "https://sts.${TEST_ENDPOINT_DOMAIN}" {
+		t.Errorf("Expected endpoint %q, got %q", "https://sts.${TEST_ENDPOINT_DOMAIN}", stsConn.Endpoint)
+	}
+
+	os.Unsetenv("TEST_UNSET_ENDPOINT")
+	if _, err := NewClient(sess, &Config{IamEndpoint: "${TEST_UNSET_ENDPOINT}"}, iam.EndpointsID, iam.New); err == nil {
+		t.Error("Expected error for unset environment variable, received none")
+	}
+}
@@ -0,0 +1,55 @@
+package awsbase
+
+import (
+	"time"
+)
+
+// Clock provides the current time to credential expiry, audit, and request
+// signing logic. Set Config.Clock, or the Clock of a credentials provider, to
+// simulate the passage of time in tests, e.g. to expire credentials without
+// sleeping. Clocks which also implement TimerClock time the waits of this
+// package, too.
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock which also provides the timers of waits, e.g. between
+// retries and background credential refreshes, so that simulating the passage
+// of time ends them, too. Waits use system timers with other Clocks.
+type TimerClock interface {
+	Clock
+	// NewTimer returns a channel which receives the time once the duration
+	// has passed, and a function which stops the timer, as time.NewTimer.
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+// SystemClock is the Clock used when none is set, returning the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// resolveClock returns the given Clock, or SystemClock if it is nil.
+func resolveClock(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// newTimer returns a timer of the given Clock if it is a TimerClock, or else a
+// system timer.
+func newTimer(clock Clock, d time.Duration) (<-chan time.Time, func() bool) {
+	if timerClock, ok := clock.(TimerClock); ok {
+		return timerClock.NewTimer(d)
+	}
+	return systemClock{}.NewTimer(d)
+}
@@ -0,0 +1,174 @@
+package awsbase
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// testTimerClock is a TimerClock whose timers fire immediately, recording the
+// durations they were started with.
+type testTimerClock struct {
+	testClock
+
+	mu        sync.Mutex
+	durations []time.Duration
+}
+
+func (c *testTimerClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.durations = append(c.durations, d)
+	timer := make(chan time.Time, 1)
+	timer <- c.now.Add(d)
+	return timer, func() bool { return false }
+}
+
+func (c *testTimerClock) Durations() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]time.Duration(nil), c.durations...)
+}
+
+func TestAWSGetCredentials_clock(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	expiration := clock.now.Add(time.Hour)
+
+	var calls int
+	cfg := Config{
+		Clock: clock,
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			calls++
+			return awsCredentials.Value{AccessKeyID: "funcAccessKey", SecretAccessKey: "funcSecretKey"}, expiration, nil
+		},
+		SkipMetadataApiCheck: true,
+	}
+
+	creds, trail, err := GetCredentialsWithAuditTrail(&cfg)
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+
+	if _, err := creds.Get(); err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if creds.IsExpired() {
+		t.Error("Expected credentials not to be expired")
+	}
+
+	clock.now = expiration
+	if !creds.IsExpired() {
+		t.Error("Expected credentials to be expired")
+	}
+
+	if _, err := creds.Get(); err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected credentials function to be called twice, got %d", calls)
+	}
+
+	attempts := trail.Attempts()
+	if last := attempts[len(attempts)-1]; !last.Time.Equal(expiration) {
+		t.Errorf("Expected attempt time %s, got %s", expiration, last.Time)
+	}
+}
+
+func TestSleep_timerClock(t *testing.T) {
+	clock := &testTimerClock{}
+
+	if !sleep(context.Background(), clock, time.Hour) {
+		t.Fatal("Expected sleep to complete")
+	}
+	if durations := clock.Durations(); len(durations) != 1 || durations[0] != time.Hour {
+		t.Errorf("Expected a timer of %s, got %v", time.Hour, durations)
+	}
+}
+
+func TestCredentialsRefresher_timerClock(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	clock := &testTimerClock{testClock: testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}}
+
+	var calls int32
+	refresher := &CredentialsRefresher{
+		Lead:          time.Minute,
+		RetryInterval: time.Second,
+	}
+	defer refresher.Stop()
+	config := &Config{
+		Clock: clock,
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			atomic.AddInt32(&calls, 1)
+			return awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"}, clock.now.Add(time.Hour), nil
+		},
+		CredentialsRefresher: refresher,
+		SkipMetadataApiCheck: true,
+	}
+
+	if _, err := GetCredentials(config); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	// The timers of the clock fire immediately, rather than a minute before
+	// the credentials expire.
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) >= 3 })
+
+	if durations := clock.Durations(); len(durations) == 0 || durations[0] != 59*time.Minute {
+		t.Errorf("Expected a first timer of %s, got %v", 59*time.Minute, durations)
+	}
+}
+
+func TestSSOConfigCachedTokenValid_clock(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	config := &ssoConfig{SessionName: "corporate"}
+	path, err := config.tokenCachePath()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	expiresAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	contents, err := json.Marshal(map[string]interface{}{
+		"accessToken": "token",
+		"expiresAt":   expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if !config.cachedTokenValid(&testClock{now: expiresAt.Add(-time.Minute)}) {
+		t.Error("Expected cached token to be valid before it expires")
+	}
+	if config.cachedTokenValid(&testClock{now: expiresAt}) {
+		t.Error("Expected cached token to be invalid once it expires")
+	}
+}
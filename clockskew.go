@@ -0,0 +1,118 @@
+package awsbase
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ClockSkew tracks the offset between the local clock and the clock of AWS
+// STS, as detected from signature time errors. Set Config.ClockSkew to share
+// the detected offset between sessions and read it after authentication.
+type ClockSkew struct {
+	offset int64
+}
+
+// Offset returns the duration to add to the local time to match AWS time.
+func (s *ClockSkew) Offset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.offset))
+}
+
+func (s *ClockSkew) setOffset(offset time.Duration) {
+	atomic.StoreInt64(&s.offset, int64(offset))
+}
+
+func (s *ClockSkew) now(clock Clock) time.Time {
+	return clock.Now().Add(s.Offset())
+}
+
+// clockSkewErrorCodes are the error codes returned by AWS when the signing
+// time differs too much from the server time.
+var clockSkewErrorCodes = map[string]bool{
+	"InvalidSignatureException": true,
+	"RequestExpired":            true,
+	"RequestInTheFuture":        true,
+	"RequestTimeTooSkewed":      true,
+	"SignatureDoesNotMatch":     true,
+}
+
+// addClockSkewHandlers signs STS requests with the local time corrected by
+// the detected clock skew. When STS rejects a request due to its signing
+// time, the skew is detected from the response Date header and the request is
+// retried once more than its retryer allows, so that it is re-signed even if
+// retries are disabled, e.g. with MaxRetries of 0, or exhausted.
+func addClockSkewHandlers(c *Config, handlers *request.Handlers) {
+	skew := c.ClockSkew
+	if skew == nil {
+		skew = &ClockSkew{}
+	}
+	clock := resolveClock(c.Clock)
+
+	signer := request.NamedHandler{
+		Name: v4.SignRequestHandler.Name,
+		Fn: func(r *request.Request) {
+			v4.SignSDKRequestWithCurrentTime(r, func() time.Time {
+				return skew.now(clock)
+			})
+		},
+	}
+
+	handlers.Build.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.ClockSkewSigner",
+		Fn: func(r *request.Request) {
+			if r.ClientInfo.ServiceName == sts.ServiceName {
+				r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, signer)
+			}
+		},
+	})
+
+	handlers.Retry.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.ClockSkewRetry",
+		Fn: func(r *request.Request) {
+			if r.ClientInfo.ServiceName != sts.ServiceName || r.HTTPResponse == nil {
+				return
+			}
+			if !isClockSkewError(r) {
+				return
+			}
+
+			serverTime, err := http.ParseTime(r.HTTPResponse.Header.Get("Date"))
+			if err != nil {
+				return
+			}
+
+			offset := serverTime.Sub(clock.Now())
+			logger.Printf("[WARN] Detected clock skew of %s from AWS STS, correcting signing time", offset)
+			skew.setOffset(offset)
+			r.Retryable = aws.Bool(true)
+			if _, ok := r.Retryer.(clockSkewRetryer); !ok {
+				r.Retryer = clockSkewRetryer{Retryer: r.Retryer}
+			}
+		},
+	})
+}
+
+// clockSkewRetryer allows a request one more retry than its retryer, to
+// re-sign it once the clock skew is corrected.
+type clockSkewRetryer struct {
+	request.Retryer
+}
+
+func (r clockSkewRetryer) MaxRetries() int {
+	return r.Retryer.MaxRetries() + 1
+}
+
+func isClockSkewError(r *request.Request) bool {
+	for code := range clockSkewErrorCodes {
+		if IsAWSErr(r.Error, code, "") {
+			// SignatureDoesNotMatch is also returned for invalid credentials.
+			return code != "SignatureDoesNotMatch" || IsAWSErr(r.Error, code, "Signature expired")
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+func TestAddClockSkewHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description string
+		MaxRetries  int
+	}{
+		{
+			Description: "retries",
+			MaxRetries:  1,
+		},
+		{
+			Description: "no retries",
+			MaxRetries:  0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			serverOffset := time.Hour
+
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+
+				serverTime := time.Now().Add(serverOffset)
+				w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+
+				signingTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+				if err != nil || serverTime.Sub(signingTime) > 5*time.Minute {
+					w.Header().Set("Content-Type", "text/xml")
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprintln(w, `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>SignatureDoesNotMatch</Code>
+    <Message>Signature expired: 20190101T000000Z is now earlier than 20190101T010000Z (20190101T011500Z - 15 min.)</Message>
+  </Error>
+  <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+</ErrorResponse>`)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/xml")
+				fmt.Fprintln(w, awsmocks.MockStsGetCallerIdentityValidResponseBody)
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: awsCredentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Endpoint:    aws.String(ts.URL),
+				MaxRetries:  aws.Int(testCase.MaxRetries),
+				Region:      aws.String("us-east-1"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			skew := &ClockSkew{}
+			addClockSkewHandlers(&Config{ClockSkew: skew}, &sess.Handlers)
+
+			if _, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{}); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if offset := skew.Offset(); offset < serverOffset-time.Minute || offset > serverOffset+time.Minute {
+				t.Fatalf("Expected clock skew of approximately %s, got %s", serverOffset, offset)
+			}
+			if requests := atomic.LoadInt32(&requests); requests != 2 {
+				t.Errorf("Expected request to be re-signed and sent again once, got %d request(s)", requests)
+			}
+		})
+	}
+}
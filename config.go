@@ -1,29 +1,319 @@
 package awsbase
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
 type Config struct {
-	AccessKey               string
-	AssumeRoleARN           string
-	AssumeRoleExternalID    string
-	AssumeRolePolicy        string
-	AssumeRoleSessionName   string
-	CredsFilename           string
-	DebugLogging            bool
-	IamEndpoint             string
-	Insecure                bool
-	MaxRetries              int
-	Profile                 string
-	Region                  string
-	SecretKey               string
-	SkipCredsValidation     bool
-	SkipMetadataApiCheck    bool
-	SkipRequestingAccountId bool
-	StsEndpoint             string
-	Token                   string
-	UserAgentProducts       []*UserAgentProduct
+	AccessKey                   string                      `json:"access_key,omitempty" hcl:"access_key,optional"`
+	AssumeRole                  *AssumeRole                 `json:"assume_role,omitempty" hcl:"assume_role,block"`
+	Clock                       Clock                       `json:"-"`
+	ClockSkew                   *ClockSkew                  `json:"-"`
+	CredentialProcess           *CredentialProcess          `json:"credential_process,omitempty" hcl:"credential_process,block"`
+	CredentialSourceOrder       []CredentialSource          `json:"credential_source_order,omitempty" hcl:"credential_source_order,optional"`
+	CredentialsProviderFunc     CredentialsProviderFunc     `json:"-"`
+	CredentialsRefresher        *CredentialsRefresher       `json:"-"`
+	CredsFilename               string                      `json:"shared_credentials_file,omitempty" hcl:"shared_credentials_file,optional"`
+	DebugLogging                bool                        `json:"debug_logging,omitempty" hcl:"debug_logging,optional"`
+	DefaultsMode                DefaultsMode                `json:"defaults_mode,omitempty" hcl:"defaults_mode,optional"`
+	DialContext                 DialContextFunc             `json:"-"`
+	DialFallbackDelay           time.Duration               `json:"dial_fallback_delay,omitempty" hcl:"dial_fallback_delay,optional"`
+	DynamoDBEndpoint            string                      `json:"dynamodb_endpoint,omitempty" hcl:"dynamodb_endpoint,optional"`
+	EndpointResolver            endpoints.Resolver          `json:"-"`
+	FederationToken             *FederationToken            `json:"federation_token,omitempty" hcl:"federation_token,block"`
+	HTTPClient                  *http.Client                `json:"-"`
+	IPAddressFamily             IPAddressFamily             `json:"ip_address_family,omitempty" hcl:"ip_address_family,optional"`
+	IamEndpoint                 string                      `json:"iam_endpoint,omitempty" hcl:"iam_endpoint,optional"`
+	IamSigningName              string                      `json:"iam_signing_name,omitempty" hcl:"iam_signing_name,optional"`
+	IamSigningRegion            string                      `json:"iam_signing_region,omitempty" hcl:"iam_signing_region,optional"`
+	IgnoreEnvCredentials        bool                        `json:"ignore_env_credentials,omitempty" hcl:"ignore_env_credentials,optional"`
+	IgnoreSharedCredentialsFile bool                        `json:"ignore_shared_credentials_file,omitempty" hcl:"ignore_shared_credentials_file,optional"`
+	Insecure                    bool                        `json:"insecure,omitempty" hcl:"insecure,optional"`
+	KeychainService             string                      `json:"keychain_service,omitempty" hcl:"keychain_service,optional"`
+	KeychainUser                string                      `json:"keychain_user,omitempty" hcl:"keychain_user,optional"`
+	MaxRetries                  int                         `json:"max_retries,omitempty" hcl:"max_retries,optional"`
+	MemoizeCredentials          bool                        `json:"memoize_credentials,omitempty" hcl:"memoize_credentials,optional"`
+	MemoizeSession              bool                        `json:"memoize_session,omitempty" hcl:"memoize_session,optional"`
+	MetadataApiCheckAttempts    int                         `json:"metadata_api_check_attempts,omitempty" hcl:"metadata_api_check_attempts,optional"`
+	Metrics                     Metrics                     `json:"-"`
+	OnError                     func(*request.Request)      `json:"-"`
+	OnRequest                   func(*request.Request)      `json:"-"`
+	OnRetry                     func(*request.Request)      `json:"-"`
+	OnSession                   SessionFunc                 `json:"-"`
+	Profile                     string                      `json:"profile,omitempty" hcl:"profile,optional"`
+	ProxyClientCertFilename     string                      `json:"proxy_client_cert_file,omitempty" hcl:"proxy_client_cert_file,optional"`
+	ProxyClientKeyFilename      string                      `json:"proxy_client_key_file,omitempty" hcl:"proxy_client_key_file,optional"`
+	ProxyNegotiateTokenProvider ProxyNegotiateTokenProvider `json:"-"`
+	ProxyRules                  []ProxyRule                 `json:"proxy_rules,omitempty" hcl:"proxy_rules,block"`
+	Region                      string                      `json:"region,omitempty" hcl:"region,optional"`
+	RequestLogging              bool                        `json:"request_logging,omitempty" hcl:"request_logging,optional"`
+	Resolver                    *net.Resolver               `json:"-"`
+	RetryQuota                  *RetryQuota                 `json:"-"`
+	RolesAnywhere               *RolesAnywhere              `json:"roles_anywhere,omitempty" hcl:"roles_anywhere,block"`
+	S3Endpoint                  string                      `json:"s3_endpoint,omitempty" hcl:"s3_endpoint,optional"`
+	S3ForcePathStyle            bool                        `json:"s3_force_path_style,omitempty" hcl:"s3_force_path_style,optional"`
+	S3SigningName               string                      `json:"s3_signing_name,omitempty" hcl:"s3_signing_name,optional"`
+	S3SigningRegion             string                      `json:"s3_signing_region,omitempty" hcl:"s3_signing_region,optional"`
+	S3UnsignedPayload           bool                        `json:"s3_unsigned_payload,omitempty" hcl:"s3_unsigned_payload,optional"`
+	S3UsEast1RegionalEndpoint   string                      `json:"s3_us_east_1_regional_endpoint,omitempty" hcl:"s3_us_east_1_regional_endpoint,optional"`
+	S3UseARNRegion              bool                        `json:"s3_use_arn_region,omitempty" hcl:"s3_use_arn_region,optional"`
+	S3UseAccelerate             bool                        `json:"s3_use_accelerate,omitempty" hcl:"s3_use_accelerate,optional"`
+	S3UseDualStack              bool                        `json:"s3_use_dualstack,omitempty" hcl:"s3_use_dualstack,optional"`
+	SecretKey                   string                      `json:"secret_key,omitempty" hcl:"secret_key,optional"`
+	ServiceMaxRetries           map[string]int              `json:"service_max_retries,omitempty" hcl:"service_max_retries,optional"`
+	SigV4ARegionSet             []string                    `json:"sigv4a_region_set,omitempty" hcl:"sigv4a_region_set,optional"`
+	SigV4AServices              []string                    `json:"sigv4a_services,omitempty" hcl:"sigv4a_services,optional"`
+	SkipCredsValidation         bool                        `json:"skip_credentials_validation,omitempty" hcl:"skip_credentials_validation,optional"`
+	SkipMetadataApiCheck        bool                        `json:"skip_metadata_api_check,omitempty" hcl:"skip_metadata_api_check,optional"`
+	SkipRequestingAccountId     bool                        `json:"skip_requesting_account_id,omitempty" hcl:"skip_requesting_account_id,optional"`
+	StrictCredentialSources     bool                        `json:"strict_credential_sources,omitempty" hcl:"strict_credential_sources,optional"`
+	StrictCredentialsFormat     bool                        `json:"strict_credentials_format,omitempty" hcl:"strict_credentials_format,optional"`
+	StsCallTimeout              time.Duration               `json:"sts_call_timeout,omitempty" hcl:"sts_call_timeout,optional"`
+	StsClientCertFilename       string                      `json:"sts_client_cert_file,omitempty" hcl:"sts_client_cert_file,optional"`
+	StsClientKeyFilename        string                      `json:"sts_client_key_file,omitempty" hcl:"sts_client_key_file,optional"`
+	StsConnectivityCheck        bool                        `json:"sts_connectivity_check,omitempty" hcl:"sts_connectivity_check,optional"`
+	StsEndpoint                 string                      `json:"sts_endpoint,omitempty" hcl:"sts_endpoint,optional"`
+	StsSigningName              string                      `json:"sts_signing_name,omitempty" hcl:"sts_signing_name,optional"`
+	StsSigningRegion            string                      `json:"sts_signing_region,omitempty" hcl:"sts_signing_region,optional"`
+	Token                       string                      `json:"token,omitempty" hcl:"token,optional"`
+	TracerProvider              trace.TracerProvider        `json:"-"`
+	UserAgentProducts           []*UserAgentProduct         `json:"user_agent_products,omitempty" hcl:"user_agent_products,block"`
+	WatchedCredsFilename        string                      `json:"watched_credentials_file,omitempty" hcl:"watched_credentials_file,optional"`
+}
+
+// AssumeRole contains the settings for assuming an IAM role with the
+// credentials resolved from the other Config fields.
+//
+// Region and StsEndpoint, when set, override the Config region and STS
+// endpoint for the sts:AssumeRole call, e.g. to assume a role in another
+// partition such as AWS GovCloud (US) where the trust policy permits.
+//
+// When TagsOptional is set and the session tags are rejected because
+// sts:TagSession is denied, the role is assumed again without session tags
+// and a warning is logged, rather than failing.
+//
+// ExpiryWindow is how long before they expire the assumed role credentials
+// are refreshed. A random duration of up to ExpiryWindowJitter, chosen once
+// per provider, is added to it, so that a fleet of hosts doesn't refresh in
+// lockstep.
+type AssumeRole struct {
+	Duration           time.Duration          `json:"duration,omitempty" hcl:"duration,optional"`
+	ExpiryWindow       time.Duration          `json:"expiry_window,omitempty" hcl:"expiry_window,optional"`
+	ExpiryWindowJitter time.Duration          `json:"expiry_window_jitter,omitempty" hcl:"expiry_window_jitter,optional"`
+	ExternalID         string                 `json:"external_id,omitempty" hcl:"external_id,optional"`
+	MFASerialNumber    string                 `json:"mfa_serial_number,omitempty" hcl:"mfa_serial_number,optional"`
+	MFATokenProvider   func() (string, error) `json:"-"`
+	Policy             string                 `json:"policy,omitempty" hcl:"policy,optional"`
+	PolicyARNs         []string               `json:"policy_arns,omitempty" hcl:"policy_arns,optional"`
+	Region             string                 `json:"region,omitempty" hcl:"region,optional"`
+	RoleARN            string                 `json:"role_arn,omitempty" hcl:"role_arn,optional"`
+	SessionName        string                 `json:"session_name,omitempty" hcl:"session_name,optional"`
+	SourceIdentity     string                 `json:"source_identity,omitempty" hcl:"source_identity,optional"`
+	StsEndpoint        string                 `json:"sts_endpoint,omitempty" hcl:"sts_endpoint,optional"`
+	Tags               map[string]string      `json:"tags,omitempty" hcl:"tags,optional"`
+	TagsOptional       bool                   `json:"tags_optional,omitempty" hcl:"tags_optional,optional"`
+	TransitiveTagKeys  []string               `json:"transitive_tag_keys,omitempty" hcl:"transitive_tag_keys,optional"`
+}
+
+var (
+	assumeRoleExternalIDRegexp     = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+	assumeRoleSessionNameRegexp    = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+	assumeRoleSourceIdentityRegexp = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+)
+
+// Validate checks the AssumeRole settings against the constraints of the
+// STS AssumeRole API, so that misconfiguration is reported before any API
+// calls are made.
+func (r *AssumeRole) Validate() error {
+	var errs []error
+
+	if r.RoleARN == "" {
+		errs = append(errs, errors.New("role ARN must be set"))
+	} else if roleARN, err := arn.Parse(r.RoleARN); err != nil {
+		errs = append(errs, fmt.Errorf("invalid role ARN %q: %w", r.RoleARN, err))
+	} else if r.Region != "" && ValidateRegionFormat(r.Region) == nil {
+		if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), r.Region); ok && partition.ID() != roleARN.Partition {
+			errs = append(errs, fmt.Errorf("role ARN %q is not in the partition of region %q (%s)", r.RoleARN, r.Region, partition.ID()))
+		}
+	}
+
+	if r.Region != "" {
+		if err := ValidateRegionFormat(r.Region); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.Duration != 0 && (r.Duration < 15*time.Minute || r.Duration > 12*time.Hour) {
+		errs = append(errs, fmt.Errorf("duration must be between 15m and 12h, got %s", r.Duration))
+	}
+
+	if r.ExpiryWindow < 0 || r.ExpiryWindowJitter < 0 {
+		errs = append(errs, errors.New("expiry window and jitter must not be negative"))
+	} else if duration := r.duration(); r.ExpiryWindow+r.ExpiryWindowJitter >= duration {
+		errs = append(errs, fmt.Errorf("expiry window and jitter must be less than the duration %s", duration))
+	}
+
+	if r.ExternalID != "" && (len(r.ExternalID) < 2 || len(r.ExternalID) > 1224 || !assumeRoleExternalIDRegexp.MatchString(r.ExternalID)) {
+		errs = append(errs, fmt.Errorf("invalid external ID %q", r.ExternalID))
+	}
+
+	if r.SessionName != "" && !assumeRoleSessionNameRegexp.MatchString(r.SessionName) {
+		errs = append(errs, fmt.Errorf("invalid session name %q", r.SessionName))
+	}
+
+	if r.SourceIdentity != "" && !assumeRoleSourceIdentityRegexp.MatchString(r.SourceIdentity) {
+		errs = append(errs, fmt.Errorf("invalid source identity %q", r.SourceIdentity))
+	}
+
+	if r.Policy != "" && !json.Valid([]byte(r.Policy)) {
+		errs = append(errs, errors.New("policy must be valid JSON"))
+	}
+
+	for _, policyARN := range r.PolicyARNs {
+		if _, err := arn.Parse(policyARN); err != nil {
+			errs = append(errs, fmt.Errorf("invalid policy ARN %q: %w", policyARN, err))
+		}
+	}
+
+	if len(r.Tags) > 50 {
+		errs = append(errs, fmt.Errorf("at most 50 session tags may be set, got %d", len(r.Tags)))
+	}
+
+	for key, value := range r.Tags {
+		if len(key) < 1 || len(key) > 128 {
+			errs = append(errs, fmt.Errorf("session tag key %q must be between 1 and 128 characters", key))
+		}
+		if len(value) > 256 {
+			errs = append(errs, fmt.Errorf("session tag %q value must be at most 256 characters", key))
+		}
+	}
+
+	for _, key := range r.TransitiveTagKeys {
+		if _, ok := r.Tags[key]; !ok {
+			errs = append(errs, fmt.Errorf("transitive tag key %q is not a session tag", key))
+		}
+	}
+
+	if r.MFASerialNumber != "" && r.MFATokenProvider == nil {
+		errs = append(errs, errors.New("MFA token provider must be set with MFA serial number"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// duration returns the duration of the assumed role session.
+func (r *AssumeRole) duration() time.Duration {
+	if r.Duration > 0 {
+		return r.Duration
+	}
+	return stscreds.DefaultDuration
+}
+
+// ResolveExpiryWindow returns how long before they expire the assumed role
+// credentials are refreshed: the ExpiryWindow plus a random duration of up to
+// the ExpiryWindowJitter.
+func (r *AssumeRole) ResolveExpiryWindow() time.Duration {
+	window := r.ExpiryWindow
+	if r.ExpiryWindowJitter > 0 {
+		window += time.Duration(rand.Int63n(int64(r.ExpiryWindowJitter) + 1))
+	}
+	return window
+}
+
+const (
+	// AssumeRoleARNEnvVar is the environment variable for the ARN of the role
+	// to assume when AssumeRole.RoleARN is empty.
+	AssumeRoleARNEnvVar = "AWS_ROLE_ARN"
+	// AssumeRoleExternalIDEnvVar is the environment variable for the external
+	// ID used when AssumeRole.ExternalID is empty.
+	AssumeRoleExternalIDEnvVar = "AWS_ROLE_EXTERNAL_ID"
+	// AssumeRoleSessionNameEnvVar is the environment variable for the session
+	// name used when AssumeRole.SessionName is empty.
+	AssumeRoleSessionNameEnvVar = "AWS_ROLE_SESSION_NAME"
+
+	webIdentityTokenFileEnvVar = "AWS_WEB_IDENTITY_TOKEN_FILE"
+)
+
+// ResolveAssumeRole returns the AssumeRole settings of the Config with the
+// AWS_ROLE_ARN, AWS_ROLE_SESSION_NAME, and AWS_ROLE_EXTERNAL_ID environment
+// variables applied to empty fields, or nil if no role is to be assumed.
+// The Config is not modified.
+//
+// The environment variables are ignored when AWS_WEB_IDENTITY_TOKEN_FILE is
+// set, as the AWS SDK then uses them to assume a role with web identity.
+func ResolveAssumeRole(c *Config) *AssumeRole {
+	if os.Getenv(webIdentityTokenFileEnvVar) != "" {
+		return c.AssumeRole
+	}
+
+	var r AssumeRole
+	if c.AssumeRole != nil {
+		r = *c.AssumeRole
+	}
+
+	if r.RoleARN == "" {
+		r.RoleARN = os.Getenv(AssumeRoleARNEnvVar)
+	}
+	if r.RoleARN == "" {
+		return c.AssumeRole
+	}
+	if r.SessionName == "" {
+		r.SessionName = os.Getenv(AssumeRoleSessionNameEnvVar)
+	}
+	if r.ExternalID == "" {
+		r.ExternalID = os.Getenv(AssumeRoleExternalIDEnvVar)
+	}
+
+	return &r
+}
+
+// Merge returns a copy of the Config with the fields set in the override
+// Config, i.e. those with non-zero values, taking precedence, e.g. to layer
+// settings of files and command-line flags over defaults:
+//
+//	c := DefaultConfig().Merge(fileConfig).Merge(flagConfig)
+//
+// Fields are replaced as a whole: pointers to nested settings, e.g.
+// AssumeRole, are not merged field by field, and slices and maps are not
+// appended to. An empty, non-nil slice or map clears that of the Config. As
+// false is the zero value, boolean fields can only be set, not cleared, by
+// the override. Neither Config is modified, but the copy shares the values
+// of pointers, slices, and maps with them.
+func (c *Config) Merge(override *Config) *Config {
+	merged := *c
+	if override == nil {
+		return &merged
+	}
+
+	dst := reflect.ValueOf(&merged).Elem()
+	src := reflect.ValueOf(override).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if field := src.Field(i); !field.IsZero() {
+			dst.Field(i).Set(field)
+		}
+	}
+
+	return &merged
 }
 
 type UserAgentProduct struct {
-	Extra   []string
-	Name    string
-	Version string
+	Extra   []string `json:"extra,omitempty" hcl:"extra,optional"`
+	Name    string   `json:"name,omitempty" hcl:"name,optional"`
+	Version string   `json:"version,omitempty" hcl:"version,optional"`
 }
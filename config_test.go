@@ -0,0 +1,393 @@
+package awsbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssumeRoleValidate(t *testing.T) {
+	testCases := []struct {
+		Description   string
+		AssumeRole    *AssumeRole
+		ExpectedError string
+	}{
+		{
+			Description: "role ARN only",
+			AssumeRole: &AssumeRole{
+				RoleARN: "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+		},
+		{
+			Description: "all settings",
+			AssumeRole: &AssumeRole{
+				Duration:          time.Hour,
+				ExternalID:        "external-id",
+				MFASerialNumber:   "arn:aws:iam::555555555555:mfa/user",
+				MFATokenProvider:  func() (string, error) { return "123456", nil },
+				Policy:            `{"Version":"2012-10-17","Statement":[]}`,
+				PolicyARNs:        []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+				RoleARN:           "arn:aws:iam::555555555555:role/AssumeRole",
+				SessionName:       "session@example.com",
+				SourceIdentity:    "operator@example.com",
+				Tags:              map[string]string{"Project": "example"},
+				TransitiveTagKeys: []string{"Project"},
+			},
+		},
+		{
+			Description:   "missing role ARN",
+			AssumeRole:    &AssumeRole{},
+			ExpectedError: "role ARN must be set",
+		},
+		{
+			Description: "malformed region",
+			AssumeRole: &AssumeRole{
+				Region:  "US-EAST-1",
+				RoleARN: "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: `invalid AWS region "US-EAST-1": must be lowercase`,
+		},
+		{
+			Description: "invalid role ARN",
+			AssumeRole: &AssumeRole{
+				RoleARN: "AssumeRole",
+			},
+			ExpectedError: `invalid role ARN "AssumeRole"`,
+		},
+		{
+			Description: "expiry window with jitter",
+			AssumeRole: &AssumeRole{
+				ExpiryWindow:       5 * time.Minute,
+				ExpiryWindowJitter: 2 * time.Minute,
+				RoleARN:            "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+		},
+		{
+			Description: "negative expiry window",
+			AssumeRole: &AssumeRole{
+				ExpiryWindow: -time.Minute,
+				RoleARN:      "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: "expiry window and jitter must not be negative",
+		},
+		{
+			Description: "expiry window exceeding duration",
+			AssumeRole: &AssumeRole{
+				ExpiryWindow:       10 * time.Minute,
+				ExpiryWindowJitter: 5 * time.Minute,
+				RoleARN:            "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: "expiry window and jitter must be less than the duration 15m0s",
+		},
+		{
+			Description: "region in role ARN partition",
+			AssumeRole: &AssumeRole{
+				Region:  "us-gov-west-1",
+				RoleARN: "arn:aws-us-gov:iam::555555555555:role/AssumeRole",
+			},
+		},
+		{
+			Description: "region not in role ARN partition",
+			AssumeRole: &AssumeRole{
+				Region:  "us-gov-west-1",
+				RoleARN: "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: `role ARN "arn:aws:iam::555555555555:role/AssumeRole" is not in the partition of region "us-gov-west-1" (aws-us-gov)`,
+		},
+		{
+			Description: "duration too short",
+			AssumeRole: &AssumeRole{
+				Duration: time.Minute,
+				RoleARN:  "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: "duration must be between 15m and 12h",
+		},
+		{
+			Description: "invalid session name",
+			AssumeRole: &AssumeRole{
+				RoleARN:     "arn:aws:iam::555555555555:role/AssumeRole",
+				SessionName: "session name",
+			},
+			ExpectedError: `invalid session name "session name"`,
+		},
+		{
+			Description: "invalid external ID",
+			AssumeRole: &AssumeRole{
+				ExternalID: "x",
+				RoleARN:    "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: `invalid external ID "x"`,
+		},
+		{
+			Description: "invalid policy",
+			AssumeRole: &AssumeRole{
+				Policy:  "{",
+				RoleARN: "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: "policy must be valid JSON",
+		},
+		{
+			Description: "transitive tag key without tag",
+			AssumeRole: &AssumeRole{
+				RoleARN:           "arn:aws:iam::555555555555:role/AssumeRole",
+				TransitiveTagKeys: []string{"Project"},
+			},
+			ExpectedError: `transitive tag key "Project" is not a session tag`,
+		},
+		{
+			Description: "invalid source identity",
+			AssumeRole: &AssumeRole{
+				RoleARN:        "arn:aws:iam::555555555555:role/AssumeRole",
+				SourceIdentity: "operator name",
+			},
+			ExpectedError: `invalid source identity "operator name"`,
+		},
+		{
+			Description: "MFA serial number without token provider",
+			AssumeRole: &AssumeRole{
+				MFASerialNumber: "arn:aws:iam::555555555555:mfa/user",
+				RoleARN:         "arn:aws:iam::555555555555:role/AssumeRole",
+			},
+			ExpectedError: "MFA token provider must be set",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			err := testCase.AssumeRole.Validate()
+
+			if testCase.ExpectedError == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error containing %q, got none", testCase.ExpectedError)
+			}
+			if !strings.Contains(err.Error(), testCase.ExpectedError) {
+				t.Errorf("expected error containing %q, got: %s", testCase.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestResolveAssumeRole(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Config      *Config
+		Env         map[string]string
+		Expected    *AssumeRole
+	}{
+		{
+			Description: "no role",
+			Config:      &Config{},
+		},
+		{
+			Description: "config only",
+			Config: &Config{
+				AssumeRole: &AssumeRole{RoleARN: "arn:aws:iam::555555555555:role/Config"},
+			},
+			Expected: &AssumeRole{RoleARN: "arn:aws:iam::555555555555:role/Config"},
+		},
+		{
+			Description: "environment only",
+			Config:      &Config{},
+			Env: map[string]string{
+				"AWS_ROLE_ARN":          "arn:aws:iam::555555555555:role/Env",
+				"AWS_ROLE_EXTERNAL_ID":  "env-external-id",
+				"AWS_ROLE_SESSION_NAME": "env-session",
+			},
+			Expected: &AssumeRole{
+				ExternalID:  "env-external-id",
+				RoleARN:     "arn:aws:iam::555555555555:role/Env",
+				SessionName: "env-session",
+			},
+		},
+		{
+			Description: "environment fills empty fields",
+			Config: &Config{
+				AssumeRole: &AssumeRole{
+					RoleARN:     "arn:aws:iam::555555555555:role/Config",
+					SessionName: "config-session",
+				},
+			},
+			Env: map[string]string{
+				"AWS_ROLE_ARN":          "arn:aws:iam::555555555555:role/Env",
+				"AWS_ROLE_EXTERNAL_ID":  "env-external-id",
+				"AWS_ROLE_SESSION_NAME": "env-session",
+			},
+			Expected: &AssumeRole{
+				ExternalID:  "env-external-id",
+				RoleARN:     "arn:aws:iam::555555555555:role/Config",
+				SessionName: "config-session",
+			},
+		},
+		{
+			Description: "session name without role ARN",
+			Config:      &Config{},
+			Env: map[string]string{
+				"AWS_ROLE_SESSION_NAME": "env-session",
+			},
+		},
+		{
+			Description: "web identity",
+			Config:      &Config{},
+			Env: map[string]string{
+				"AWS_ROLE_ARN":                "arn:aws:iam::555555555555:role/Env",
+				"AWS_WEB_IDENTITY_TOKEN_FILE": "/var/run/secrets/token",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			for _, key := range []string{"AWS_ROLE_ARN", "AWS_ROLE_EXTERNAL_ID", "AWS_ROLE_SESSION_NAME", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+				t.Setenv(key, testCase.Env[key])
+			}
+
+			var original *AssumeRole
+			if testCase.Config.AssumeRole != nil {
+				r := *testCase.Config.AssumeRole
+				original = &r
+			}
+
+			got := ResolveAssumeRole(testCase.Config)
+
+			if !reflect.DeepEqual(got, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, got)
+			}
+			if !reflect.DeepEqual(testCase.Config.AssumeRole, original) {
+				t.Errorf("expected Config not to be modified, got %+v", testCase.Config.AssumeRole)
+			}
+		})
+	}
+}
+
+func TestAssumeRoleValidate_multipleErrors(t *testing.T) {
+	err := (&AssumeRole{
+		Duration:    time.Minute,
+		SessionName: "operator name",
+	}).Validate()
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Expected joined errors, got: %v", err)
+	}
+	if got := len(joined.Unwrap()); got != 3 {
+		t.Errorf("Expected 3 errors, got %d: %s", got, err)
+	}
+}
+
+func TestAssumeRoleResolveExpiryWindow(t *testing.T) {
+	r := &AssumeRole{
+		ExpiryWindow:       5 * time.Minute,
+		ExpiryWindowJitter: time.Minute,
+	}
+
+	for i := 0; i < 100; i++ {
+		if window := r.ResolveExpiryWindow(); window < 5*time.Minute || window > 6*time.Minute {
+			t.Fatalf("Expected expiry window between 5m and 6m, got %s", window)
+		}
+	}
+
+	if window := (&AssumeRole{ExpiryWindow: time.Minute}).ResolveExpiryWindow(); window != time.Minute {
+		t.Errorf("Expected expiry window 1m without jitter, got %s", window)
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	assumeRole := &AssumeRole{RoleARN: "arn:aws:iam::555555555555:role/AssumeRole"}
+
+	testCases := []struct {
+		Description string
+		Config      *Config
+		Override    *Config
+		Expected    *Config
+	}{
+		{
+			Description: "nil override",
+			Config:      &Config{Region: "us-west-2"},
+			Expected:    &Config{Region: "us-west-2"},
+		},
+		{
+			Description: "set fields take precedence",
+			Config: &Config{
+				MaxRetries: 25,
+				Profile:    "default",
+				Region:     "us-west-2",
+			},
+			Override: &Config{
+				AssumeRole: assumeRole,
+				Region:     "us-east-1",
+			},
+			Expected: &Config{
+				AssumeRole: assumeRole,
+				MaxRetries: 25,
+				Profile:    "default",
+				Region:     "us-east-1",
+			},
+		},
+		{
+			Description: "nested settings are replaced",
+			Config: &Config{
+				AssumeRole: &AssumeRole{
+					RoleARN:     "arn:aws:iam::111111111111:role/Default",
+					SessionName: "default",
+				},
+			},
+			Override: &Config{AssumeRole: assumeRole},
+			Expected: &Config{AssumeRole: assumeRole},
+		},
+		{
+			Description: "false does not clear booleans",
+			Config:      &Config{Insecure: true},
+			Override:    &Config{SkipCredsValidation: true},
+			Expected: &Config{
+				Insecure:            true,
+				SkipCredsValidation: true,
+			},
+		},
+		{
+			Description: "empty slices clear slices",
+			Config: &Config{
+				SigV4AServices:    []string{"s3"},
+				UserAgentProducts: []*UserAgentProduct{{Name: "first"}},
+			},
+			Override: &Config{
+				SigV4AServices:    []string{},
+				UserAgentProducts: []*UserAgentProduct{{Name: "second"}},
+			},
+			Expected: &Config{
+				SigV4AServices:    []string{},
+				UserAgentProducts: []*UserAgentProduct{{Name: "second"}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			original := *testCase.Config
+
+			merged := testCase.Config.Merge(testCase.Override)
+
+			if !reflect.DeepEqual(merged, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, merged)
+			}
+			if merged == testCase.Config {
+				t.Error("expected a copy of the Config")
+			}
+			if !reflect.DeepEqual(*testCase.Config, original) {
+				t.Error("expected Config not to be modified")
+			}
+		})
+	}
+}
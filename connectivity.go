@@ -0,0 +1,164 @@
+package awsbase
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// stsConnectivityCheckTimeout is how long the STS connectivity check waits to
+// connect to the STS endpoint.
+const stsConnectivityCheckTimeout = 5 * time.Second
+
+// checkConnectivity opens a TCP connection to the host of the endpoint,
+// through the proxy the HTTP client would use, and completes a TLS handshake
+// for HTTPS endpoints, so that network misconfigurations are reported clearly
+// rather than as API call timeouts.
+func checkConnectivity(ctx context.Context, client *http.Client, endpoint string) error {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("error parsing endpoint %q: %w", endpoint, err)
+	}
+
+	address := endpointURL.Host
+	if endpointURL.Port() == "" {
+		port := "443"
+		if endpointURL.Scheme == "http" {
+			port = "80"
+		}
+		address = net.JoinHostPort(endpointURL.Hostname(), port)
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		logger.Printf("[WARN] Unable to check connectivity to %s for HTTP client transport %T", address, client.Transport)
+		return nil
+	}
+
+	var proxyURL *url.URL
+	if transport.Proxy != nil {
+		proxyURL, err = transport.Proxy(&http.Request{URL: endpointURL, Header: make(http.Header)})
+		if err != nil {
+			return fmt.Errorf("error determining proxy for %s: %w", address, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stsConnectivityCheckTimeout)
+	defer cancel()
+
+	conn, err := dialEndpoint(ctx, transport, proxyURL, address)
+	if err == nil {
+		defer conn.Close()
+
+		if endpointURL.Scheme != "http" {
+			tlsConfig := &tls.Config{}
+			if transport.TLSClientConfig != nil {
+				tlsConfig = transport.TLSClientConfig.Clone()
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = endpointURL.Hostname()
+			}
+			err = tls.Client(conn, tlsConfig).HandshakeContext(ctx)
+		}
+	}
+
+	if err != nil {
+		if proxyURL != nil {
+			return fmt.Errorf("cannot reach %s via proxy %s: %w", address, proxyURL.Host, err)
+		}
+		return fmt.Errorf("cannot reach %s: %w", address, err)
+	}
+
+	return nil
+}
+
+// dialEndpoint opens a TCP connection to the address, tunneled through the
+// proxy with a CONNECT request if one is given.
+func dialEndpoint(ctx context.Context, transport *http.Transport, proxyURL *url.URL, address string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	dial := dialer.DialContext
+	if transport.DialContext != nil {
+		dial = transport.DialContext
+	}
+
+	if proxyURL == nil {
+		return dial(ctx, "tcp", address)
+	}
+
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	header := transport.ProxyConnectHeader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if transport.GetProxyConnectHeader != nil {
+		extraHeader, err := transport.GetProxyConnectHeader(ctx, proxyURL, address)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		for k, v := range extraHeader {
+			header[k] = v
+		}
+	}
+	if proxyURL.User != nil && header.Get("Proxy-Authorization") == "" {
+		password, _ := proxyURL.User.Password()
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(proxyURL.User.Username(), password)
+		header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: header,
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The buffered reader can be discarded, as the TLS server doesn't write
+	// until the client does.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy responded to CONNECT with %s", resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
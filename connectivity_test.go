@@ -0,0 +1,107 @@
+package awsbase
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckConnectivity(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	// proxy is a CONNECT proxy which only tunnels to the target server.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Host != target.Listener.Addr().String() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		targetConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer targetConn.Close()
+
+		w.WriteHeader(http.StatusOK)
+		clientConn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer clientConn.Close()
+
+		go io.Copy(targetConn, clientConn)
+		io.Copy(clientConn, targetConn)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description   string
+		Endpoint      string
+		Proxy         bool
+		ExpectedError string
+	}{
+		{
+			Description: "direct",
+			Endpoint:    target.URL,
+		},
+		{
+			Description:   "direct unreachable",
+			Endpoint:      strings.Replace(closed.URL, "http:", "https:", 1),
+			ExpectedError: "cannot reach " + closed.Listener.Addr().String() + ":",
+		},
+		{
+			Description: "proxy",
+			Endpoint:    target.URL,
+			Proxy:       true,
+		},
+		{
+			Description:   "proxy unreachable",
+			Endpoint:      strings.Replace(closed.URL, "http:", "https:", 1),
+			Proxy:         true,
+			ExpectedError: "cannot reach " + closed.Listener.Addr().String() + " via proxy " + proxyURL.Host + ":",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			transport := &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+			if testCase.Proxy {
+				transport.Proxy = http.ProxyURL(proxyURL)
+			}
+
+			err := checkConnectivity(context.Background(), &http.Client{Transport: transport}, testCase.Endpoint)
+			if testCase.ExpectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, received error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error, received none")
+			}
+			if !strings.HasPrefix(err.Error(), testCase.ExpectedError) {
+				t.Errorf("Expected error prefix %q, got %q", testCase.ExpectedError, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package awsbase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// CredentialSource is a source of credentials of the credential chain built
+// by GetCredentials.
+type CredentialSource string
+
+const (
+	// CredentialSourceStatic is the AccessKey, SecretKey, and Token of the
+	// Config.
+	CredentialSourceStatic CredentialSource = "static"
+	// CredentialSourceFunction is the CredentialsProviderFunc of the Config.
+	CredentialSourceFunction CredentialSource = "function"
+	// CredentialSourceWatchedFile is the WatchedCredsFilename of the Config.
+	CredentialSourceWatchedFile CredentialSource = "watched-file"
+	// CredentialSourceKeychain is the OS credential store service of the
+	// Config.
+	CredentialSourceKeychain CredentialSource = "keychain"
+	// CredentialSourceProcess is the CredentialProcess of the Config.
+	CredentialSourceProcess CredentialSource = "process"
+	// CredentialSourceRolesAnywhere is the IAM Roles Anywhere role of the
+	// RolesAnywhere settings of the Config.
+	CredentialSourceRolesAnywhere CredentialSource = "roles-anywhere"
+	// CredentialSourceEnvironment is the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+	CredentialSourceEnvironment CredentialSource = "environment"
+	// CredentialSourceSharedCredentials is the profile of the shared
+	// credentials files.
+	CredentialSourceSharedCredentials CredentialSource = "shared-credentials"
+	// CredentialSourceWebIdentity is the web identity token file of the
+	// AWS_WEB_IDENTITY_TOKEN_FILE environment variable, e.g. of Kubernetes
+	// IAM roles for service accounts.
+	CredentialSourceWebIdentity CredentialSource = "web-identity"
+	// CredentialSourceContainer is the ECS container credentials endpoint.
+	CredentialSourceContainer CredentialSource = "container"
+	// CredentialSourceEC2InstanceProfile is the EC2 instance profile of the
+	// EC2 metadata API.
+	CredentialSourceEC2InstanceProfile CredentialSource = "ec2-instance-profile"
+)
+
+// defaultCredentialSourceOrder is the order in which the credential chain
+// consults the credential sources by default.
+var defaultCredentialSourceOrder = []CredentialSource{
+	CredentialSourceStatic,
+	CredentialSourceFunction,
+	CredentialSourceWatchedFile,
+	CredentialSourceKeychain,
+	CredentialSourceProcess,
+	CredentialSourceRolesAnywhere,
+	CredentialSourceEnvironment,
+	CredentialSourceSharedCredentials,
+	CredentialSourceWebIdentity,
+	CredentialSourceContainer,
+	CredentialSourceEC2InstanceProfile,
+}
+
+// credentialSourceOrder returns the order in which the credential chain of the
+// Config consults the credential sources: those of its CredentialSourceOrder,
+// followed by the others in the default order.
+func credentialSourceOrder(c *Config) ([]CredentialSource, error) {
+	if len(c.CredentialSourceOrder) == 0 {
+		return defaultCredentialSourceOrder, nil
+	}
+
+	known := make(map[CredentialSource]bool, len(defaultCredentialSourceOrder))
+	for _, source := range defaultCredentialSourceOrder {
+		known[source] = true
+	}
+
+	order := make([]CredentialSource, 0, len(defaultCredentialSourceOrder))
+	ordered := make(map[CredentialSource]bool, len(c.CredentialSourceOrder))
+	for _, source := range c.CredentialSourceOrder {
+		if !known[source] {
+			return nil, fmt.Errorf("invalid credential source order: unknown credential source %q", source)
+		}
+		if ordered[source] {
+			return nil, fmt.Errorf("invalid credential source order: duplicate credential source %q", source)
+		}
+		ordered[source] = true
+		order = append(order, source)
+	}
+	for _, source := range defaultCredentialSourceOrder {
+		if !ordered[source] {
+			order = append(order, source)
+		}
+	}
+
+	return order, nil
+}
+
+// orderedProviders returns the providers of the credential sources in their
+// order, up to the given source, or all of them if it is empty.
+func orderedProviders(order []CredentialSource, providers map[CredentialSource]awsCredentials.Provider, until CredentialSource) []awsCredentials.Provider {
+	var result []awsCredentials.Provider
+	for _, source := range order {
+		if source == until {
+			break
+		}
+		if provider, ok := providers[source]; ok {
+			result = append(result, provider)
+		}
+	}
+	return result
+}
+
+// credentialSourcePrecedes returns whether the credential source a precedes b
+// in the order.
+func credentialSourcePrecedes(order []CredentialSource, a, b CredentialSource) bool {
+	for _, source := range order {
+		switch source {
+		case a:
+			return true
+		case b:
+			return false
+		}
+	}
+	return false
+}
+
+// configuredCredentialSources returns descriptions of the credential sources
+// explicitly configured by the Config or the environment, e.g. static
+// credentials and the AWS_ACCESS_KEY_ID environment variable, in the given
+// order of the credential chain. The shared credentials file is only
+// included if a profile is selected, as it is otherwise consulted implicitly.
+func configuredCredentialSources(c *Config, order []CredentialSource) []string {
+	configured := make(map[CredentialSource]string)
+
+	if c.AccessKey != "" || c.SecretKey != "" {
+		configured[CredentialSourceStatic] = "static credentials"
+	}
+	if c.CredentialsProviderFunc != nil {
+		configured[CredentialSourceFunction] = "credentials function"
+	}
+	if c.WatchedCredsFilename != "" {
+		configured[CredentialSourceWatchedFile] = fmt.Sprintf("credentials file %q", c.WatchedCredsFilename)
+	}
+	if c.KeychainService != "" {
+		configured[CredentialSourceKeychain] = fmt.Sprintf("OS credential store service %q", c.KeychainService)
+	}
+	if c.CredentialProcess != nil {
+		configured[CredentialSourceProcess] = fmt.Sprintf("credential process %q", c.CredentialProcess.Command)
+	}
+	if c.RolesAnywhere != nil {
+		configured[CredentialSourceRolesAnywhere] = fmt.Sprintf("IAM Roles Anywhere role %s", c.RolesAnywhere.RoleARN)
+	}
+	if !c.IgnoreEnvCredentials && envCredentialsSet() {
+		configured[CredentialSourceEnvironment] = "environment variables"
+	}
+	if !c.IgnoreSharedCredentialsFile && (c.Profile != "" || os.Getenv("AWS_PROFILE") != "") {
+		configured[CredentialSourceSharedCredentials] = fmt.Sprintf("shared credentials profile %q", sharedCredentialsProfile(c.Profile))
+	}
+
+	var sources []string
+	for _, source := range order {
+		if description, ok := configured[source]; ok {
+			sources = append(sources, description)
+		}
+	}
+	return sources
+}
+
+// checkCredentialSourceConflicts warns if more than one credential source is
+// configured, naming the source which takes precedence in the credential
+// chain, as the others are otherwise silently ignored. With
+// StrictCredentialSources set, it returns an error instead.
+func checkCredentialSourceConflicts(c *Config, order []CredentialSource) error {
+	sources := configuredCredentialSources(c, order)
+	if len(sources) < 2 {
+		return nil
+	}
+
+	if c.StrictCredentialSources {
+		return fmt.Errorf("multiple credential sources configured (%s), of which %s would be used: configure only one", strings.Join(sources, ", "), sources[0])
+	}
+
+	logger.With(logFields{
+		"sources": sources,
+		"source":  sources[0],
+	}).Printf("[WARN] Multiple credential sources configured (%s), using the first: %s", strings.Join(sources, ", "), sources[0])
+	return nil
+}
+
+// checkSingleCredentialSource returns an error if more than one of the
+// providers of the credential chain, possibly wrapped by an audit trail, has
+// credentials, for StrictCredentialSources. The credentials are retrieved
+// from each provider, bypassing the audit trail.
+func checkSingleCredentialSource(providers []awsCredentials.Provider) error {
+	var sources []string
+	for _, provider := range providers {
+		description := providerType(provider)
+		if audited, ok := provider.(*auditedProvider); ok {
+			provider, description = audited.Provider, audited.description
+		}
+		if _, err := provider.Retrieve(); err == nil {
+			sources = append(sources, description)
+		}
+	}
+
+	if len(sources) > 1 {
+		return fmt.Errorf("multiple credential sources have credentials (%s), of which %s would be used: configure only one", strings.Join(sources, ", "), sources[0])
+	}
+	return nil
+}
+
+// ignoredCredentialsProvider returns whether the Config ignores credentials
+// of the AWS Go SDK provider with the given name, e.g. of environment
+// variables with IgnoreEnvCredentials set.
+func ignoredCredentialsProvider(c *Config, providerName string) bool {
+	switch providerName {
+	case awsCredentials.EnvProviderName:
+		return c.IgnoreEnvCredentials
+	case awsCredentials.SharedCredsProviderName:
+		return c.IgnoreSharedCredentialsFile
+	}
+	return false
+}
+
+// envCredentialsSet returns whether credentials are set by environment
+// variables, as read by awsCredentials.EnvProvider.
+func envCredentialsSet() bool {
+	return os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_ACCESS_KEY") != ""
+}
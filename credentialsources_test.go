@@ -0,0 +1,479 @@
+package awsbase
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfiguredCredentialSources(t *testing.T) {
+	var testCases = []struct {
+		Description     string
+		Config          *Config
+		EnvironmentVars map[string]string
+		ExpectedSources []string
+	}{
+		{
+			Description: "none",
+			Config:      &Config{},
+		},
+		{
+			Description: "static credentials",
+			Config: &Config{
+				AccessKey: "accessKey",
+				SecretKey: "secretKey",
+			},
+			ExpectedSources: []string{"static credentials"},
+		},
+		{
+			Description: "static credentials and environment variables",
+			Config: &Config{
+				AccessKey: "accessKey",
+				SecretKey: "secretKey",
+			},
+			EnvironmentVars: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "envAccessKey",
+				"AWS_SECRET_ACCESS_KEY": "envSecretKey",
+			},
+			ExpectedSources: []string{"static credentials", "environment variables"},
+		},
+		{
+			Description: "environment variables and profile",
+			Config: &Config{
+				Profile: "myprofile",
+			},
+			EnvironmentVars: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "envAccessKey",
+				"AWS_SECRET_ACCESS_KEY": "envSecretKey",
+			},
+			ExpectedSources: []string{"environment variables", `shared credentials profile "myprofile"`},
+		},
+		{
+			Description: "static credentials, environment variables, and AWS_PROFILE",
+			Config: &Config{
+				AccessKey: "accessKey",
+				SecretKey: "secretKey",
+			},
+			EnvironmentVars: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "envAccessKey",
+				"AWS_SECRET_ACCESS_KEY": "envSecretKey",
+				"AWS_PROFILE":           "envprofile",
+			},
+			ExpectedSources: []string{"static credentials", "environment variables", `shared credentials profile "envprofile"`},
+		},
+		{
+			Description: "ignored environment variables",
+			Config: &Config{
+				AccessKey:            "accessKey",
+				IgnoreEnvCredentials: true,
+				SecretKey:            "secretKey",
+			},
+			EnvironmentVars: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "envAccessKey",
+				"AWS_SECRET_ACCESS_KEY": "envSecretKey",
+			},
+			ExpectedSources: []string{"static credentials"},
+		},
+		{
+			Description: "ignored shared credentials file",
+			Config: &Config{
+				AccessKey:                   "accessKey",
+				IgnoreSharedCredentialsFile: true,
+				Profile:                     "myprofile",
+				SecretKey:                   "secretKey",
+			},
+			ExpectedSources: []string{"static credentials"},
+		},
+		{
+			Description: "credential process and watched file",
+			Config: &Config{
+				CredentialProcess:    &CredentialProcess{Command: "get-credentials"},
+				WatchedCredsFilename: "/run/secrets/aws",
+			},
+			ExpectedSources: []string{`credentials file "/run/secrets/aws"`, `credential process "get-credentials"`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			for k, v := range testCase.EnvironmentVars {
+				t.Setenv(k, v)
+			}
+
+			sources := configuredCredentialSources(testCase.Config, defaultCredentialSourceOrder)
+			if !reflect.DeepEqual(sources, testCase.ExpectedSources) {
+				t.Errorf("Expected sources %q, got %q", testCase.ExpectedSources, sources)
+			}
+		})
+	}
+}
+
+func TestGetCredentials_strictCredentialSources(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "envAccessKey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envSecretKey")
+
+	config := &Config{
+		AccessKey:               "accessKey",
+		SecretKey:               "secretKey",
+		SkipMetadataApiCheck:    true,
+		StrictCredentialSources: true,
+	}
+
+	_, err := GetCredentials(config)
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+	if expected := "multiple credential sources configured (static credentials, environment variables)"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error containing %q, got %q", expected, err)
+	}
+
+	config.StrictCredentialSources = false
+	creds, err := GetCredentials(config)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if value.AccessKeyID != "accessKey" {
+		t.Errorf("Expected static access key, got %q", value.AccessKeyID)
+	}
+}
+
+func TestGetCredentials_strictCredentialSourcesResolvable(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte("[default]\naws_access_key_id = profileAccessKey\naws_secret_access_key = profileSecretKey\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description   string
+		Config        *Config
+		ExpectedError string
+	}{
+		{
+			Description: "static credentials and shared credentials file",
+			Config: &Config{
+				AccessKey:               "accessKey",
+				SecretKey:               "secretKey",
+				CredsFilename:           filename,
+				SkipMetadataApiCheck:    true,
+				StrictCredentialSources: true,
+			},
+			ExpectedError: "multiple credential sources have credentials",
+		},
+		{
+			Description: "static credentials and missing shared credentials file",
+			Config: &Config{
+				AccessKey:               "accessKey",
+				SecretKey:               "secretKey",
+				CredsFilename:           filepath.Join(t.TempDir(), "missing"),
+				SkipMetadataApiCheck:    true,
+				StrictCredentialSources: true,
+			},
+		},
+		{
+			Description: "static credentials and ignored shared credentials file",
+			Config: &Config{
+				AccessKey:                   "accessKey",
+				SecretKey:                   "secretKey",
+				CredsFilename:               filename,
+				IgnoreSharedCredentialsFile: true,
+				SkipMetadataApiCheck:        true,
+				StrictCredentialSources:     true,
+			},
+		},
+		{
+			Description: "not strict",
+			Config: &Config{
+				AccessKey:            "accessKey",
+				SecretKey:            "secretKey",
+				CredsFilename:        filename,
+				SkipMetadataApiCheck: true,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			creds, err := GetCredentials(testCase.Config)
+			if testCase.ExpectedError != "" {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				if !strings.Contains(err.Error(), testCase.ExpectedError) {
+					t.Errorf("Expected error containing %q, got %q", testCase.ExpectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != "accessKey" {
+				t.Errorf("Expected static access key, got %q", value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestCredentialSourceOrder(t *testing.T) {
+	var testCases = []struct {
+		Description   string
+		Order         []CredentialSource
+		ExpectedOrder []CredentialSource
+		ExpectedError string
+	}{
+		{
+			Description:   "default",
+			ExpectedOrder: defaultCredentialSourceOrder,
+		},
+		{
+			Description: "shared credentials before environment variables",
+			Order:       []CredentialSource{CredentialSourceSharedCredentials},
+			ExpectedOrder: []CredentialSource{
+				CredentialSourceSharedCredentials,
+				CredentialSourceStatic,
+				CredentialSourceFunction,
+				CredentialSourceWatchedFile,
+				CredentialSourceKeychain,
+				CredentialSourceProcess,
+				CredentialSourceRolesAnywhere,
+				CredentialSourceEnvironment,
+				CredentialSourceWebIdentity,
+				CredentialSourceContainer,
+				CredentialSourceEC2InstanceProfile,
+			},
+		},
+		{
+			Description: "instance profile first",
+			Order:       []CredentialSource{CredentialSourceEC2InstanceProfile, CredentialSourceEnvironment},
+			ExpectedOrder: []CredentialSource{
+				CredentialSourceEC2InstanceProfile,
+				CredentialSourceEnvironment,
+				CredentialSourceStatic,
+				CredentialSourceFunction,
+				CredentialSourceWatchedFile,
+				CredentialSourceKeychain,
+				CredentialSourceProcess,
+				CredentialSourceRolesAnywhere,
+				CredentialSourceSharedCredentials,
+				CredentialSourceWebIdentity,
+				CredentialSourceContainer,
+			},
+		},
+		{
+			Description:   "unknown source",
+			Order:         []CredentialSource{"vault"},
+			ExpectedError: `unknown credential source "vault"`,
+		},
+		{
+			Description:   "duplicate source",
+			Order:         []CredentialSource{CredentialSourceEnvironment, CredentialSourceEnvironment},
+			ExpectedError: `duplicate credential source "environment"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			order, err := credentialSourceOrder(&Config{CredentialSourceOrder: testCase.Order})
+			if testCase.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.ExpectedError) {
+					t.Fatalf("Expected error containing %q, got %v", testCase.ExpectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if !reflect.DeepEqual(order, testCase.ExpectedOrder) {
+				t.Errorf("Expected order %q, got %q", testCase.ExpectedOrder, order)
+			}
+		})
+	}
+}
+
+func TestGetCredentials_credentialSourceOrder(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "envAccessKey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envSecretKey")
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte("[myprofile]\naws_access_key_id = profileAccessKey\naws_secret_access_key = profileSecretKey\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description       string
+		Order             []CredentialSource
+		ExpectedAccessKey string
+	}{
+		{
+			Description:       "default",
+			ExpectedAccessKey: "envAccessKey",
+		},
+		{
+			Description:       "shared credentials first",
+			Order:             []CredentialSource{CredentialSourceSharedCredentials},
+			ExpectedAccessKey: "profileAccessKey",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			creds, err := GetCredentials(&Config{
+				CredentialSourceOrder: testCase.Order,
+				CredsFilename:         filename,
+				Profile:               "myprofile",
+				SkipMetadataApiCheck:  true,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != testCase.ExpectedAccessKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedAccessKey, value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestGetCredentials_ignoreEnvCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "envAccessKey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envSecretKey")
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte("[default]\naws_access_key_id = profileAccessKey\naws_secret_access_key = profileSecretKey\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description          string
+		CredsFilename        string
+		IgnoreEnvCredentials bool
+		ExpectedAccessKey    string
+	}{
+		{
+			Description:       "environment variables",
+			CredsFilename:     filename,
+			ExpectedAccessKey: "envAccessKey",
+		},
+		{
+			Description:          "ignored environment variables",
+			CredsFilename:        filename,
+			IgnoreEnvCredentials: true,
+			ExpectedAccessKey:    "profileAccessKey",
+		},
+		{
+			Description:          "no other credentials",
+			CredsFilename:        filepath.Join(t.TempDir(), "missing"),
+			IgnoreEnvCredentials: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			creds, err := GetCredentials(&Config{
+				CredsFilename:        testCase.CredsFilename,
+				IgnoreEnvCredentials: testCase.IgnoreEnvCredentials,
+				SkipMetadataApiCheck: true,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			value, err := creds.Get()
+			if testCase.ExpectedAccessKey == "" {
+				if err == nil {
+					t.Fatalf("Expected error, got access key %q", value.AccessKeyID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != testCase.ExpectedAccessKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedAccessKey, value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestGetCredentials_ignoreSharedCredentialsFile(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte("[default]\naws_access_key_id = profileAccessKey\naws_secret_access_key = profileSecretKey\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description                 string
+		IgnoreSharedCredentialsFile bool
+		ExpectedAccessKey           string
+	}{
+		{
+			Description:       "shared credentials file",
+			ExpectedAccessKey: "profileAccessKey",
+		},
+		{
+			Description:                 "ignored shared credentials file",
+			IgnoreSharedCredentialsFile: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			creds, err := GetCredentials(&Config{
+				CredsFilename:               filename,
+				IgnoreSharedCredentialsFile: testCase.IgnoreSharedCredentialsFile,
+				SkipMetadataApiCheck:        true,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			value, err := creds.Get()
+			if testCase.ExpectedAccessKey == "" {
+				if err == nil {
+					t.Fatalf("Expected error, got access key %q", value.AccessKeyID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != testCase.ExpectedAccessKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedAccessKey, value.AccessKeyID)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package awsbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FromJSON returns the Config described by the JSON object, e.g. of a
+// configuration file of the application, as FromMap does.
+func FromJSON(data []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("error parsing Config JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("error parsing Config JSON: unexpected data after object")
+	}
+
+	return FromMap(m)
+}
+
+// FromMap returns the Config described by the map, whose keys are the names of
+// the json struct tags of the Config fields, e.g. "region" and "assume_role",
+// and whose values are those of decoded JSON or HCL: strings, booleans,
+// numbers, lists, and maps for nested settings. Durations are strings in the
+// format of time.ParseDuration, e.g. "1h". Fields which cannot be serialized,
+// e.g. HTTPClient, cannot be set.
+//
+// Unknown keys, values of the wrong type, and invalid settings, e.g. of
+// AssumeRole, are reported as errors.
+func FromMap(m map[string]interface{}) (*Config, error) {
+	c := &Config{}
+	if err := decodeValue("", reflect.ValueOf(c).Elem(), m); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(c); err != nil {
+		return nil, fmt.Errorf("invalid Config: %w", err)
+	}
+	return c, nil
+}
+
+// decodeValue sets the value, at the given path of the map, to the raw value.
+func decodeValue(path string, v reflect.Value, raw interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(raw)
+
+	if v.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected duration string, e.g. \"30s\", got %T", path, raw)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, raw)
+		}
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, raw)
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := decodeInt(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%s: %d out of range", path, n)
+		}
+		v.SetInt(n)
+
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(path, elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+
+	case reflect.Slice:
+		if rv.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: expected list, got %T", path, raw)
+		}
+		slice := reflect.MakeSlice(v.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := decodeValue(fmt.Sprintf("%s[%d]", path, i), slice.Index(i), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Map:
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected map, got %T", path, raw)
+		}
+		m := reflect.MakeMapWithSize(v.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(fmt.Sprintf("%s[%q]", path, iter.Key().String()), elem, iter.Value().Interface()); err != nil {
+				return err
+			}
+			m.SetMapIndex(iter.Key().Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected object, got %T", path, raw)
+		}
+		fields := decodableFields(v.Type())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			i, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("%s: unknown field", fieldPath)
+			}
+			if err := decodeValue(fieldPath, v.Field(i), iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%s: cannot decode %s", path, v.Type())
+	}
+
+	return nil
+}
+
+// decodableFields returns the indexes of the fields of the struct type by the
+// names of their json struct tags, omitting fields which cannot be
+// serialized.
+func decodableFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// decodeInt returns the integer value of a decoded number.
+func decodeInt(raw interface{}) (int64, error) {
+	switch n := raw.(type) {
+	case json.Number:
+		return n.Int64()
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("expected integer, got %v", n)
+		}
+		return int64(n), nil
+	}
+
+	rv := reflect.ValueOf(raw)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%d out of range", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("expected integer, got %T", raw)
+}
+
+// validateConfig checks the settings of a decoded Config which can be
+// checked without making API calls.
+func validateConfig(c *Config) error {
+	var errs []error
+
+	if c.Region != "" {
+		if err := ValidateRegionFormat(c.Region); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := c.DefaultsMode.settings(); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.IPAddressFamily {
+	case IPAddressFamilyDualStack, IPAddressFamilyIPv4Only, IPAddressFamilyIPv6Only, IPAddressFamilyIPv4Preferred, IPAddressFamilyIPv6Preferred:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IP address family %q", c.IPAddressFamily))
+	}
+	if _, err := credentialSourceOrder(c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.AssumeRole != nil {
+		if err := c.AssumeRole.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid assume role configuration: %w", err))
+		}
+	}
+	if c.FederationToken != nil {
+		if err := c.FederationToken.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid federation token configuration: %w", err))
+		}
+	}
+	if c.RolesAnywhere != nil {
+		if err := c.RolesAnywhere.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid IAM Roles Anywhere configuration: %w", err))
+		}
+	}
+	if c.CredentialProcess != nil && c.CredentialProcess.Command == "" {
+		errs = append(errs, errors.New("credential process command must be set"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,162 @@
+package awsbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromJSON(t *testing.T) {
+	testCases := []struct {
+		Description   string
+		JSON          string
+		Expected      *Config
+		ExpectedError string
+	}{
+		{
+			Description: "settings",
+			JSON: `{
+				"region": "us-west-2",
+				"max_retries": 5,
+				"skip_metadata_api_check": true,
+				"sts_call_timeout": "30s",
+				"credential_source_order": ["shared-credentials"],
+				"service_max_retries": {"s3": 10},
+				"assume_role": {
+					"role_arn": "arn:aws:iam::555555555555:role/AssumeRole",
+					"duration": "1h",
+					"tags": {"team": "platform"}
+				},
+				"user_agent_products": [{"name": "app", "version": "1.0"}]
+			}`,
+			Expected: &Config{
+				AssumeRole: &AssumeRole{
+					Duration: time.Hour,
+					RoleARN:  "arn:aws:iam::555555555555:role/AssumeRole",
+					Tags:     map[string]string{"team": "platform"},
+				},
+				CredentialSourceOrder: []CredentialSource{CredentialSourceSharedCredentials},
+				MaxRetries:            5,
+				Region:                "us-west-2",
+				ServiceMaxRetries:     map[string]int{"s3": 10},
+				SkipMetadataApiCheck:  true,
+				StsCallTimeout:        30 * time.Second,
+				UserAgentProducts:     []*UserAgentProduct{{Name: "app", Version: "1.0"}},
+			},
+		},
+		{
+			Description: "null values",
+			JSON:        `{"region": null, "assume_role": null}`,
+			Expected:    &Config{},
+		},
+		{
+			Description:   "unknown field",
+			JSON:          `{"regoin": "us-west-2"}`,
+			ExpectedError: "regoin: unknown field",
+		},
+		{
+			Description:   "unserializable field",
+			JSON:          `{"HTTPClient": {}}`,
+			ExpectedError: "HTTPClient: unknown field",
+		},
+		{
+			Description:   "unknown nested field",
+			JSON:          `{"assume_role": {"role": "arn:aws:iam::555555555555:role/AssumeRole"}}`,
+			ExpectedError: "assume_role.role: unknown field",
+		},
+		{
+			Description:   "wrong type",
+			JSON:          `{"max_retries": "5"}`,
+			ExpectedError: "max_retries: expected integer",
+		},
+		{
+			Description:   "fractional number",
+			JSON:          `{"max_retries": 2.5}`,
+			ExpectedError: "max_retries:",
+		},
+		{
+			Description:   "numeric duration",
+			JSON:          `{"sts_call_timeout": 30}`,
+			ExpectedError: "sts_call_timeout: expected duration string",
+		},
+		{
+			Description:   "wrong list element type",
+			JSON:          `{"sigv4a_services": ["s3", 1]}`,
+			ExpectedError: "sigv4a_services[1]: expected string",
+		},
+		{
+			Description:   "invalid region",
+			JSON:          `{"region": "US-WEST-2"}`,
+			ExpectedError: "invalid Config",
+		},
+		{
+			Description:   "invalid assume role",
+			JSON:          `{"assume_role": {"role_arn": "AssumeRole"}}`,
+			ExpectedError: "invalid assume role configuration",
+		},
+		{
+			Description:   "invalid defaults mode",
+			JSON:          `{"defaults_mode": "fast"}`,
+			ExpectedError: `invalid defaults mode "fast"`,
+		},
+		{
+			Description:   "invalid JSON",
+			JSON:          `{"region": }`,
+			ExpectedError: "error parsing Config JSON",
+		},
+		{
+			Description:   "trailing data",
+			JSON:          `{} {}`,
+			ExpectedError: "unexpected data after object",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			c, err := FromJSON([]byte(testCase.JSON))
+
+			if testCase.ExpectedError != "" {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				if !strings.Contains(err.Error(), testCase.ExpectedError) {
+					t.Errorf("Expected error containing %q, got %q", testCase.ExpectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if !reflect.DeepEqual(c, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, c)
+			}
+		})
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	c, err := FromMap(map[string]interface{}{
+		"max_retries":     3,
+		"profile":         "default",
+		"sigv4a_services": []string{"s3"},
+		"proxy_rules": []map[string]interface{}{
+			{"hosts": "*.internal", "proxy": ""},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	expected := &Config{
+		MaxRetries:     3,
+		Profile:        "default",
+		ProxyRules:     []ProxyRule{{Hosts: "*.internal"}},
+		SigV4AServices: []string{"s3"},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+}
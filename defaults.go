@@ -0,0 +1,167 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// DefaultsMode selects a bundle of default settings for timeouts, endpoint
+// choices, and retries, matching the defaults modes of the AWS Go SDK v2.
+// Settings of the Config, e.g. S3UsEast1RegionalEndpoint and MaxRetries, take
+// precedence over those of the mode.
+type DefaultsMode string
+
+const (
+	// DefaultsModeLegacy keeps the AWS Go SDK defaults. It is the default.
+	DefaultsModeLegacy DefaultsMode = "legacy"
+	// DefaultsModeStandard provides defaults suitable for most applications.
+	DefaultsModeStandard DefaultsMode = "standard"
+	// DefaultsModeInRegion is optimized for applications calling AWS services
+	// in the same region, with lower connection timeouts.
+	DefaultsModeInRegion DefaultsMode = "in-region"
+	// DefaultsModeCrossRegion is optimized for applications calling AWS
+	// services in other regions, with higher connection timeouts.
+	DefaultsModeCrossRegion DefaultsMode = "cross-region"
+	// DefaultsModeMobile is optimized for applications on mobile networks,
+	// with much higher connection timeouts.
+	DefaultsModeMobile DefaultsMode = "mobile"
+)
+
+// retryMode is the retry behavior of a DefaultsMode, as defined by the AWS
+// SDKs' shared retry behavior.
+type retryMode string
+
+const (
+	// retryModeLegacy keeps the AWS Go SDK default retryer.
+	retryModeLegacy retryMode = "legacy"
+	// retryModeStandard retries with the backoff of the standard retry mode,
+	// whose delays are capped at standardRetryMaxBackoff.
+	retryModeStandard retryMode = "standard"
+)
+
+// standardRetryMaxBackoff is the maximum delay between retries of the
+// standard retry mode.
+const standardRetryMaxBackoff = 20 * time.Second
+
+// defaultsModeSettings are the settings a DefaultsMode applies. Zero values
+// leave the AWS Go SDK defaults in place.
+type defaultsModeSettings struct {
+	ConnectTimeout time.Duration
+	// MaxRetries is the maximum number of retries of requests, unless the
+	// MaxRetries of the Config is set.
+	MaxRetries            int
+	RegionalEndpoints     bool
+	RetryMode             retryMode
+	TLSNegotiationTimeout time.Duration
+}
+
+// defaultsModes contains the settings of each DefaultsMode, as defined by the
+// AWS SDKs' shared defaults configuration.
+var defaultsModes = map[DefaultsMode]defaultsModeSettings{
+	DefaultsModeLegacy: {
+		RetryMode: retryModeLegacy,
+	},
+	DefaultsModeStandard: {
+		ConnectTimeout:        3100 * time.Millisecond,
+		MaxRetries:            2,
+		RegionalEndpoints:     true,
+		RetryMode:             retryModeStandard,
+		TLSNegotiationTimeout: 3100 * time.Millisecond,
+	},
+	DefaultsModeInRegion: {
+		ConnectTimeout:        1100 * time.Millisecond,
+		MaxRetries:            2,
+		RegionalEndpoints:     true,
+		RetryMode:             retryModeStandard,
+		TLSNegotiationTimeout: 1100 * time.Millisecond,
+	},
+	DefaultsModeCrossRegion: {
+		ConnectTimeout:        3100 * time.Millisecond,
+		MaxRetries:            2,
+		RegionalEndpoints:     true,
+		RetryMode:             retryModeStandard,
+		TLSNegotiationTimeout: 3100 * time.Millisecond,
+	},
+	DefaultsModeMobile: {
+		ConnectTimeout:        30 * time.Second,
+		MaxRetries:            2,
+		RegionalEndpoints:     true,
+		RetryMode:             retryModeStandard,
+		TLSNegotiationTimeout: 30 * time.Second,
+	},
+}
+
+// settings returns the settings of the mode, treating an empty mode as
+// DefaultsModeLegacy.
+func (m DefaultsMode) settings() (defaultsModeSettings, error) {
+	if m == "" {
+		m = DefaultsModeLegacy
+	}
+
+	settings, ok := defaultsModes[m]
+	if !ok {
+		return defaultsModeSettings{}, fmt.Errorf("invalid defaults mode %q", m)
+	}
+	return settings, nil
+}
+
+// configureDefaultsMode configures the given transport, built by this package,
+// with the timeouts of the DefaultsMode of the Config, and with the dialer of
+// the Config, which applies the connect timeout.
+func configureDefaultsMode(c *Config, transport *http.Transport) error {
+	settings, err := c.DefaultsMode.settings()
+	if err != nil {
+		return err
+	}
+
+	if settings.TLSNegotiationTimeout > 0 {
+		transport.TLSHandshakeTimeout = settings.TLSNegotiationTimeout
+	}
+
+	return configureDialer(c, transport)
+}
+
+// defaultsModeEndpoints returns the regional STS and S3 us-east-1 endpoint
+// settings of the DefaultsMode of the Config, or unset values if the mode
+// leaves the AWS Go SDK defaults in place.
+func defaultsModeEndpoints(c *Config) (endpoints.STSRegionalEndpoint, endpoints.S3UsEast1RegionalEndpoint, error) {
+	settings, err := c.DefaultsMode.settings()
+	if err != nil {
+		return endpoints.UnsetSTSEndpoint, endpoints.UnsetS3UsEast1Endpoint, err
+	}
+
+	if !settings.RegionalEndpoints {
+		return endpoints.UnsetSTSEndpoint, endpoints.UnsetS3UsEast1Endpoint, nil
+	}
+	return endpoints.RegionalSTSEndpoint, endpoints.RegionalS3UsEast1Endpoint, nil
+}
+
+// defaultsModeRetryer returns the retryer of the DefaultsMode of the Config,
+// which makes the MaxRetries of the Config, or else of the mode, retries with
+// the backoff of its retry mode, or nil if the mode keeps the AWS Go SDK
+// default retryer.
+func defaultsModeRetryer(c *Config) (request.Retryer, error) {
+	settings, err := c.DefaultsMode.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.RetryMode != retryModeStandard {
+		return nil, nil
+	}
+
+	maxRetries := settings.MaxRetries
+	if c.MaxRetries > 0 {
+		maxRetries = c.MaxRetries
+	}
+	return client.DefaultRetryer{
+		NumMaxRetries:    maxRetries,
+		MaxRetryDelay:    standardRetryMaxBackoff,
+		MaxThrottleDelay: standardRetryMaxBackoff,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package awsbase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiagnosticSeverity is the severity of a Diagnostic.
+type DiagnosticSeverity string
+
+const (
+	// DiagnosticSeverityError marks a problem which caused the operation to
+	// fail.
+	DiagnosticSeverityError DiagnosticSeverity = "error"
+	// DiagnosticSeverityWarning marks a problem which the operation recovered
+	// from, e.g. by falling back to another method.
+	DiagnosticSeverityWarning DiagnosticSeverity = "warning"
+)
+
+// Diagnostic is a problem encountered while resolving credentials or account
+// information, with the source it came from and a hint for resolving it.
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	// Source is the credential provider or API call the problem came from,
+	// e.g. sts:GetCallerIdentity.
+	Source string
+	Err    error
+	// Remediation is a hint for resolving the problem, if any.
+	Remediation string
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s: %s", d.Source, d.Err)
+	if d.Remediation != "" {
+		s += fmt.Sprintf(" (%s)", d.Remediation)
+	}
+	return s
+}
+
+// Diagnostics are the problems encountered by an operation, in order. They
+// implement error, so that they can be returned in place of combined errors,
+// and errors.Is and errors.As match the error of any diagnostic.
+type Diagnostics []Diagnostic
+
+// Append returns the diagnostics with a diagnostic for the error appended, or
+// unchanged if the error is nil.
+func (d Diagnostics) Append(severity DiagnosticSeverity, source string, err error, remediation string) Diagnostics {
+	if err == nil {
+		return d
+	}
+	return append(d, Diagnostic{
+		Severity:    severity,
+		Source:      source,
+		Err:         err,
+		Remediation: remediation,
+	})
+}
+
+// HasErrors returns whether any diagnostic has DiagnosticSeverityError.
+func (d Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d {
+		if diagnostic.Severity == DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorOrNil returns the diagnostics as an error if any has
+// DiagnosticSeverityError, or nil otherwise.
+func (d Diagnostics) ErrorOrNil() error {
+	if d.HasErrors() {
+		return d
+	}
+	return nil
+}
+
+func (d Diagnostics) Error() string {
+	if len(d) == 1 {
+		return d[0].String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems occurred:", len(d))
+	for _, diagnostic := range d {
+		fmt.Fprintf(&b, "\n\t* %s: %s", diagnostic.Severity, diagnostic)
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the diagnostics.
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, diagnostic := range d {
+		errs[i] = diagnostic.Err
+	}
+	return errs
+}
@@ -0,0 +1,38 @@
+package awsbase
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	errDenied := errors.New("access denied")
+
+	var diags Diagnostics
+	diags = diags.Append(DiagnosticSeverityWarning, "iam:GetUser", errDenied, "allow iam:GetUser")
+	diags = diags.Append(DiagnosticSeverityError, "sts:GetCallerIdentity", nil, "")
+
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %d", len(diags))
+	}
+	if err := diags.ErrorOrNil(); err != nil {
+		t.Fatalf("Expected no error for warnings, received error: %s", err)
+	}
+
+	diags = diags.Append(DiagnosticSeverityError, "iam:ListRoles", errors.New("throttled"), "")
+
+	err := diags.ErrorOrNil()
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+	if !errors.Is(err, errDenied) {
+		t.Errorf("Expected error to match %q", errDenied)
+	}
+
+	expected := "2 problems occurred:\n" +
+		"\t* warning: iam:GetUser: access denied (allow iam:GetUser)\n" +
+		"\t* error: iam:ListRoles: throttled"
+	if actual := err.Error(); actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,202 @@
+package awsbase
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DialContextFunc dials connections for the HTTP clients built by this
+// package, as http.Transport.DialContext does.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// IPAddressFamily selects the IP address families connections are made with.
+type IPAddressFamily string
+
+const (
+	// IPAddressFamilyDualStack connects over IPv4 or IPv6, racing both as
+	// described by RFC 6555 (Happy Eyeballs), in the order of the addresses
+	// returned by the resolver. It is the default.
+	IPAddressFamilyDualStack IPAddressFamily = ""
+	// IPAddressFamilyIPv4Only only connects over IPv4.
+	IPAddressFamilyIPv4Only IPAddressFamily = "ipv4"
+	// IPAddressFamilyIPv6Only only connects over IPv6, e.g. in IPv6-only
+	// networks.
+	IPAddressFamilyIPv6Only IPAddressFamily = "ipv6"
+	// IPAddressFamilyIPv4Preferred connects over IPv4, falling back to IPv6
+	// if no IPv4 connection is made within the fallback delay.
+	IPAddressFamilyIPv4Preferred IPAddressFamily = "ipv4-preferred"
+	// IPAddressFamilyIPv6Preferred connects over IPv6, falling back to IPv4
+	// if no IPv6 connection is made within the fallback delay.
+	IPAddressFamilyIPv6Preferred IPAddressFamily = "ipv6-preferred"
+)
+
+// defaultDialFallbackDelay is the delay before falling back to the other IP
+// address family, as in net.Dialer.
+const defaultDialFallbackDelay = 300 * time.Millisecond
+
+// NewDialContext returns the DialContextFunc for HTTP clients built for the
+// Config: its DialContext, or a dialer using its Resolver, DialFallbackDelay,
+// and the given connect timeout, restricted to or preferring its
+// IPAddressFamily. It returns nil if the Config sets none of these, in which
+// case the dialer of the transport is kept.
+func NewDialContext(c *Config, connectTimeout time.Duration) (DialContextFunc, error) {
+	dial := c.DialContext
+	if dial == nil && (c.Resolver != nil || c.DialFallbackDelay != 0 || c.IPAddressFamily != IPAddressFamilyDualStack || connectTimeout > 0) {
+		// The defaults of go-cleanhttp, which builds the transports.
+		dialer := &net.Dialer{
+			Timeout:       30 * time.Second,
+			KeepAlive:     30 * time.Second,
+			FallbackDelay: c.DialFallbackDelay,
+			Resolver:      c.Resolver,
+		}
+		if connectTimeout > 0 {
+			dialer.Timeout = connectTimeout
+		}
+		dial = dialer.DialContext
+	}
+
+	fallbackDelay := c.DialFallbackDelay
+	if fallbackDelay == 0 {
+		fallbackDelay = defaultDialFallbackDelay
+	}
+
+	switch c.IPAddressFamily {
+	case IPAddressFamilyDualStack:
+		return dial, nil
+	case IPAddressFamilyIPv4Only:
+		return familyDialContext(dial, "4", "", 0), nil
+	case IPAddressFamilyIPv6Only:
+		return familyDialContext(dial, "6", "", 0), nil
+	case IPAddressFamilyIPv4Preferred:
+		return familyDialContext(dial, "4", "6", fallbackDelay), nil
+	case IPAddressFamilyIPv6Preferred:
+		return familyDialContext(dial, "6", "4", fallbackDelay), nil
+	default:
+		return nil, fmt.Errorf("invalid IP address family %q", c.IPAddressFamily)
+	}
+}
+
+// familyDialContext returns a DialContextFunc which dials TCP connections
+// over the primary IP address family, e.g. "6" for IPv6, and, if set, races
+// a connection over the fallback family once the fallback delay passed
+// without a connection, or the primary dial failed. A negative fallback delay
+// only falls back after the primary dial failed.
+func familyDialContext(dial DialContextFunc, primary, fallback string, fallbackDelay time.Duration) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network != "tcp" {
+			return dial(ctx, network, address)
+		}
+		if fallback == "" {
+			return dial(ctx, network+primary, address)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			conn    net.Conn
+			err     error
+			primary bool
+		}
+		results := make(chan result, 2)
+		race := func(family string, primary bool) {
+			conn, err := dial(ctx, network+family, address)
+			results <- result{conn: conn, err: err, primary: primary}
+		}
+
+		go race(primary, true)
+
+		var timer <-chan time.Time
+		if fallbackDelay >= 0 {
+			fallbackTimer := time.NewTimer(fallbackDelay)
+			defer fallbackTimer.Stop()
+			timer = fallbackTimer.C
+		}
+
+		var firstErr error
+		started, pending := 1, 1
+		for pending > 0 {
+			select {
+			case <-timer:
+				timer = nil
+				if started == 1 {
+					started, pending = 2, pending+1
+					go race(fallback, false)
+				}
+			case r := <-results:
+				pending--
+				if r.err == nil {
+					// Close the connection of the other dial, if it
+					// completes.
+					go func(pending int) {
+						for ; pending > 0; pending-- {
+							if r := <-results; r.conn != nil {
+								r.conn.Close()
+							}
+						}
+					}(pending)
+					return r.conn, nil
+				}
+				if firstErr == nil || r.primary {
+					firstErr = r.err
+				}
+				if started == 1 {
+					started, pending = 2, pending+1
+					go race(fallback, false)
+				}
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// configureDialer configures the given transport, built by this package, to
+// dial connections as configured by the Config, with the connect timeout of
+// its DefaultsMode, and to authenticate to HTTPS proxies with its proxy
+// client certificate.
+func configureDialer(c *Config, transport *http.Transport) error {
+	settings, err := c.DefaultsMode.settings()
+	if err != nil {
+		return err
+	}
+
+	dial, err := NewDialContext(c, settings.ConnectTimeout)
+	if err != nil {
+		return err
+	}
+	if dial != nil {
+		transport.DialContext = dial
+	}
+	return ConfigureProxyClientCertificate(c, transport)
+}
+
+// PinnedHostsDialContext returns a DialContextFunc which connects to the IP
+// addresses of the given hosts instead of resolving them, e.g. to pin AWS
+// endpoints to the addresses of VPC endpoints in split-horizon DNS
+// environments. Hosts are matched exactly, e.g. sts.us-west-2.amazonaws.com,
+// and connections to other hosts are dialed as usual. TLS certificates are
+// still verified against the host names. If dial is nil, a net.Dialer is
+// used.
+func PinnedHostsDialContext(hosts map[string]string, dial DialContextFunc) DialContextFunc {
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return dial(ctx, network, address)
+		}
+		if ip, ok := hosts[host]; ok {
+			logger.Printf("[DEBUG] Connecting to %s at pinned address %s", host, ip)
+			address = net.JoinHostPort(ip, port)
+		}
+		return dial(ctx, network, address)
+	}
+}
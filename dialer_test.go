@@ -0,0 +1,204 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPinnedHostsDialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: PinnedHostsDialContext(map[string]string{"sts.us-west-2.amazonaws.com": host}, nil),
+		},
+	}
+
+	resp, err := client.Get("http://" + net.JoinHostPort("sts.us-west-2.amazonaws.com", port))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestGetSessionOptions_dialContext(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	var dials int32
+	dialer := &net.Dialer{}
+	config := &Config{
+		AccessKey:           "StaticAccessKey",
+		DefaultsMode:        DefaultsModeStandard,
+		SecretKey:           "StaticSecretKey",
+		SkipCredsValidation: true,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+
+	options, err := GetSessionOptions(config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	resp, err := options.Config.HTTPClient.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	resp.Body.Close()
+
+	if atomic.LoadInt32(&dials) != 1 {
+		t.Errorf("Expected 1 dial, got %d", dials)
+	}
+}
+
+func TestNewDialContext_ipAddressFamily(t *testing.T) {
+	errPrimary := errors.New("primary failed")
+	errFallback := errors.New("fallback failed")
+
+	testCases := []struct {
+		Description      string
+		IPAddressFamily  IPAddressFamily
+		Dial             map[string]func(ctx context.Context) (net.Conn, error)
+		ExpectedNetworks []string
+		ExpectedErr      error
+	}{
+		{
+			Description:      "IPv6 only",
+			IPAddressFamily:  IPAddressFamilyIPv6Only,
+			ExpectedNetworks: []string{"tcp6"},
+		},
+		{
+			Description:      "IPv4 only",
+			IPAddressFamily:  IPAddressFamilyIPv4Only,
+			ExpectedNetworks: []string{"tcp4"},
+		},
+		{
+			Description:      "IPv6 preferred",
+			IPAddressFamily:  IPAddressFamilyIPv6Preferred,
+			ExpectedNetworks: []string{"tcp6"},
+		},
+		{
+			Description:     "IPv6 preferred with slow IPv6",
+			IPAddressFamily: IPAddressFamilyIPv6Preferred,
+			Dial: map[string]func(ctx context.Context) (net.Conn, error){
+				"tcp6": func(ctx context.Context) (net.Conn, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			},
+			ExpectedNetworks: []string{"tcp6", "tcp4"},
+		},
+		{
+			Description:     "IPv4 preferred with failed IPv4",
+			IPAddressFamily: IPAddressFamilyIPv4Preferred,
+			Dial: map[string]func(ctx context.Context) (net.Conn, error){
+				"tcp4": func(ctx context.Context) (net.Conn, error) {
+					return nil, errPrimary
+				},
+			},
+			ExpectedNetworks: []string{"tcp4", "tcp6"},
+		},
+		{
+			Description:     "IPv6 preferred with both failed",
+			IPAddressFamily: IPAddressFamilyIPv6Preferred,
+			Dial: map[string]func(ctx context.Context) (net.Conn, error){
+				"tcp6": func(ctx context.Context) (net.Conn, error) {
+					return nil, errPrimary
+				},
+				"tcp4": func(ctx context.Context) (net.Conn, error) {
+					return nil, errFallback
+				},
+			},
+			ExpectedNetworks: []string{"tcp6", "tcp4"},
+			ExpectedErr:      errPrimary,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			var mu sync.Mutex
+			var networks []string
+
+			config := &Config{
+				DialFallbackDelay: 10 * time.Millisecond,
+				IPAddressFamily:   testCase.IPAddressFamily,
+				DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+					mu.Lock()
+					networks = append(networks, network)
+					mu.Unlock()
+
+					if dial, ok := testCase.Dial[network]; ok {
+						return dial(ctx)
+					}
+					client, server := net.Pipe()
+					server.Close()
+					return client, nil
+				},
+			}
+
+			dial, err := NewDialContext(config, 0)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %s", err)
+			}
+
+			conn, err := dial(context.Background(), "tcp", "sts.amazonaws.com:443")
+			if testCase.ExpectedErr != nil {
+				if !errors.Is(err, testCase.ExpectedErr) {
+					t.Fatalf("Expected error %q, got: %v", testCase.ExpectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got: %s", err)
+			} else {
+				conn.Close()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(networks) != len(testCase.ExpectedNetworks) {
+				t.Fatalf("Expected networks %v, got %v", testCase.ExpectedNetworks, networks)
+			}
+			for i, network := range testCase.ExpectedNetworks {
+				if networks[i] != network {
+					t.Errorf("Expected networks %v, got %v", testCase.ExpectedNetworks, networks)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDialContext_invalidIPAddressFamily(t *testing.T) {
+	if _, err := NewDialContext(&Config{IPAddressFamily: "ipv5"}, 0); err == nil {
+		t.Fatal("Expected error, got none")
+	}
+}
@@ -0,0 +1,150 @@
+package awsbase
+
+import (
+	"fmt"
+	"os"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// PlannedCredentialsProvider describes a credential provider GetCredentials
+// would consult for a Config, as determined by DryRunCredentials.
+type PlannedCredentialsProvider struct {
+	// Description is a human-readable description of the credential source,
+	// as recorded in CredentialsAttempt.Description.
+	Description string
+	// Network reports whether consulting the provider makes network calls,
+	// e.g. to STS or the EC2 metadata API.
+	Network bool
+	// Checked reports whether the provider was consulted by the dry run,
+	// which is only done for providers reading local files and environment
+	// variables.
+	Checked bool
+	// Found reports whether a checked provider has credentials. Providers
+	// after the first one found are not consulted, except to assume a role.
+	Found bool
+	// Note explains the outcome of the check, or why the provider was not
+	// checked.
+	Note string
+}
+
+// DryRunCredentials returns the credential providers GetCredentials would
+// consult for the Config, in order, without making any network calls or
+// running credential processes, e.g. for troubleshooting in environments
+// where STS calls are undesirable. Providers reading local files and
+// environment variables are checked for credentials, others are only listed.
+func DryRunCredentials(c *Config) []PlannedCredentialsProvider {
+	// GetCredentials returns an error for references to unset environment
+	// variables.
+	if expanded, err := ExpandConfigEnv(c); err == nil {
+		c = expanded
+	}
+
+	// GetCredentials returns an error for invalid orders.
+	order, err := credentialSourceOrder(c)
+	if err != nil {
+		order = defaultCredentialSourceOrder
+	}
+
+	sources := make(map[CredentialSource]PlannedCredentialsProvider)
+
+	check := func(source CredentialSource, provider awsCredentials.Provider, description string) {
+		planned := PlannedCredentialsProvider{
+			Description: description,
+			Checked:     true,
+			Note:        "credentials found",
+		}
+		if _, err := provider.Retrieve(); err != nil {
+			planned.Note = firstLine(err.Error())
+		} else {
+			planned.Found = true
+		}
+		sources[source] = planned
+	}
+	list := func(source CredentialSource, description string, network bool, note string) {
+		sources[source] = PlannedCredentialsProvider{
+			Description: description,
+			Network:     network,
+			Note:        note,
+		}
+	}
+
+	check(CredentialSourceStatic, &awsCredentials.StaticProvider{Value: awsCredentials.Value{
+		AccessKeyID:     c.AccessKey,
+		SecretAccessKey: c.SecretKey,
+		SessionToken:    c.Token,
+	}}, "static credentials")
+
+	if c.CredentialsProviderFunc != nil {
+		list(CredentialSourceFunction, "credentials function", false, "not called by dry run")
+	}
+
+	if c.WatchedCredsFilename != "" {
+		check(CredentialSourceWatchedFile, &FileCredentialsProvider{Filename: c.WatchedCredsFilename}, fmt.Sprintf("credentials file %q", c.WatchedCredsFilename))
+	}
+
+	if c.KeychainService != "" {
+		list(CredentialSourceKeychain, fmt.Sprintf("OS credential store service %q", c.KeychainService), false, "not read by dry run")
+	}
+
+	if c.CredentialProcess != nil {
+		list(CredentialSourceProcess, fmt.Sprintf("credential process %q", c.CredentialProcess.Command), false, "not run by dry run")
+	}
+
+	if c.RolesAnywhere != nil {
+		list(CredentialSourceRolesAnywhere, fmt.Sprintf("IAM Roles Anywhere role %s", c.RolesAnywhere.RoleARN), true, "calls rolesanywhere:CreateSession")
+	}
+
+	if !c.IgnoreEnvCredentials {
+		check(CredentialSourceEnvironment, &awsCredentials.EnvProvider{}, "environment variables")
+	}
+	if !c.IgnoreSharedCredentialsFile {
+		check(CredentialSourceSharedCredentials, newSharedCredentialsProvider(c), fmt.Sprintf("shared credentials profile %q", sharedCredentialsProfile(c.Profile)))
+	}
+
+	webIdentityRoleARN := os.Getenv(AssumeRoleARNEnvVar)
+	webIdentityConfigured := os.Getenv(webIdentityTokenFileEnvVar) != "" && webIdentityRoleARN != ""
+	if webIdentityConfigured {
+		list(CredentialSourceWebIdentity, fmt.Sprintf("web identity role %s", webIdentityRoleARN), true, "calls sts:AssumeRoleWithWebIdentity")
+	}
+
+	if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" {
+		list(CredentialSourceContainer, "ECS container credentials", true, "calls the ECS container credentials endpoint")
+	}
+
+	var plan []PlannedCredentialsProvider
+	localCredentialsFound := false
+	for _, source := range order {
+		if source == CredentialSourceEC2InstanceProfile && !c.SkipMetadataApiCheck {
+			switch {
+			case localCredentialsFound:
+				list(source, "EC2 instance profile", true, "skipped, as local credentials are found")
+			case webIdentityConfigured && credentialSourcePrecedes(order, CredentialSourceWebIdentity, source):
+				list(source, "EC2 instance profile", true, "skipped, as web identity credentials are configured")
+			default:
+				list(source, "EC2 instance profile", true, "added if the EC2 metadata API responds")
+			}
+		}
+
+		if planned, ok := sources[source]; ok {
+			localCredentialsFound = localCredentialsFound || planned.Found
+			plan = append(plan, planned)
+		}
+	}
+
+	if c.FederationToken != nil {
+		plan = append(plan, PlannedCredentialsProvider{
+			Description: fmt.Sprintf("federation token %s", c.FederationToken.Name),
+			Network:     true,
+			Note:        "calls sts:GetFederationToken with the credentials above",
+		})
+	} else if assumeRole := ResolveAssumeRole(c); assumeRole != nil {
+		plan = append(plan, PlannedCredentialsProvider{
+			Description: fmt.Sprintf("assumed role %s", assumeRole.RoleARN),
+			Network:     true,
+			Note:        "calls sts:AssumeRole with the credentials above",
+		})
+	}
+
+	return plan
+}
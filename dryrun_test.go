@@ -0,0 +1,122 @@
+package awsbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRunCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte(credentialsFileContents), 0600); err != nil {
+		t.Fatalf("Error writing temporary credentials file: %s", err)
+	}
+
+	plan := DryRunCredentials(&Config{
+		AssumeRole: &AssumeRole{
+			RoleARN: "arn:aws:iam::123456789012:role/example",
+		},
+		CredsFilename: filename,
+		Profile:       "myprofile",
+	})
+
+	expected := []PlannedCredentialsProvider{
+		{Description: "static credentials", Checked: true},
+		{Description: "environment variables", Checked: true},
+		{Description: `shared credentials profile "myprofile"`, Checked: true, Found: true},
+		{Description: "EC2 instance profile", Network: true},
+		{Description: "assumed role arn:aws:iam::123456789012:role/example", Network: true},
+	}
+	if len(plan) != len(expected) {
+		t.Fatalf("Expected %d providers, got %d: %+v", len(expected), len(plan), plan)
+	}
+	for i, e := range expected {
+		actual := plan[i]
+		if actual.Description != e.Description || actual.Network != e.Network || actual.Checked != e.Checked || actual.Found != e.Found {
+			t.Errorf("Expected provider %d to be %+v, got %+v", i, e, actual)
+		}
+	}
+	if note := plan[3].Note; note != "skipped, as local credentials are found" {
+		t.Errorf("Expected EC2 instance profile to be skipped, got note %q", note)
+	}
+}
+
+func TestDryRunCredentials_credentialSourceOrder(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte(credentialsFileContents), 0600); err != nil {
+		t.Fatalf("Error writing temporary credentials file: %s", err)
+	}
+
+	plan := DryRunCredentials(&Config{
+		CredentialSourceOrder: []CredentialSource{CredentialSourceEC2InstanceProfile, CredentialSourceSharedCredentials},
+		CredsFilename:         filename,
+		Profile:               "myprofile",
+	})
+
+	expected := []PlannedCredentialsProvider{
+		{Description: "EC2 instance profile", Network: true},
+		{Description: `shared credentials profile "myprofile"`, Checked: true, Found: true},
+		{Description: "static credentials", Checked: true},
+		{Description: "environment variables", Checked: true},
+	}
+	if len(plan) != len(expected) {
+		t.Fatalf("Expected %d providers, got %d: %+v", len(expected), len(plan), plan)
+	}
+	for i, e := range expected {
+		actual := plan[i]
+		if actual.Description != e.Description || actual.Network != e.Network || actual.Checked != e.Checked || actual.Found != e.Found {
+			t.Errorf("Expected provider %d to be %+v, got %+v", i, e, actual)
+		}
+	}
+	if note := plan[0].Note; note != "added if the EC2 metadata API responds" {
+		t.Errorf("Expected EC2 instance profile to be added, got note %q", note)
+	}
+}
+
+func TestDryRunCredentials_ignoreEnvCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "envAccessKey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envSecretKey")
+
+	plan := DryRunCredentials(&Config{
+		IgnoreEnvCredentials: true,
+		SkipMetadataApiCheck: true,
+	})
+
+	for _, planned := range plan {
+		if planned.Description == "environment variables" {
+			t.Errorf("Expected environment variables to be ignored, got %+v", planned)
+		}
+	}
+}
+
+func TestDryRunCredentials_ignoreSharedCredentialsFile(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(filename, []byte(credentialsFileContents), 0600); err != nil {
+		t.Fatalf("Error writing temporary credentials file: %s", err)
+	}
+
+	plan := DryRunCredentials(&Config{
+		CredsFilename:               filename,
+		IgnoreSharedCredentialsFile: true,
+		Profile:                     "myprofile",
+		SkipMetadataApiCheck:        true,
+	})
+
+	for _, planned := range plan {
+		if planned.Description == `shared credentials profile "myprofile"` {
+			t.Errorf("Expected shared credentials file to be ignored, got %+v", planned)
+		}
+	}
+}
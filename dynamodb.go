@@ -0,0 +1,15 @@
+package awsbase
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// NewDynamoDBClient returns a DynamoDB client for the given session, built by
+// GetSession from the Config, with the DynamoDB endpoint of the Config
+// applied, e.g. for state locking tables. The client retries requests as
+// configured by MaxRetries and ServiceMaxRetries, like other clients of the
+// session.
+func NewDynamoDBClient(sess *session.Session, c *Config) *dynamodb.DynamoDB {
+	return dynamodb.New(sess, endpointConfig(expandedConfig(c).DynamoDBEndpoint))
+}
@@ -0,0 +1,68 @@
+package awsbase
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestNewDynamoDBClient(t *testing.T) {
+	t.Setenv("TEST_ENDPOINT_HOST", "localhost:8000")
+
+	testCases := []struct {
+		Description  string
+		Config       *Config
+		ExpectedHost string
+	}{
+		{
+			Description:  "default",
+			Config:       &Config{},
+			ExpectedHost: "dynamodb.us-west-2.amazonaws.com",
+		},
+		{
+			Description: "custom endpoint",
+			Config: &Config{
+				DynamoDBEndpoint: "http://localhost:8000",
+			},
+			ExpectedHost: "localhost:8000",
+		},
+		{
+			Description: "custom endpoint from environment variable",
+			Config: &Config{
+				DynamoDBEndpoint: "http://${TEST_ENDPOINT_HOST}",
+			},
+			ExpectedHost: "localhost:8000",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Region:      aws.String("us-west-2"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := NewDynamoDBClient(sess, testCase.Config).GetItemRequest(&dynamodb.GetItemInput{
+				Key: map[string]*dynamodb.AttributeValue{
+					"LockID": {S: aws.String("state")},
+				},
+				TableName: aws.String("locks"),
+			})
+			if err := req.Build(); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if req.HTTPRequest.URL.Host != testCase.ExpectedHost {
+				t.Errorf("Expected host %q, got %q", testCase.ExpectedHost, req.HTTPRequest.URL.Host)
+			}
+		})
+	}
+}
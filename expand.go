@@ -0,0 +1,111 @@
+package awsbase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// envReferenceRegexp matches references to environment variables, e.g.
+// ${HOME}. References without braces, e.g. $HOME, are not expanded, so that
+// file names and URLs containing "$" are kept as they are.
+var envReferenceRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandConfigEnv returns a copy of the Config with references to environment
+// variables, e.g. ${HOME}, in its file names and endpoints replaced by their
+// values, e.g. for templated configuration of container images. The Config
+// itself is returned if it has no references, and an error for references to
+// unset environment variables.
+//
+// The functions of this package which take a Config, e.g. GetSession,
+// GetCredentials, NewS3Client, and ClientConfig, call it once, so that
+// references are expanded when the Config is used, but references in the
+// values of environment variables are not. Configs returned by it are thus
+// expanded again if passed to them.
+//
+// The expanded fields are CredsFilename, WatchedCredsFilename, the client
+// certificate and key files, the DynamoDB, IAM, S3, and STS endpoints, the
+// StsEndpoint of AssumeRole, and the certificate and private key files and
+// Endpoint of RolesAnywhere.
+func ExpandConfigEnv(c *Config) (*Config, error) {
+	expanded := *c
+	var errs []error
+	changed := false
+
+	expand := func(name string, value *string) {
+		result, err := expandEnvReferences(*value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		if result != *value {
+			*value = result
+			changed = true
+		}
+	}
+
+	expand("CredsFilename", &expanded.CredsFilename)
+	expand("DynamoDBEndpoint", &expanded.DynamoDBEndpoint)
+	expand("IamEndpoint", &expanded.IamEndpoint)
+	expand("ProxyClientCertFilename", &expanded.ProxyClientCertFilename)
+	expand("ProxyClientKeyFilename", &expanded.ProxyClientKeyFilename)
+	expand("S3Endpoint", &expanded.S3Endpoint)
+	expand("StsClientCertFilename", &expanded.StsClientCertFilename)
+	expand("StsClientKeyFilename", &expanded.StsClientKeyFilename)
+	expand("StsEndpoint", &expanded.StsEndpoint)
+	expand("WatchedCredsFilename", &expanded.WatchedCredsFilename)
+
+	if c.AssumeRole != nil {
+		assumeRole := *c.AssumeRole
+		expand("AssumeRole.StsEndpoint", &assumeRole.StsEndpoint)
+		expanded.AssumeRole = &assumeRole
+	}
+	if c.RolesAnywhere != nil {
+		rolesAnywhere := *c.RolesAnywhere
+		expand("RolesAnywhere.CertificateFilename", &rolesAnywhere.CertificateFilename)
+		expand("RolesAnywhere.Endpoint", &rolesAnywhere.Endpoint)
+		expand("RolesAnywhere.PrivateKeyFilename", &rolesAnywhere.PrivateKeyFilename)
+		expanded.RolesAnywhere = &rolesAnywhere
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("error expanding environment variables of Config: %w", err)
+	}
+	if !changed {
+		return c, nil
+	}
+	return &expanded, nil
+}
+
+// expandedConfig returns the Config with references to environment variables
+// expanded, for functions which cannot return errors, e.g. NewS3Client. The
+// session they take was built by GetSession from the Config, which returned
+// the error of ExpandConfigEnv, so the Config itself is only returned, with a
+// warning, if environment variables were unset since.
+func expandedConfig(c *Config) *Config {
+	expanded, err := ExpandConfigEnv(c)
+	if err != nil {
+		logger.Printf("[WARN] %s", err)
+		return c
+	}
+	return expanded
+}
+
+// expandEnvReferences replaces the references to environment variables in the
+// value, returning an error for unset environment variables.
+func expandEnvReferences(value string) (string, error) {
+	var unset []string
+	result := envReferenceRegexp.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReferenceRegexp.FindStringSubmatch(reference)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			unset = append(unset, name)
+		}
+		return v
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", unset[0])
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package awsbase
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	var testCases = []struct {
+		Description   string
+		Config        *Config
+		Expected      *Config
+		ExpectedError string
+	}{
+		{
+			Description: "file names and endpoints",
+			Config: &Config{
+				CredsFilename: "${CONFIG_DIR}/credentials",
+				Region:        "${REGION}",
+				StsEndpoint:   "https://sts.${STS_DOMAIN}",
+			},
+			Expected: &Config{
+				CredsFilename: "/etc/aws/credentials",
+				Region:        "${REGION}",
+				StsEndpoint:   "https://sts.example.com",
+			},
+		},
+		{
+			Description: "nested settings",
+			Config: &Config{
+				AssumeRole: &AssumeRole{
+					RoleARN:     "arn:aws:iam::555555555555:role/AssumeRole",
+					StsEndpoint: "https://sts.${STS_DOMAIN}",
+				},
+				RolesAnywhere: &RolesAnywhere{
+					CertificateFilename: "${CONFIG_DIR}/certificate.pem",
+					PrivateKeyFilename:  "${CONFIG_DIR}/private-key.pem",
+				},
+			},
+			Expected: &Config{
+				AssumeRole: &AssumeRole{
+					RoleARN:     "arn:aws:iam::555555555555:role/AssumeRole",
+					StsEndpoint: "https://sts.example.com",
+				},
+				RolesAnywhere: &RolesAnywhere{
+					CertificateFilename: "/etc/aws/certificate.pem",
+					PrivateKeyFilename:  "/etc/aws/private-key.pem",
+				},
+			},
+		},
+		{
+			Description: "endpoints",
+			Config: &Config{
+				AssumeRole: &AssumeRole{
+					StsEndpoint: "https://sts.${STS_DOMAIN}",
+				},
+				DynamoDBEndpoint: "https://dynamodb.${STS_DOMAIN}",
+				IamEndpoint:      "https://iam.${STS_DOMAIN}",
+				RolesAnywhere: &RolesAnywhere{
+					Endpoint: "https://rolesanywhere.${STS_DOMAIN}",
+				},
+				S3Endpoint:  "https://s3.${STS_DOMAIN}",
+				StsEndpoint: "https://sts.${STS_DOMAIN}",
+			},
+			Expected: &Config{
+				AssumeRole: &AssumeRole{
+					StsEndpoint: "https://sts.example.com",
+				},
+				DynamoDBEndpoint: "https://dynamodb.example.com",
+				IamEndpoint:      "https://iam.example.com",
+				RolesAnywhere: &RolesAnywhere{
+					Endpoint: "https://rolesanywhere.example.com",
+				},
+				S3Endpoint:  "https://s3.example.com",
+				StsEndpoint: "https://sts.example.com",
+			},
+		},
+		{
+			Description: "environment variable containing a reference",
+			Config: &Config{
+				StsEndpoint: "${LITERAL_ENDPOINT}",
+			},
+			Expected: &Config{
+				StsEndpoint: "https://sts.${STS_DOMAIN}",
+			},
+		},
+		{
+			Description: "references without braces",
+			Config: &Config{
+				CredsFilename: "$CONFIG_DIR/credentials",
+			},
+			Expected: &Config{
+				CredsFilename: "$CONFIG_DIR/credentials",
+			},
+		},
+		{
+			Description: "empty environment variable",
+			Config: &Config{
+				CredsFilename: "${EMPTY}credentials",
+			},
+			Expected: &Config{
+				CredsFilename: "credentials",
+			},
+		},
+		{
+			Description: "unset environment variable",
+			Config: &Config{
+				CredsFilename: "${UNSET_CONFIG_DIR}/credentials",
+			},
+			ExpectedError: "CredsFilename: environment variable UNSET_CONFIG_DIR is not set",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			t.Setenv("CONFIG_DIR", "/etc/aws")
+			t.Setenv("EMPTY", "")
+			t.Setenv("LITERAL_ENDPOINT", "https://sts.${STS_DOMAIN}")
+			t.Setenv("REGION", "us-west-2")
+			t.Setenv("STS_DOMAIN", "example.com")
+			os.Unsetenv("UNSET_CONFIG_DIR")
+
+			c, err := ExpandConfigEnv(testCase.Config)
+
+			if testCase.ExpectedError != "" {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				if !strings.Contains(err.Error(), testCase.ExpectedError) {
+					t.Errorf("Expected error containing %q, got %q", testCase.ExpectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if !reflect.DeepEqual(c, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, c)
+			}
+		})
+	}
+}
+
+func TestExpandConfigEnv_unchanged(t *testing.T) {
+	c := &Config{CredsFilename: "/etc/aws/credentials"}
+
+	expanded, err := ExpandConfigEnv(c)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if expanded != c {
+		t.Error("Expected Config without references to be returned as is")
+	}
+}
+
+func TestGetCredentials_expandsEnv(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(credentialsFileContents), 0600); err != nil {
+		t.Fatalf("Error writing temporary credentials file: %s", err)
+	}
+	t.Setenv("TEST_CONFIG_DIR", dir)
+
+	c := &Config{
+		CredsFilename:        "${TEST_CONFIG_DIR}/credentials",
+		Profile:              "myprofile",
+		SkipMetadataApiCheck: true,
+	}
+	creds, err := GetCredentials(c)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if v.AccessKeyID != "accesskey" {
+		t.Errorf("Expected access key %q, got %q", "accesskey", v.AccessKeyID)
+	}
+	if c.CredsFilename != "${TEST_CONFIG_DIR}/credentials" {
+		t.Errorf("Expected Config to be unchanged, got CredsFilename %q", c.CredsFilename)
+	}
+}
+
+func TestGetSession_expandsEnvOnce(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	// The value of the environment variable contains a reference itself,
+	// which is part of the directory name rather than expanded again.
+	dir := filepath.Join(t.TempDir(), "${TEST_OTHER_DIR}")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(credentialsFileContents), 0600); err != nil {
+		t.Fatalf("Error writing temporary credentials file: %s", err)
+	}
+	t.Setenv("TEST_CONFIG_DIR", dir)
+	t.Setenv("TEST_OTHER_DIR", "other")
+
+	sess, err := GetSession(&Config{
+		CredsFilename:        "${TEST_CONFIG_DIR}/credentials",
+		Profile:              "myprofile",
+		Region:               "us-east-1",
+		SkipCredsValidation:  true,
+		SkipMetadataApiCheck: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	v, err := sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if v.AccessKeyID != "accesskey" {
+		t.Errorf("Expected access key %q, got %q", "accesskey", v.AccessKeyID)
+	}
+}
@@ -0,0 +1,190 @@
+package awsbase
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// CredentialsExpiresAt returns when the credentials, as returned by
+// GetCredentials, expire, less the expiry window of their provider, e.g. to
+// display the remaining session time. It returns false if the credentials
+// have not been retrieved yet, or don't expire, e.g. static credentials and
+// those from environment variables or shared credentials files.
+func CredentialsExpiresAt(creds *awsCredentials.Credentials) (time.Time, bool) {
+	expiresAt, err := creds.ExpiresAt()
+	if err != nil || expiresAt.IsZero() {
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+// newChainCredentials returns credentials from the first of the providers
+// which has them, as awsCredentials.NewChainCredentials does, whose expiry
+// time is that of the provider, if it reports one, along with their chain
+// provider.
+func newChainCredentials(providers []awsCredentials.Provider, verboseErrors bool, clock Clock) (*awsCredentials.Credentials, *expiringChainProvider) {
+	chain := &expiringChainProvider{clock: clock}
+	chain.Providers = providers
+	chain.VerboseErrors = verboseErrors
+	return awsCredentials.NewCredentials(chain), chain
+}
+
+// expiringChainProvider is an awsCredentials.ChainProvider which implements
+// awsCredentials.Expirer, which the ChainProvider doesn't, by tracking the
+// provider the credentials were retrieved from. Unless noted otherwise, its
+// fields are only accessed with the lock of the credentials held.
+//
+// Unlike the ChainProvider, it implements awsCredentials.ProviderWithContext,
+// passing the context of the retrieval on to its providers.
+//
+// It also supports the refreshes of a CredentialsRefresher: credentials are
+// retrieved again when a refresh is requested, and if that fails, the current
+// credentials are kept until they expire.
+type expiringChainProvider struct {
+	awsCredentials.ChainProvider
+
+	clock   Clock
+	current awsCredentials.Provider
+	// failed is whether the last retrieval from the chain failed, in which
+	// case the current provider and value are those of the retrieval before.
+	failed bool
+	value  awsCredentials.Value
+	// primed is whether the value was retrieved by prime, and is to be
+	// returned by the next retrieval.
+	primed bool
+	// staleUntil is when the current credentials, kept after a failed
+	// refresh, expire.
+	staleUntil time.Time
+
+	refreshRequested atomic.Bool
+
+	mu         sync.Mutex
+	refreshErr error
+}
+
+// Retrieve retrieves credentials from the first provider of the chain which
+// has them. If a requested refresh fails while the current credentials are
+// still valid, they are returned instead.
+func (p *expiringChainProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+// RetrieveWithContext is Retrieve with a context, which is passed on to the
+// providers of the chain which support one.
+func (p *expiringChainProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	if p.primed {
+		p.primed = false
+		return p.value, nil
+	}
+
+	refresh := p.refreshRequested.Swap(false)
+	expiresAt := p.ExpiresAt()
+
+	value, err := p.retrieveChain(ctx)
+	if refresh {
+		p.mu.Lock()
+		p.refreshErr = err
+		p.mu.Unlock()
+	}
+	if err != nil {
+		if refresh && p.current != nil && resolveClock(p.clock).Now().Before(expiresAt) {
+			logger.Printf("[WARN] Error refreshing credentials, using current credentials until they expire at %s: %s", expiresAt.Format(time.RFC3339), err)
+			p.staleUntil = expiresAt
+			return p.value, nil
+		}
+		return value, err
+	}
+
+	p.value = value
+	p.staleUntil = time.Time{}
+	return value, nil
+}
+
+// prime retrieves credentials from the chain with the context, for the next
+// retrieval of the credentials to return. Unlike GetWithContext of the
+// credentials, which only passes the values of the context on to the
+// provider, it stops the retrieval when the context is cancelled. It must be
+// called before the credentials are used.
+func (p *expiringChainProvider) prime(ctx awsCredentials.Context) error {
+	if _, err := p.RetrieveWithContext(ctx); err != nil {
+		return err
+	}
+	p.primed = true
+	return nil
+}
+
+// retrieveChain retrieves credentials from the first provider of the chain
+// which has them, as the ChainProvider does.
+func (p *expiringChainProvider) retrieveChain(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	var errs []error
+	for _, provider := range p.Providers {
+		value, err := retrieveWithContext(ctx, provider)
+		if err == nil {
+			p.current = provider
+			p.failed = false
+			return value, nil
+		}
+		errs = append(errs, err)
+	}
+	p.failed = true
+
+	if p.VerboseErrors {
+		return awsCredentials.Value{}, awserr.NewBatchError("NoCredentialProviders", "no valid providers in chain", errs)
+	}
+	return awsCredentials.Value{}, awsCredentials.ErrNoValidProvidersFoundInChain
+}
+
+// IsExpired returns true if a refresh was requested, the current credentials
+// kept after a failed refresh expired, or those of the current provider did.
+func (p *expiringChainProvider) IsExpired() bool {
+	if p.refreshRequested.Load() {
+		return true
+	}
+	if !p.staleUntil.IsZero() {
+		return !resolveClock(p.clock).Now().Before(p.staleUntil)
+	}
+	if p.current == nil || p.failed {
+		return true
+	}
+	return p.current.IsExpired()
+}
+
+// ExpiresAt returns the expiry time of the credentials of the current
+// provider, or the zero time if the provider doesn't report one.
+func (p *expiringChainProvider) ExpiresAt() time.Time {
+	if expirer, ok := p.current.(awsCredentials.Expirer); ok {
+		return expirer.ExpiresAt()
+	}
+	return time.Time{}
+}
+
+// requestRefresh makes the next retrieval of the credentials retrieve them
+// from the chain again, even if they haven't expired. It may be called
+// without the lock of the credentials held.
+func (p *expiringChainProvider) requestRefresh() {
+	p.refreshRequested.Store(true)
+}
+
+// refreshError returns the error of the last requested refresh, including
+// one which kept the current credentials. It may be called without the lock
+// of the credentials held.
+func (p *expiringChainProvider) refreshError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.refreshErr
+}
+
+// retrieveWithContext retrieves credentials from the provider, passing it the
+// context if it supports one.
+func retrieveWithContext(ctx awsCredentials.Context, provider awsCredentials.Provider) (awsCredentials.Value, error) {
+	if provider, ok := provider.(awsCredentials.ProviderWithContext); ok {
+		return provider.RetrieveWithContext(ctx)
+	}
+	return provider.Retrieve()
+}
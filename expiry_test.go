@@ -0,0 +1,119 @@
+package awsbase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+func TestCredentialsExpiresAt(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	var testCases = []struct {
+		Description       string
+		Config            *Config
+		ExpectedExpiresAt time.Time
+	}{
+		{
+			Description: "static credentials",
+			Config: &Config{
+				AccessKey: "accessKey",
+				SecretKey: "secretKey",
+			},
+		},
+		{
+			Description: "credentials function with expiration",
+			Config: &Config{
+				CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+					return awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"}, expiration, nil
+				},
+			},
+			ExpectedExpiresAt: expiration,
+		},
+		{
+			Description: "credentials function without expiration",
+			Config: &Config{
+				CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+					return awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"}, time.Time{}, nil
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			testCase.Config.SkipMetadataApiCheck = true
+
+			creds, err := GetCredentials(testCase.Config)
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if _, ok := CredentialsExpiresAt(creds); ok {
+				t.Error("Expected no expiry time before retrieval")
+			}
+
+			if _, err := creds.Get(); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			expiresAt, ok := CredentialsExpiresAt(creds)
+			if testCase.ExpectedExpiresAt.IsZero() {
+				if ok {
+					t.Errorf("Expected no expiry time, got %s", expiresAt)
+				}
+				return
+			}
+			if !ok || !expiresAt.Equal(testCase.ExpectedExpiresAt) {
+				t.Errorf("Expected expiry time %s, got %s (%t)", testCase.ExpectedExpiresAt, expiresAt, ok)
+			}
+		})
+	}
+}
+
+func TestCredentialsExpiresAt_assumeRole(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, nil, nil)
+	defer servers.Close()
+
+	assumeRoleServer := awsmocks.NewServer("STS", []*awsmocks.MockEndpoint{awsmocks.MockStsAssumeRoleValidEndpoint})
+	defer assumeRoleServer.Close()
+
+	creds, err := GetCredentials(&Config{
+		AccessKey: "accessKey",
+		AssumeRole: &AssumeRole{
+			ExpiryWindow: time.Minute,
+			RoleARN:      awsmocks.MockStsAssumeRoleArn,
+			SessionName:  awsmocks.MockStsAssumeRoleSessionName,
+			StsEndpoint:  assumeRoleServer.URL,
+		},
+		Region:               "us-east-1",
+		SecretKey:            "secretKey",
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          servers.StsEndpoint(),
+	})
+	if err != nil {
+		t.Fatalf("Error getting creds: %s", err)
+	}
+
+	expiresAt, ok := CredentialsExpiresAt(creds)
+	if !ok {
+		t.Fatal("Expected expiry time of assumed role credentials, got none")
+	}
+
+	// The expiry time of the mock credentials, less the expiry window.
+	expected := time.Date(2099, 12, 31, 23, 58, 59, 0, time.UTC)
+	if !expiresAt.Equal(expected) {
+		t.Errorf("Expected expiry time %s, got %s", expected, expiresAt)
+	}
+}
@@ -0,0 +1,141 @@
+package awsbase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExplainedSetting is a resolved setting and where its value came from.
+type ExplainedSetting struct {
+	// Name is the name of the setting, e.g. region.
+	Name string
+	// Value is the resolved value, or an empty string if the setting is unset.
+	Value string
+	// Source describes where the value came from, e.g. environment variable
+	// AWS_REGION.
+	Source string
+}
+
+// CredentialsExplanation explains how credentials and related settings were
+// resolved for a Config, e.g. for display by a --debug-auth flag.
+type CredentialsExplanation struct {
+	// Settings are the resolved profile, shared credentials file, region, and
+	// role settings.
+	Settings []ExplainedSetting
+	// Attempts are the credential providers consulted, in order.
+	Attempts []CredentialsAttempt
+	// Sources are the descriptions of the credential sources which supplied
+	// the credentials, outermost last.
+	Sources []string
+	// ProviderName is the name of the credential provider which supplied the
+	// credentials, e.g. AssumeRoleProviderName.
+	ProviderName string
+	// AssumedRoleARN is the ARN of the role assumed, if any.
+	AssumedRoleARN string
+	// Err is the error resolving credentials, if any.
+	Err error
+}
+
+// ExplainCredentials resolves credentials for the Config, as GetCredentials
+// does, and explains which settings were used and why. The explanation is
+// returned even if credentials cannot be resolved, with the error in Err.
+func ExplainCredentials(c *Config) *CredentialsExplanation {
+	region := explainSetting("region", c.Region, "AWS_REGION", "")
+	// The AWS Go SDK only consults AWS_DEFAULT_REGION when shared
+	// configuration is loaded.
+	if region.Value == "" && os.Getenv("AWS_SDK_LOAD_CONFIG") != "" {
+		region = explainSetting("region", "", "AWS_DEFAULT_REGION", "")
+	}
+
+	explanation := &CredentialsExplanation{
+		Settings: []ExplainedSetting{
+			explainSetting("profile", c.Profile, "AWS_PROFILE", "default"),
+			explainSetting("shared credentials file", c.CredsFilename, "AWS_SHARED_CREDENTIALS_FILE", "~/.aws/credentials"),
+			region,
+		},
+	}
+
+	assumeRole := ResolveAssumeRole(c)
+	if assumeRole != nil {
+		roleARN := ExplainedSetting{
+			Name:   "role ARN",
+			Value:  assumeRole.RoleARN,
+			Source: "Config",
+		}
+		if c.AssumeRole == nil || c.AssumeRole.RoleARN == "" {
+			roleARN.Source = fmt.Sprintf("environment variable %s", AssumeRoleARNEnvVar)
+		}
+		explanation.Settings = append(explanation.Settings, roleARN)
+	}
+
+	creds, trail, err := GetCredentialsWithAuditTrail(c)
+	if err == nil {
+		_, err = creds.Get()
+	}
+	explanation.Err = err
+	explanation.Attempts = trail.Attempts()
+
+	if err == nil {
+		explanation.Sources = trail.Sources()
+		explanation.ProviderName = trail.ProviderName()
+		if assumeRole != nil {
+			explanation.AssumedRoleARN = assumeRole.RoleARN
+		}
+	}
+
+	return explanation
+}
+
+// String returns the explanation formatted for display to end users.
+func (e *CredentialsExplanation) String() string {
+	var b strings.Builder
+
+	for _, setting := range e.Settings {
+		if setting.Value == "" {
+			fmt.Fprintf(&b, "%s: not set\n", setting.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s (%s)\n", setting.Name, setting.Value, setting.Source)
+	}
+
+	b.WriteString("credential sources consulted:\n")
+	for _, attempt := range e.Attempts {
+		if attempt.Err != nil {
+			fmt.Fprintf(&b, "  %s: %s\n", attempt.Description, firstLine(attempt.Err.Error()))
+		} else {
+			fmt.Fprintf(&b, "  %s: supplied credentials (%s)\n", attempt.Description, attempt.ProviderName)
+		}
+	}
+
+	if e.Err != nil {
+		fmt.Fprintf(&b, "not authenticated: %s\n", e.Err)
+	} else {
+		fmt.Fprintf(&b, "authenticated via %s\n", strings.Join(e.Sources, " → "))
+	}
+
+	return b.String()
+}
+
+// explainSetting explains a setting configured by the given Config field
+// value, falling back to the given environment variable and default value.
+func explainSetting(name, value, envVar, defaultValue string) ExplainedSetting {
+	if value != "" {
+		return ExplainedSetting{Name: name, Value: value, Source: "Config"}
+	}
+	if value := os.Getenv(envVar); value != "" {
+		return ExplainedSetting{Name: name, Value: value, Source: fmt.Sprintf("environment variable %s", envVar)}
+	}
+	if defaultValue != "" {
+		return ExplainedSetting{Name: name, Value: defaultValue, Source: "default"}
+	}
+	return ExplainedSetting{Name: name, Source: "not set"}
+}
+
+// firstLine returns the first line of a possibly multi-line message.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package awsbase
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExplainCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	t.Run("static credentials", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "us-west-2")
+
+		explanation := ExplainCredentials(&Config{
+			AccessKey:            "accessKey",
+			SecretKey:            "secretKey",
+			Profile:              "prod",
+			SkipMetadataApiCheck: true,
+		})
+		if explanation.Err != nil {
+			t.Fatalf("Expected no error, received error: %s", explanation.Err)
+		}
+
+		expectedSettings := []ExplainedSetting{
+			{Name: "profile", Value: "prod", Source: "Config"},
+			{Name: "shared credentials file", Value: "~/.aws/credentials", Source: "default"},
+			{Name: "region", Value: "us-west-2", Source: "environment variable AWS_REGION"},
+		}
+		if len(explanation.Settings) != len(expectedSettings) {
+			t.Fatalf("Expected %d settings, got %d", len(expectedSettings), len(explanation.Settings))
+		}
+		for i, expected := range expectedSettings {
+			if explanation.Settings[i] != expected {
+				t.Errorf("Expected setting %d to be %+v, got %+v", i, expected, explanation.Settings[i])
+			}
+		}
+
+		if explanation.ProviderName != StaticProviderName {
+			t.Errorf("Expected provider name %q, got %q", StaticProviderName, explanation.ProviderName)
+		}
+		if explanation.AssumedRoleARN != "" {
+			t.Errorf("Expected no assumed role, got %q", explanation.AssumedRoleARN)
+		}
+		if !strings.Contains(explanation.String(), "authenticated via static credentials") {
+			t.Errorf("Expected explanation to report static credentials, got:\n%s", explanation)
+		}
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/example")
+
+		explanation := ExplainCredentials(&Config{
+			SkipMetadataApiCheck: true,
+		})
+		if explanation.Err == nil {
+			t.Fatal("Expected error, received none")
+		}
+
+		expected := ExplainedSetting{Name: "role ARN", Value: "arn:aws:iam::123456789012:role/example", Source: "environment variable AWS_ROLE_ARN"}
+		if actual := explanation.Settings[len(explanation.Settings)-1]; actual != expected {
+			t.Errorf("Expected setting %+v, got %+v", expected, actual)
+		}
+		if len(explanation.Attempts) == 0 {
+			t.Error("Expected credential provider attempts, got none")
+		}
+		if !strings.Contains(explanation.String(), "not authenticated") {
+			t.Errorf("Expected explanation to report failure, got:\n%s", explanation)
+		}
+	})
+
+	t.Run("unset environment variable", func(t *testing.T) {
+		os.Unsetenv("UNSET_CONFIG_DIR")
+
+		explanation := ExplainCredentials(&Config{
+			CredsFilename:        "${UNSET_CONFIG_DIR}/credentials",
+			SkipMetadataApiCheck: true,
+		})
+		if explanation.Err == nil {
+			t.Fatal("Expected error, received none")
+		}
+		if !strings.Contains(explanation.Err.Error(), "environment variable UNSET_CONFIG_DIR is not set") {
+			t.Errorf("Expected error for unset environment variable, got %q", explanation.Err)
+		}
+		if len(explanation.Attempts) != 0 {
+			t.Errorf("Expected no credential provider attempts, got %d", len(explanation.Attempts))
+		}
+		if !strings.Contains(explanation.String(), "not authenticated") {
+			t.Errorf("Expected explanation to report failure, got:\n%s", explanation)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package awsbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// FederationTokenProviderName is the name of FederationTokenProvider.
+const FederationTokenProviderName = "FederationTokenProvider"
+
+// federationTokenExpiryWindow is how long before their expiration federation
+// tokens are refreshed.
+const federationTokenExpiryWindow = 1 * time.Minute
+
+// FederationToken contains the settings for obtaining the temporary
+// credentials of a federated user with sts:GetFederationToken, with the
+// credentials resolved from the other Config fields, which must be long-term
+// IAM user credentials. The Policy and PolicyARNs scope the permissions of the
+// federated user down from those of the IAM user, e.g. to hand credentials to
+// subprocesses or users.
+//
+// It cannot be combined with AssumeRole, as federation tokens cannot be
+// obtained with temporary credentials.
+type FederationToken struct {
+	Duration   time.Duration     `json:"duration,omitempty" hcl:"duration,optional"`
+	Name       string            `json:"name,omitempty" hcl:"name,optional"`
+	Policy     string            `json:"policy,omitempty" hcl:"policy,optional"`
+	PolicyARNs []string          `json:"policy_arns,omitempty" hcl:"policy_arns,optional"`
+	Tags       map[string]string `json:"tags,omitempty" hcl:"tags,optional"`
+}
+
+var federationTokenNameRegexp = regexp.MustCompile(`^[\w+=,.@-]{2,32}$`)
+
+// Validate checks the FederationToken settings against the constraints of the
+// STS GetFederationToken API, so that misconfiguration is reported before any
+// API calls are made.
+func (t *FederationToken) Validate() error {
+	var errs []error
+
+	if t.Name == "" {
+		errs = append(errs, errors.New("name must be set"))
+	} else if !federationTokenNameRegexp.MatchString(t.Name) {
+		errs = append(errs, fmt.Errorf("invalid name %q", t.Name))
+	}
+
+	if t.Duration != 0 && (t.Duration < 15*time.Minute || t.Duration > 36*time.Hour) {
+		errs = append(errs, fmt.Errorf("duration must be between 15m and 36h, got %s", t.Duration))
+	}
+
+	if t.Policy != "" && !json.Valid([]byte(t.Policy)) {
+		errs = append(errs, errors.New("policy must be valid JSON"))
+	}
+
+	for _, policyARN := range t.PolicyARNs {
+		if _, err := arn.Parse(policyARN); err != nil {
+			errs = append(errs, fmt.Errorf("invalid policy ARN %q: %w", policyARN, err))
+		}
+	}
+
+	if len(t.Tags) > 50 {
+		errs = append(errs, fmt.Errorf("at most 50 session tags may be set, got %d", len(t.Tags)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// FederationTokenProvider retrieves the temporary credentials of a federated
+// user with sts:GetFederationToken, refreshing them before they expire.
+type FederationTokenProvider struct {
+	awsCredentials.Expiry
+
+	Client stsiface.STSAPI
+	Token  *FederationToken
+}
+
+// Retrieve obtains a federation token.
+func (p *FederationTokenProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+// RetrieveWithContext obtains a federation token with the given context.
+func (p *FederationTokenProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	input := &sts.GetFederationTokenInput{
+		Name: aws.String(p.Token.Name),
+	}
+	if p.Token.Duration > 0 {
+		input.DurationSeconds = aws.Int64(int64(p.Token.Duration / time.Second))
+	}
+	if p.Token.Policy != "" {
+		input.Policy = aws.String(p.Token.Policy)
+	}
+	for _, policyARN := range p.Token.PolicyARNs {
+		input.PolicyArns = append(input.PolicyArns, &sts.PolicyDescriptorType{Arn: aws.String(policyARN)})
+	}
+
+	// Sort the tag keys so that requests are deterministic.
+	tagKeys := make([]string, 0, len(p.Token.Tags))
+	for key := range p.Token.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		input.Tags = append(input.Tags, &sts.Tag{Key: aws.String(key), Value: aws.String(p.Token.Tags[key])})
+	}
+
+	output, err := p.Client.GetFederationTokenWithContext(ctx, input)
+	if err != nil {
+		return awsCredentials.Value{ProviderName: FederationTokenProviderName}, err
+	}
+
+	p.SetExpiration(aws.TimeValue(output.Credentials.Expiration), federationTokenExpiryWindow)
+
+	return awsCredentials.Value{
+		AccessKeyID:     aws.StringValue(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(output.Credentials.SessionToken),
+		ProviderName:    FederationTokenProviderName,
+	}, nil
+}
+
+// getFederationTokenCredentials returns the credentials of the federated user
+// of the FederationToken of the Config, obtained with the credentials of the
+// given providers.
+func getFederationTokenCredentials(ctx context.Context, c *Config, internalSession *session.Session, providers []awsCredentials.Provider, trail *CredentialsAuditTrail) (*awsCredentials.Credentials, error) {
+	if err := c.FederationToken.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid federation token configuration: %w", err)
+	}
+
+	logger.Printf("[INFO] Attempting to GetFederationToken %s (Policy: %q)", c.FederationToken.Name, c.FederationToken.Policy)
+
+	creds, _ := newChainCredentials(providers, false, c.Clock)
+	cp, err := creds.GetWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading credentials for AWS Provider: %w", err)
+	}
+
+	logger.With(logFields{"provider": cp.ProviderName}).Printf("[INFO] AWS Auth provider used: %q", cp.ProviderName)
+
+	stsConfig, err := stsConfig(c, internalSession.Config.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	stsConfig.Credentials = creds
+	stsConfig.Region = aws.String(c.Region)
+	stsConfig.MaxRetries = aws.Int(c.MaxRetries)
+
+	provider := &FederationTokenProvider{
+		Client: sts.New(internalSession, stsConfig),
+		Token:  c.FederationToken,
+	}
+	provider.CurrentTime = resolveClock(c.Clock).Now
+
+	federationCreds, chain := newChainCredentials([]awsCredentials.Provider{
+		trail.wrap(provider, fmt.Sprintf("federation token %s", c.FederationToken.Name)),
+	}, true, c.Clock)
+	if _, err := federationCreds.GetWithContext(ctx); err != nil {
+		return nil, fmt.Errorf("Error getting federation token for AWS Provider: %w", err)
+	}
+
+	c.CredentialsRefresher.start(federationCreds, chain, c.Clock)
+	return federationCreds, nil
+}
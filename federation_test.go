@@ -0,0 +1,155 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFederationTokenValidate(t *testing.T) {
+	var testCases = []struct {
+		Description     string
+		FederationToken FederationToken
+		ExpectedErrors  []string
+	}{
+		{
+			Description: "valid",
+			FederationToken: FederationToken{
+				Duration:   time.Hour,
+				Name:       "deploy-bot",
+				Policy:     `{"Version":"2012-10-17","Statement":[]}`,
+				PolicyARNs: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+		{
+			Description:     "missing name",
+			FederationToken: FederationToken{},
+			ExpectedErrors:  []string{"name must be set"},
+		},
+		{
+			Description: "invalid",
+			FederationToken: FederationToken{
+				Duration:   37 * time.Hour,
+				Name:       "deploy bot",
+				Policy:     "{",
+				PolicyARNs: []string{"ReadOnlyAccess"},
+			},
+			ExpectedErrors: []string{
+				`invalid name "deploy bot"`,
+				"duration must be between 15m and 36h",
+				"policy must be valid JSON",
+				`invalid policy ARN "ReadOnlyAccess"`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			err := testCase.FederationToken.Validate()
+			if len(testCase.ExpectedErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, received error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error, received none")
+			}
+			for _, expected := range testCase.ExpectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error containing %q, got %q", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCredentials_federationToken(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	var requestBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Error parsing request: %s", err)
+		}
+		requestBody = r.PostForm.Encode()
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, stsResponse_GetFederationToken_valid, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		AccessKey: "StaticAccessKey",
+		FederationToken: &FederationToken{
+			Duration: time.Hour,
+			Name:     "deploy-bot",
+			Policy:   `{"Version":"2012-10-17","Statement":[]}`,
+			Tags:     map[string]string{"team": "platform"},
+		},
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	}
+
+	creds, trail, err := GetCredentialsWithAuditTrail(config)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	for _, expected := range []string{
+		"Action=GetFederationToken",
+		"DurationSeconds=3600",
+		"Name=deploy-bot",
+		"Tags.member.1.Key=team",
+	} {
+		if !strings.Contains(requestBody, expected) {
+			t.Errorf("Expected request containing %q, got %q", expected, requestBody)
+		}
+	}
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if value.AccessKeyID != "FederatedAccessKey" || value.SessionToken != "FederatedSessionToken" {
+		t.Errorf("Expected federated credentials, got %q", value.AccessKeyID)
+	}
+	if value.ProviderName != FederationTokenProviderName {
+		t.Errorf("Expected provider name %q, got %q", FederationTokenProviderName, value.ProviderName)
+	}
+	if sources := trail.Sources(); len(sources) == 0 || sources[len(sources)-1] != "federation token deploy-bot" {
+		t.Errorf("Expected federation token in audit trail, got %q", sources)
+	}
+
+	config.AssumeRole = &AssumeRole{RoleARN: "arn:aws:iam::111111111111:role/Deploy"}
+	if _, err := GetCredentials(config); err == nil || !strings.Contains(err.Error(), "cannot be configured with assume role") {
+		t.Errorf("Expected assume role conflict error, got %v", err)
+	}
+}
+
+const stsResponse_GetFederationToken_valid = `<GetFederationTokenResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetFederationTokenResult>
+    <Credentials>
+      <SessionToken>FederatedSessionToken</SessionToken>
+      <SecretAccessKey>FederatedSecretKey</SecretAccessKey>
+      <Expiration>%s</Expiration>
+      <AccessKeyId>FederatedAccessKey</AccessKeyId>
+    </Credentials>
+    <FederatedUser>
+      <Arn>arn:aws:sts::111111111111:federated-user/deploy-bot</Arn>
+      <FederatedUserId>111111111111:deploy-bot</FederatedUserId>
+    </FederatedUser>
+    <PackedPolicySize>6</PackedPolicySize>
+  </GetFederationTokenResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</GetFederationTokenResponse>`
@@ -0,0 +1,108 @@
+package awsbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// FileCredentialsProviderName is the name of FileCredentialsProvider.
+const FileCredentialsProviderName = "FileCredentialsProvider"
+
+// FileCredentialsProvider retrieves static credentials from a file, which is
+// read again once it changes, so that keys rotated on disk by an agent are
+// picked up without restarting the process.
+//
+// The file contains either a JSON object with AccessKeyId, SecretAccessKey,
+// and optional SessionToken fields, as output by credential processes, or a
+// shared credentials file whose default profile contains the credentials.
+type FileCredentialsProvider struct {
+	Filename string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	read    bool
+}
+
+// fileCredentials is the JSON format of credentials stored outside of the
+// shared credentials file.
+type fileCredentials struct {
+	AccessKeyID     string     `json:"AccessKeyId"`
+	SecretAccessKey string     `json:"SecretAccessKey"`
+	SessionToken    string     `json:"SessionToken"`
+	Expiration      *time.Time `json:"Expiration"`
+}
+
+// Retrieve reads the credentials from the file.
+func (p *FileCredentialsProvider) Retrieve() (awsCredentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.Filename)
+	if err != nil {
+		return awsCredentials.Value{ProviderName: FileCredentialsProviderName}, fmt.Errorf("error reading credentials file: %w", err)
+	}
+
+	contents, err := ioutil.ReadFile(p.Filename)
+	if err != nil {
+		return awsCredentials.Value{ProviderName: FileCredentialsProviderName}, fmt.Errorf("error reading credentials file: %w", err)
+	}
+
+	var value awsCredentials.Value
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("{")) {
+		var creds fileCredentials
+		if err := json.Unmarshal(contents, &creds); err != nil {
+			return awsCredentials.Value{ProviderName: FileCredentialsProviderName}, fmt.Errorf("error parsing credentials file %q: %w", p.Filename, err)
+		}
+		value = awsCredentials.Value{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+		}
+	} else {
+		sharedCredentialsProvider := &awsCredentials.SharedCredentialsProvider{
+			Filename: p.Filename,
+			Profile:  "default",
+		}
+		value, err = sharedCredentialsProvider.Retrieve()
+		if err != nil {
+			return awsCredentials.Value{ProviderName: FileCredentialsProviderName}, err
+		}
+	}
+	value.ProviderName = FileCredentialsProviderName
+
+	if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+		return value, fmt.Errorf("credentials file %q is missing the access key or secret key", p.Filename)
+	}
+
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.read = true
+
+	return value, nil
+}
+
+// IsExpired returns true if the file has changed since the credentials were
+// last read from it.
+func (p *FileCredentialsProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.read {
+		return true
+	}
+
+	info, err := os.Stat(p.Filename)
+	if err != nil {
+		return true
+	}
+
+	return !info.ModTime().Equal(p.modTime) || info.Size() != p.size
+}
@@ -0,0 +1,144 @@
+package awsbase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCredentialsProvider(t *testing.T) {
+	var testCases = []struct {
+		Description     string
+		Contents        string
+		RotatedContents string
+		ExpectedKey     string
+		ExpectedRotated string
+	}{
+		{
+			Description:     "JSON",
+			Contents:        `{"AccessKeyId": "accessKey1", "SecretAccessKey": "secretKey1"}`,
+			RotatedContents: `{"AccessKeyId": "accessKey2", "SecretAccessKey": "secretKey2", "SessionToken": "token"}`,
+			ExpectedKey:     "accessKey1",
+			ExpectedRotated: "accessKey2",
+		},
+		{
+			Description: "shared credentials file",
+			Contents: `[default]
+aws_access_key_id = accessKey1
+aws_secret_access_key = secretKey1
+`,
+			RotatedContents: `[default]
+aws_access_key_id = accessKey2
+aws_secret_access_key = secretKey2
+`,
+			ExpectedKey:     "accessKey1",
+			ExpectedRotated: "accessKey2",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "credentials")
+			if err := ioutil.WriteFile(filename, []byte(testCase.Contents), 0600); err != nil {
+				t.Fatalf("Error writing credentials file: %s", err)
+			}
+
+			provider := &FileCredentialsProvider{Filename: filename}
+			if !provider.IsExpired() {
+				t.Error("Expected provider to be expired before retrieval")
+			}
+
+			value, err := provider.Retrieve()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != testCase.ExpectedKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedKey, value.AccessKeyID)
+			}
+			if value.ProviderName != FileCredentialsProviderName {
+				t.Errorf("Expected provider name %q, got %q", FileCredentialsProviderName, value.ProviderName)
+			}
+			if provider.IsExpired() {
+				t.Error("Expected provider not to be expired after retrieval")
+			}
+
+			if err := ioutil.WriteFile(filename, []byte(testCase.RotatedContents), 0600); err != nil {
+				t.Fatalf("Error writing credentials file: %s", err)
+			}
+			modTime := time.Now().Add(time.Minute)
+			if err := os.Chtimes(filename, modTime, modTime); err != nil {
+				t.Fatalf("Error changing credentials file times: %s", err)
+			}
+
+			if !provider.IsExpired() {
+				t.Error("Expected provider to be expired after file change")
+			}
+
+			value, err = provider.Retrieve()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != testCase.ExpectedRotated {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedRotated, value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestFileCredentialsProvider_missingKeys(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := ioutil.WriteFile(filename, []byte(`{"AccessKeyId": "accessKey"}`), 0600); err != nil {
+		t.Fatalf("Error writing credentials file: %s", err)
+	}
+
+	provider := &FileCredentialsProvider{Filename: filename}
+	if _, err := provider.Retrieve(); err == nil {
+		t.Fatal("Expected error, received none")
+	}
+}
+
+func TestAWSGetCredentials_shouldReloadWatchedFile(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	filename := filepath.Join(t.TempDir(), "credentials")
+	if err := ioutil.WriteFile(filename, []byte(`{"AccessKeyId": "accessKey1", "SecretAccessKey": "secretKey1"}`), 0600); err != nil {
+		t.Fatalf("Error writing credentials file: %s", err)
+	}
+
+	creds, err := GetCredentials(&Config{
+		SkipMetadataApiCheck: true,
+		WatchedCredsFilename: filename,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error getting credentials, received error: %s", err)
+	}
+	if value.AccessKeyID != "accessKey1" {
+		t.Errorf("Expected access key %q, got %q", "accessKey1", value.AccessKeyID)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(`{"AccessKeyId": "accessKey2", "SecretAccessKey": "secretKey2"}`), 0600); err != nil {
+		t.Fatalf("Error writing credentials file: %s", err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatalf("Error changing credentials file times: %s", err)
+	}
+
+	value, err = creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error getting credentials, received error: %s", err)
+	}
+	if value.AccessKeyID != "accessKey2" {
+		t.Errorf("Expected rotated access key %q, got %q", "accessKey2", value.AccessKeyID)
+	}
+}
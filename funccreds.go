@@ -0,0 +1,81 @@
+package awsbase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// FuncCredentialsProviderName is the name of FuncCredentialsProvider, used
+// when the function does not name the provider of its credentials.
+const FuncCredentialsProviderName = "FuncCredentialsProvider"
+
+// CredentialsProviderFunc returns credentials along with the time they expire.
+// A zero expiration means the credentials never expire.
+type CredentialsProviderFunc func(ctx context.Context) (awsCredentials.Value, time.Time, error)
+
+// FuncCredentialsProvider adapts a CredentialsProviderFunc to the credentials
+// Provider interface, so that consumers can supply dynamic credentials, e.g.
+// in a provider chain, without implementing the interface themselves.
+type FuncCredentialsProvider struct {
+	Func CredentialsProviderFunc
+	// Clock determines whether the credentials have expired. If nil,
+	// SystemClock is used.
+	Clock Clock
+
+	mu         sync.Mutex
+	expiration time.Time
+	retrieved  bool
+}
+
+// Retrieve calls the function for credentials.
+func (p *FuncCredentialsProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+// RetrieveWithContext calls the function for credentials with the given
+// context.
+func (p *FuncCredentialsProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	value, expiration, err := p.Func(ctx)
+	if value.ProviderName == "" {
+		value.ProviderName = FuncCredentialsProviderName
+	}
+	if err != nil {
+		return awsCredentials.Value{ProviderName: value.ProviderName}, err
+	}
+	if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+		return awsCredentials.Value{ProviderName: value.ProviderName}, fmt.Errorf("credentials function returned no access key or secret key")
+	}
+
+	p.expiration = expiration
+	p.retrieved = true
+
+	return value, nil
+}
+
+// IsExpired returns true if the credentials have not been retrieved or have
+// expired. Credentials without an expiration never expire.
+func (p *FuncCredentialsProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.retrieved {
+		return true
+	}
+	return !p.expiration.IsZero() && !resolveClock(p.Clock).Now().Before(p.expiration)
+}
+
+// ExpiresAt returns the expiration of the credentials, or the zero time if
+// they don't expire.
+func (p *FuncCredentialsProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expiration
+}
@@ -0,0 +1,144 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+func TestFuncCredentialsProvider(t *testing.T) {
+	var testCases = []struct {
+		Description          string
+		Value                awsCredentials.Value
+		Expiration           time.Time
+		Err                  error
+		ExpectedErr          bool
+		ExpectedProviderName string
+		ExpectedExpired      bool
+	}{
+		{
+			Description:          "no expiration",
+			Value:                awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"},
+			ExpectedProviderName: FuncCredentialsProviderName,
+		},
+		{
+			Description:          "future expiration",
+			Value:                awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey", ProviderName: "Vault"},
+			Expiration:           time.Now().Add(time.Hour),
+			ExpectedProviderName: "Vault",
+		},
+		{
+			Description:          "past expiration",
+			Value:                awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"},
+			Expiration:           time.Now().Add(-time.Minute),
+			ExpectedProviderName: FuncCredentialsProviderName,
+			ExpectedExpired:      true,
+		},
+		{
+			Description:          "error",
+			Err:                  errors.New("test error"),
+			ExpectedErr:          true,
+			ExpectedProviderName: FuncCredentialsProviderName,
+			ExpectedExpired:      true,
+		},
+		{
+			Description:          "missing secret key",
+			Value:                awsCredentials.Value{AccessKeyID: "accessKey"},
+			ExpectedErr:          true,
+			ExpectedProviderName: FuncCredentialsProviderName,
+			ExpectedExpired:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			provider := &FuncCredentialsProvider{
+				Func: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+					return testCase.Value, testCase.Expiration, testCase.Err
+				},
+			}
+			if !provider.IsExpired() {
+				t.Error("Expected provider to be expired before retrieval")
+			}
+
+			value, err := provider.Retrieve()
+			if err != nil && !testCase.ExpectedErr {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if err == nil && testCase.ExpectedErr {
+				t.Fatal("Expected error, received none")
+			}
+			if value.ProviderName != testCase.ExpectedProviderName {
+				t.Errorf("Expected provider name %q, got %q", testCase.ExpectedProviderName, value.ProviderName)
+			}
+			if provider.IsExpired() != testCase.ExpectedExpired {
+				t.Errorf("Expected expired %t, got %t", testCase.ExpectedExpired, provider.IsExpired())
+			}
+		})
+	}
+}
+
+func TestAWSGetCredentials_shouldBeFunc(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	var calls int
+	cfg := Config{
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			calls++
+			return awsCredentials.Value{AccessKeyID: "funcAccessKey", SecretAccessKey: "funcSecretKey"}, time.Time{}, nil
+		},
+		SkipMetadataApiCheck: true,
+	}
+
+	creds, err := GetCredentials(&cfg)
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if v.AccessKeyID != "funcAccessKey" {
+		t.Errorf("AccessKeyID mismatch, expected: (funcAccessKey), got (%s)", v.AccessKeyID)
+	}
+	if v.ProviderName != FuncCredentialsProviderName {
+		t.Errorf("Expected provider name %q, got %q", FuncCredentialsProviderName, v.ProviderName)
+	}
+	if calls != 1 {
+		t.Errorf("Expected credentials function to be called once, got %d", calls)
+	}
+}
+
+func TestAWSGetCredentials_funcContext(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	type contextKey struct{}
+	var received interface{}
+	cfg := Config{
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			received = ctx.Value(contextKey{})
+			return awsCredentials.Value{AccessKeyID: "funcAccessKey", SecretAccessKey: "funcSecretKey"}, time.Time{}, nil
+		},
+		SkipMetadataApiCheck: true,
+	}
+
+	creds, err := GetCredentials(&cfg)
+	if err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+
+	if _, err := creds.GetWithContext(context.WithValue(context.Background(), contextKey{}, "value")); err != nil {
+		t.Fatalf("Error gettings creds: %s", err)
+	}
+	if received != "value" {
+		t.Errorf("Expected credentials function to receive the context of the retrieval, got value %v", received)
+	}
+}
@@ -0,0 +1,56 @@
+package awsbase
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// SessionFunc returns the session built by GetSession, or a copy of it, e.g.
+// instrumented with AWS X-Ray by OnSession of the xray subpackage, which keeps
+// the X-Ray SDK out of this package.
+type SessionFunc func(*session.Session) *session.Session
+
+// addHookHandlers installs the OnRequest, OnRetry, and OnError hooks of the
+// Config into the given handlers.
+func addHookHandlers(c *Config, handlers *request.Handlers) {
+	if c.OnRequest != nil {
+		onRequest := c.OnRequest
+
+		handlers.Send.PushFrontNamed(request.NamedHandler{
+			Name: "awsbase.OnRequest",
+			Fn:   onRequest,
+		})
+	}
+
+	if c.OnRetry != nil {
+		onRetry := c.OnRetry
+
+		// The error is cleared by the core handler once a retry is decided, so
+		// the decision is made here, the same way, while the error is available.
+		handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+			Name: "awsbase.OnRetry",
+			Fn: func(r *request.Request) {
+				if r.Retryable == nil {
+					r.Retryable = aws.Bool(r.ShouldRetry(r))
+				}
+				if r.WillRetry() {
+					onRetry(r)
+				}
+			},
+		})
+	}
+
+	if c.OnError != nil {
+		onError := c.OnError
+
+		handlers.Complete.PushBackNamed(request.NamedHandler{
+			Name: "awsbase.OnError",
+			Fn: func(r *request.Request) {
+				if r.Error != nil {
+					onError(r)
+				}
+			},
+		})
+	}
+}
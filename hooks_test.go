@@ -0,0 +1,141 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+func TestAddHookHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		ThrottledRequests int32
+		MaxRetries        int
+		ExpectedRequests  int
+		ExpectedRetries   int
+		ExpectedErrors    int
+	}{
+		{
+			Description:      "success",
+			MaxRetries:       1,
+			ExpectedRequests: 1,
+		},
+		{
+			Description:       "success after retry",
+			ThrottledRequests: 1,
+			MaxRetries:        1,
+			ExpectedRequests:  2,
+			ExpectedRetries:   1,
+		},
+		{
+			Description:       "error after retries",
+			ThrottledRequests: 3,
+			MaxRetries:        1,
+			ExpectedRequests:  2,
+			ExpectedRetries:   1,
+			ExpectedErrors:    1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/xml")
+				if atomic.AddInt32(&requests, 1) <= testCase.ThrottledRequests {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, stsResponse_GetCallerIdentity_throttled)
+					return
+				}
+				fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Endpoint:    aws.String(ts.URL),
+				MaxRetries:  aws.Int(testCase.MaxRetries),
+				Region:      aws.String("us-east-1"),
+				SleepDelay:  func(time.Duration) {},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var onRequest, onRetry, onError int
+			addHookHandlers(&Config{
+				OnRequest: func(r *request.Request) {
+					onRequest++
+				},
+				OnRetry: func(r *request.Request) {
+					if r.Error == nil {
+						t.Error("Expected retry error, received none")
+					}
+					onRetry++
+				},
+				OnError: func(r *request.Request) {
+					onError++
+				},
+			}, &sess.Handlers)
+
+			_, _ = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+
+			if onRequest != testCase.ExpectedRequests {
+				t.Errorf("Expected OnRequest to be called %d time(s), got %d", testCase.ExpectedRequests, onRequest)
+			}
+			if onRetry != testCase.ExpectedRetries {
+				t.Errorf("Expected OnRetry to be called %d time(s), got %d", testCase.ExpectedRetries, onRetry)
+			}
+			if onError != testCase.ExpectedErrors {
+				t.Errorf("Expected OnError to be called %d time(s), got %d", testCase.ExpectedErrors, onError)
+			}
+		})
+	}
+}
+
+func TestGetSession_onSession(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{awsmocks.MockStsGetCallerIdentityValidEndpoint}, nil)
+	defer servers.Close()
+
+	var requests int
+	var instrumented *session.Session
+	sess, err := GetSession(&Config{
+		AccessKey: "StaticAccessKey",
+		OnSession: func(sess *session.Session) *session.Session {
+			instrumented = sess.Copy()
+			instrumented.Handlers.Send.PushFront(func(r *request.Request) {
+				requests++
+			})
+			return instrumented
+		},
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          servers.StsEndpoint(),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if sess != instrumented {
+		t.Error("Expected session returned by OnSession")
+	}
+	if requests != 1 {
+		t.Errorf("Expected credentials validation to use the session returned by OnSession, got %d request(s)", requests)
+	}
+}
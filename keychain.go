@@ -0,0 +1,93 @@
+package awsbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/zalando/go-keyring"
+)
+
+// KeychainCredentialsProviderName is the name of KeychainCredentialsProvider.
+const KeychainCredentialsProviderName = "KeychainCredentialsProvider"
+
+// KeychainCredentialsProvider retrieves credentials from the OS credential
+// store: the macOS Keychain, Windows Credential Manager, or a Secret Service
+// implementation such as GNOME Keyring on Linux.
+//
+// The secret stored for the service and user is a JSON object with
+// AccessKeyId, SecretAccessKey, and optional SessionToken and Expiration
+// fields, so that cached session credentials are refreshed once expired.
+type KeychainCredentialsProvider struct {
+	Service string
+	User    string
+	// Clock determines whether the credentials have expired. If nil,
+	// SystemClock is used.
+	Clock Clock
+
+	mu         sync.Mutex
+	expiration time.Time
+	retrieved  bool
+}
+
+// Retrieve reads the credentials from the OS credential store.
+func (p *KeychainCredentialsProvider) Retrieve() (awsCredentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secret, err := keyring.Get(p.Service, p.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return awsCredentials.Value{ProviderName: KeychainCredentialsProviderName}, fmt.Errorf("no credentials found in OS credential store for service %q and user %q", p.Service, p.User)
+	}
+	if err != nil {
+		return awsCredentials.Value{ProviderName: KeychainCredentialsProviderName}, fmt.Errorf("error reading OS credential store: %w", err)
+	}
+
+	var creds fileCredentials
+	if err := json.Unmarshal([]byte(secret), &creds); err != nil {
+		return awsCredentials.Value{ProviderName: KeychainCredentialsProviderName}, fmt.Errorf("error parsing credentials in OS credential store for service %q and user %q: %w", p.Service, p.User, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials.Value{ProviderName: KeychainCredentialsProviderName}, fmt.Errorf("credentials in OS credential store for service %q and user %q are missing the access key or secret key", p.Service, p.User)
+	}
+	if creds.Expiration != nil && resolveClock(p.Clock).Now().After(*creds.Expiration) {
+		return awsCredentials.Value{ProviderName: KeychainCredentialsProviderName}, fmt.Errorf("credentials in OS credential store for service %q and user %q expired at %s", p.Service, p.User, creds.Expiration)
+	}
+
+	p.expiration = time.Time{}
+	if creds.Expiration != nil {
+		p.expiration = *creds.Expiration
+	}
+	p.retrieved = true
+
+	return awsCredentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    KeychainCredentialsProviderName,
+	}, nil
+}
+
+// IsExpired returns true if the credentials have not been retrieved or have
+// expired. Credentials without an expiration never expire.
+func (p *KeychainCredentialsProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.retrieved {
+		return true
+	}
+	return !p.expiration.IsZero() && resolveClock(p.Clock).Now().After(p.expiration)
+}
+
+// ExpiresAt returns the expiration of the credentials, or the zero time if
+// they don't expire.
+func (p *KeychainCredentialsProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expiration
+}
@@ -0,0 +1,83 @@
+package awsbase
+
+import (
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeychainCredentialsProvider(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		Secret            string
+		ExpectedAccessKey string
+		ExpectedExpired   bool
+		ExpectedError     bool
+	}{
+		{
+			Description:       "access keys",
+			Secret:            `{"AccessKeyId": "accessKey", "SecretAccessKey": "secretKey"}`,
+			ExpectedAccessKey: "accessKey",
+		},
+		{
+			Description:       "session credentials",
+			Secret:            `{"AccessKeyId": "accessKey", "SecretAccessKey": "secretKey", "SessionToken": "token", "Expiration": "2099-12-31T23:59:59Z"}`,
+			ExpectedAccessKey: "accessKey",
+		},
+		{
+			Description:   "expired session credentials",
+			Secret:        `{"AccessKeyId": "accessKey", "SecretAccessKey": "secretKey", "SessionToken": "token", "Expiration": "2000-01-01T00:00:00Z"}`,
+			ExpectedError: true,
+		},
+		{
+			Description:   "missing secret key",
+			Secret:        `{"AccessKeyId": "accessKey"}`,
+			ExpectedError: true,
+		},
+		{
+			Description:   "invalid JSON",
+			Secret:        "secretKey",
+			ExpectedError: true,
+		},
+		{
+			Description:   "not found",
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			keyring.MockInit()
+			if testCase.Secret != "" {
+				if err := keyring.Set("aws", "default", testCase.Secret); err != nil {
+					t.Fatalf("Error storing secret: %s", err)
+				}
+			}
+
+			provider := &KeychainCredentialsProvider{Service: "aws", User: "default"}
+
+			value, err := provider.Retrieve()
+			if testCase.ExpectedError {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if value.AccessKeyID != testCase.ExpectedAccessKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedAccessKey, value.AccessKeyID)
+			}
+			if value.ProviderName != KeychainCredentialsProviderName {
+				t.Errorf("Expected provider name %q, got %q", KeychainCredentialsProviderName, value.ProviderName)
+			}
+			if expired := provider.IsExpired(); expired != testCase.ExpectedExpired {
+				t.Errorf("Expected expired to be %t, got %t", testCase.ExpectedExpired, expired)
+			}
+		})
+	}
+}
@@ -1,10 +1,152 @@
 package awsbase
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
 )
 
+// LogLevelEnvVar is the environment variable setting the minimum level of the
+// log lines written by this package, one of DEBUG, INFO, WARN, or ERROR, or
+// OFF to suppress them. All lines are written if it is unset, leaving
+// filtering to the application. It is read for each line, so it can be
+// changed at runtime.
+const LogLevelEnvVar = "AWS_BASE_LOG_LEVEL"
+
+// logLevels orders the levels of log lines, from least to most severe.
+var logLevels = map[string]int{
+	"TRACE": 0,
+	"DEBUG": 1,
+	"INFO":  2,
+	"WARN":  3,
+	"ERROR": 4,
+	"OFF":   5,
+}
+
+// jsonLogging is whether log lines are written as JSON objects, as set by
+// SetJSONLogging.
+var jsonLogging atomic.Bool
+
+// logger writes the log lines of this package with the standard library log
+// package, either as text prefixed with the level, e.g. [DEBUG], or as JSON
+// objects with the level, message, time, and fields of the line.
+var logger = &fieldLogger{}
+
+// logFields are the fields of a log line, e.g. provider, endpoint, or
+// duration, which are only written as JSON.
+type logFields map[string]interface{}
+
+type fieldLogger struct {
+	fields logFields
+}
+
+// SetJSONLogging sets whether the log lines of this package are written as
+// JSON objects with the level, message, time, and fields of the line, such as
+// provider, endpoint, and duration, rather than as text. As the package logs
+// with the standard library log package, the setting applies to the whole
+// process, e.g. to all sessions, and is not part of the Config.
+func SetJSONLogging(enabled bool) {
+	jsonLogging.Store(enabled)
+}
+
+// Logf writes a log line with the logger of this package, e.g. for packages
+// building on it, such as awsv2, so that their lines honor SetJSONLogging and
+// LogLevelEnvVar. The format is prefixed with the level, e.g. [DEBUG].
+func Logf(format string, v ...interface{}) {
+	logger.Printf(format, v...)
+}
+
+// With returns a logger adding the given fields to its log lines.
+func (l *fieldLogger) With(fields logFields) *fieldLogger {
+	merged := make(logFields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &fieldLogger{fields: merged}
+}
+
+func (l *fieldLogger) Print(v ...interface{}) {
+	l.output(fmt.Sprint(v...))
+}
+
+func (l *fieldLogger) Printf(format string, v ...interface{}) {
+	l.output(fmt.Sprintf(format, v...))
+}
+
+func (l *fieldLogger) Println(v ...interface{}) {
+	l.output(fmt.Sprintln(v...))
+}
+
+func (l *fieldLogger) output(line string) {
+	level, msg := parseLogLine(line)
+	if !logLevelEnabled(level) {
+		return
+	}
+
+	if !jsonLogging.Load() {
+		log.Print(line)
+		return
+	}
+
+	entry := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for k, v := range l.fields {
+		if duration, ok := v.(time.Duration); ok {
+			v = duration.Seconds()
+		}
+		entry[k] = v
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Print(line)
+		return
+	}
+	// The log package prefix and flags would make the lines invalid JSON.
+	fmt.Fprintln(log.Writer(), string(b))
+}
+
+// logLevelEnabled returns whether lines of the level are written, as set by
+// LogLevelEnvVar. Lines of unknown levels are written unless logging is OFF.
+func logLevelEnabled(level string) bool {
+	minLevel, ok := logLevels[strings.ToUpper(os.Getenv(LogLevelEnvVar))]
+	if !ok {
+		return true
+	}
+	lineLevel, ok := logLevels[level]
+	if !ok {
+		lineLevel = logLevels["ERROR"]
+	}
+	return lineLevel >= minLevel
+}
+
+// parseLogLine splits a log line into its level, e.g. DEBUG, and message.
+// Lines without a level are INFO.
+func parseLogLine(line string) (string, string) {
+	line = strings.TrimSuffix(line, "\n")
+	if strings.HasPrefix(line, "[") {
+		if i := strings.IndexByte(line, ']'); i > 0 {
+			return line[1:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+	}
+	return "INFO", line
+}
+
 type DebugLogger struct{}
 
 func (l DebugLogger) Log(args ...interface{}) {
@@ -14,5 +156,97 @@ func (l DebugLogger) Log(args ...interface{}) {
 			tokens = append(tokens, token)
 		}
 	}
-	log.Printf("[DEBUG] [aws-sdk-go] %s", strings.Join(tokens, " "))
+	logger.Printf("[DEBUG] [aws-sdk-go] %s", strings.Join(tokens, " "))
+}
+
+const redactedValue = "REDACTED"
+
+// sensitiveHeaders are request headers whose values are never logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":            true,
+	"Cookie":                   true,
+	"X-Amz-Security-Token":     true,
+	"X-Aws-Ec2-Metadata-Token": true,
+	"X-Amz-Server-Side-Encryption-Customer-Key": true,
+}
+
+// sensitiveQueryParameters are URL query parameters whose values are never
+// logged, such as those used by presigned URLs.
+var sensitiveQueryParameters = map[string]bool{
+	"X-Amz-Credential":     true,
+	"X-Amz-Security-Token": true,
+	"X-Amz-Signature":      true,
+}
+
+// addRequestLoggingHandlers logs a summary of each request made with the
+// handlers, if request logging is configured.
+func addRequestLoggingHandlers(c *Config, handlers *request.Handlers) {
+	if !c.RequestLogging {
+		return
+	}
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.RequestLogging",
+		Fn: func(r *request.Request) {
+			status := "no response"
+			if r.HTTPResponse != nil {
+				status = fmt.Sprintf("%d", r.HTTPResponse.StatusCode)
+			}
+
+			requestLogger := logger.With(logFields{
+				"service":  r.ClientInfo.ServiceName,
+				"endpoint": r.ClientInfo.Endpoint,
+				"duration": time.Since(r.Time),
+			})
+			requestLogger.Printf("[DEBUG] [aws-sdk-go] %s/%s: %s %s %s (RequestID: %q, RetryCount: %d, Headers: %s)",
+				r.ClientInfo.ServiceName, r.Operation.Name, r.HTTPRequest.Method, redactURL(r.HTTPRequest.URL),
+				status, r.RequestID, r.RetryCount, redactHeaders(r.HTTPRequest.Header))
+
+			if r.Error != nil {
+				requestLogger.Printf("[DEBUG] [aws-sdk-go] %s/%s error: %s", r.ClientInfo.ServiceName, r.Operation.Name, r.Error)
+			}
+		},
+	})
+}
+
+// redactHeaders returns the headers formatted for logging, with sensitive
+// values redacted.
+func redactHeaders(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Join(header[key], ",")
+		if sensitiveHeaders[http.CanonicalHeaderKey(key)] {
+			value = redactedValue
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return "[" + strings.Join(pairs, " ") + "]"
+}
+
+// redactURL returns the URL formatted for logging, with sensitive query
+// parameter values redacted and user information removed.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+	redacted.User = nil
+
+	query := redacted.Query()
+	for key := range query {
+		if sensitiveQueryParameters[key] {
+			query.Set(key, redactedValue)
+			redacted.RawQuery = query.Encode()
+		}
+	}
+
+	return redacted.String()
 }
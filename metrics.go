@@ -0,0 +1,123 @@
+package awsbase
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// MetricAPICalls counts completed AWS API calls.
+	MetricAPICalls = "aws_api_calls_total"
+	// MetricAPIRetries counts retried AWS API call attempts.
+	MetricAPIRetries = "aws_api_retries_total"
+	// MetricAPIThrottles counts AWS API call attempts rejected by throttling.
+	MetricAPIThrottles = "aws_api_throttles_total"
+	// MetricAPICallDuration observes AWS API call latency in seconds,
+	// including all retries.
+	MetricAPICallDuration = "aws_api_call_duration_seconds"
+	// MetricHTTPConnectionsNew counts HTTP connections opened for AWS API call
+	// attempts.
+	MetricHTTPConnectionsNew = "aws_http_connections_new_total"
+	// MetricHTTPConnectionsReused counts AWS API call attempts which reused a
+	// pooled HTTP connection.
+	MetricHTTPConnectionsReused = "aws_http_connections_reused_total"
+	// MetricHTTPDNSLookups counts DNS lookups for AWS API call attempts.
+	MetricHTTPDNSLookups = "aws_http_dns_lookups_total"
+	// MetricHTTPTLSHandshakes counts TLS handshakes for AWS API call attempts.
+	MetricHTTPTLSHandshakes = "aws_http_tls_handshakes_total"
+)
+
+// Metrics receives measurements of the AWS API calls made by this package and
+// the sessions it builds. Implementations should forward them to a metrics
+// backend and must be safe for concurrent use.
+//
+// Each measurement is labeled with "service" and "operation". MetricAPICalls
+// and MetricAPICallDuration are also labeled with "status", which is either
+// "success" or "error".
+//
+// The MetricHTTP counters show whether the HTTP transports reuse connections,
+// e.g. in long-running processes, where new connections and TLS handshakes
+// should be rare relative to MetricAPICalls.
+type Metrics interface {
+	IncrCounter(name string, labels map[string]string)
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// addMetricsHandlers records metrics for each request made with the handlers,
+// if metrics are configured.
+func addMetricsHandlers(c *Config, handlers *request.Handlers) {
+	if c.Metrics == nil {
+		return
+	}
+
+	metrics := c.Metrics
+
+	// The error is cleared by the core handler once a retry is decided, so
+	// throttles are counted before it runs.
+	handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.ThrottleMetrics",
+		Fn: func(r *request.Request) {
+			if request.IsErrorThrottle(r.Error) {
+				metrics.IncrCounter(MetricAPIThrottles, metricsLabels(r))
+			}
+		},
+	})
+
+	handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.RetryMetrics",
+		Fn: func(r *request.Request) {
+			if r.RetryCount > 0 {
+				metrics.IncrCounter(MetricAPIRetries, metricsLabels(r))
+			}
+		},
+	})
+
+	// The trace is derived from the context of the request rather than of the
+	// HTTP request, which is reused by retries, so that each attempt is traced
+	// once.
+	handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.ConnectionMetrics",
+		Fn: func(r *request.Request) {
+			labels := metricsLabels(r)
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					if info.Reused {
+						metrics.IncrCounter(MetricHTTPConnectionsReused, labels)
+					} else {
+						metrics.IncrCounter(MetricHTTPConnectionsNew, labels)
+					}
+				},
+				DNSDone: func(httptrace.DNSDoneInfo) {
+					metrics.IncrCounter(MetricHTTPDNSLookups, labels)
+				},
+				TLSHandshakeDone: func(tls.ConnectionState, error) {
+					metrics.IncrCounter(MetricHTTPTLSHandshakes, labels)
+				},
+			}
+			r.HTTPRequest = r.HTTPRequest.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.CallMetrics",
+		Fn: func(r *request.Request) {
+			labels := metricsLabels(r)
+			labels["status"] = "success"
+			if r.Error != nil {
+				labels["status"] = "error"
+			}
+			metrics.IncrCounter(MetricAPICalls, labels)
+			metrics.ObserveHistogram(MetricAPICallDuration, time.Since(r.Time).Seconds(), labels)
+		},
+	})
+}
+
+func metricsLabels(r *request.Request) map[string]string {
+	return map[string]string{
+		"service":   r.ClientInfo.ServiceName,
+		"operation": r.Operation.Name,
+	}
+}
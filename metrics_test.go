@@ -0,0 +1,199 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+type testMetrics struct {
+	sync.Mutex
+	counters   map[string]int
+	histograms map[string]int
+}
+
+func (m *testMetrics) IncrCounter(name string, labels map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+	m.counters[name+":"+labels["status"]]++
+}
+
+func (m *testMetrics) ObserveHistogram(name string, value float64, labels map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+	m.histograms[name+":"+labels["status"]]++
+}
+
+func TestAddMetricsHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description        string
+		MockEndpoints      []*MockEndpoint
+		ExpectedCounters   map[string]int
+		ExpectedHistograms map[string]int
+	}{
+		{
+			Description: "sts:GetCallerIdentity success",
+			MockEndpoints: []*MockEndpoint{
+				{
+					Request:  &MockRequest{"POST", "/", "Action=GetCallerIdentity&Version=2011-06-15"},
+					Response: &MockResponse{200, stsResponse_GetCallerIdentity_valid, "text/xml"},
+				},
+			},
+			ExpectedCounters: map[string]int{
+				MetricAPICalls + ":success": 1,
+			},
+			ExpectedHistograms: map[string]int{
+				MetricAPICallDuration + ":success": 1,
+			},
+		},
+		{
+			Description: "sts:GetCallerIdentity unauthorized",
+			MockEndpoints: []*MockEndpoint{
+				{
+					Request:  &MockRequest{"POST", "/", "Action=GetCallerIdentity&Version=2011-06-15"},
+					Response: &MockResponse{403, stsResponse_GetCallerIdentity_unauthorized, "text/xml"},
+				},
+			},
+			ExpectedCounters: map[string]int{
+				MetricAPICalls + ":error": 1,
+			},
+			ExpectedHistograms: map[string]int{
+				MetricAPICallDuration + ":error": 1,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			closeSts, stsSess, err := GetMockedAwsApiSession("STS", testCase.MockEndpoints)
+			defer closeSts()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics := &testMetrics{
+				counters:   make(map[string]int),
+				histograms: make(map[string]int),
+			}
+			addMetricsHandlers(&Config{Metrics: metrics}, &stsSess.Handlers)
+
+			_, _ = sts.New(stsSess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+
+			for name, expected := range testCase.ExpectedCounters {
+				if metrics.counters[name] != expected {
+					t.Errorf("Expected counter %q to be %d, got %d", name, expected, metrics.counters[name])
+				}
+			}
+			for name, expected := range testCase.ExpectedHistograms {
+				if metrics.histograms[name] != expected {
+					t.Errorf("Expected histogram %q to have %d observation(s), got %d", name, expected, metrics.histograms[name])
+				}
+			}
+		})
+	}
+}
+
+func TestAddMetricsHandlers_retries(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, stsResponse_GetCallerIdentity_throttled)
+			return
+		}
+		fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+	}))
+	defer ts.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Endpoint:    aws.String(ts.URL),
+		MaxRetries:  aws.Int(1),
+		Region:      aws.String("us-east-1"),
+		SleepDelay:  func(time.Duration) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := &testMetrics{
+		counters:   make(map[string]int),
+		histograms: make(map[string]int),
+	}
+	addMetricsHandlers(&Config{Metrics: metrics}, &sess.Handlers)
+
+	if _, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{}); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	for name, expected := range map[string]int{
+		MetricAPICalls + ":success": 1,
+		MetricAPIRetries + ":":      1,
+		MetricAPIThrottles + ":":    1,
+	} {
+		if metrics.counters[name] != expected {
+			t.Errorf("Expected counter %q to be %d, got %d", name, expected, metrics.counters[name])
+		}
+	}
+}
+
+const stsResponse_GetCallerIdentity_throttled = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>Throttling</Code>
+    <Message>Rate exceeded</Message>
+  </Error>
+  <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+</ErrorResponse>`
+
+func TestAddMetricsHandlers_connections(t *testing.T) {
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+	}))
+	defer ts.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Endpoint:    aws.String(ts.URL),
+		HTTPClient:  ts.Client(),
+		Region:      aws.String("us-east-1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := &testMetrics{
+		counters:   make(map[string]int),
+		histograms: make(map[string]int),
+	}
+	addMetricsHandlers(&Config{Metrics: metrics}, &sess.Handlers)
+
+	for i := 0; i < 2; i++ {
+		if _, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{}); err != nil {
+			t.Fatalf("Expected no error, received error: %s", err)
+		}
+	}
+
+	for name, expected := range map[string]int{
+		MetricHTTPConnectionsNew + ":":    1,
+		MetricHTTPConnectionsReused + ":": 1,
+		MetricHTTPTLSHandshakes + ":":     1,
+	} {
+		if metrics.counters[name] != expected {
+			t.Errorf("Expected counter %q to be %d, got %d", name, expected, metrics.counters[name])
+		}
+	}
+}
@@ -1,50 +1,26 @@
 package awsbase
 
 import (
-	"bytes"
-	"fmt"
-	"log"
-	"net/http"
-	"net/http/httptest"
-	"time"
+	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
 	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
 )
 
 // GetMockedAwsApiSession establishes a httptest server to simulate behaviour
 // of a real AWS API server
 func GetMockedAwsApiSession(svcName string, endpoints []*MockEndpoint) (func(), *session.Session, error) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		buf := new(bytes.Buffer)
-		if _, err := buf.ReadFrom(r.Body); err != nil {
-			w.WriteHeader(500)
-			fmt.Fprintf(w, "Error reading from HTTP Request Body: %s", err)
-			return
+	mockEndpoints := make([]*awsmocks.MockEndpoint, len(endpoints))
+	for i, e := range endpoints {
+		mockEndpoints[i] = &awsmocks.MockEndpoint{
+			Request:  (*awsmocks.MockRequest)(e.Request),
+			Response: (*awsmocks.MockResponse)(e.Response),
 		}
-		requestBody := buf.String()
+	}
 
-		log.Printf("[DEBUG] Received %s API %q request to %q: %s",
-			svcName, r.Method, r.RequestURI, requestBody)
-
-		for _, e := range endpoints {
-			if r.Method == e.Request.Method && r.RequestURI == e.Request.Uri && requestBody == e.Request.Body {
-				log.Printf("[DEBUG] Mocked %s API responding with %d: %s",
-					svcName, e.Response.StatusCode, e.Response.Body)
-
-				w.WriteHeader(e.Response.StatusCode)
-				w.Header().Set("Content-Type", e.Response.ContentType)
-				w.Header().Set("X-Amzn-Requestid", "1b206dd1-f9a8-11e5-becf-051c60f11c4a")
-				w.Header().Set("Date", time.Now().Format(time.RFC1123))
-
-				fmt.Fprintln(w, e.Response.Body)
-				return
-			}
-		}
-
-		w.WriteHeader(400)
-	}))
+	ts := awsmocks.NewServer(svcName, mockEndpoints)
 
 	sc := awsCredentials.NewStaticCredentials("accessKey", "secretKey", "")
 
@@ -58,6 +34,25 @@ func GetMockedAwsApiSession(svcName string, endpoints []*MockEndpoint) (func(),
 	return ts.Close, sess, err
 }
 
+// ConfigureMockedAwsApi points the Config IAM and STS endpoints at the mock
+// servers and sets the AWS_METADATA_URL environment variable to the mock EC2
+// metadata server. The returned function restores the environment.
+func ConfigureMockedAwsApi(c *Config, servers *awsmocks.Servers) func() {
+	c.IamEndpoint = servers.IamEndpoint()
+	c.StsEndpoint = servers.StsEndpoint()
+
+	metadataURL, metadataURLSet := os.LookupEnv("AWS_METADATA_URL")
+	os.Setenv("AWS_METADATA_URL", servers.EC2MetadataEndpoint())
+
+	return func() {
+		if metadataURLSet {
+			os.Setenv("AWS_METADATA_URL", metadataURL)
+		} else {
+			os.Unsetenv("AWS_METADATA_URL")
+		}
+	}
+}
+
 type MockEndpoint struct {
 	Request  *MockRequest
 	Response *MockResponse
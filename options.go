@@ -0,0 +1,111 @@
+package awsbase
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the MaxRetries of Configs created by
+	// DefaultConfig.
+	DefaultMaxRetries = 25
+	// DefaultStsCallTimeout is the StsCallTimeout of Configs created by
+	// DefaultConfig.
+	DefaultStsCallTimeout = 30 * time.Second
+)
+
+// Option configures a Config created by NewConfig or DefaultConfig.
+type Option func(*Config)
+
+// NewConfig returns a Config with the given options applied in order.
+func NewConfig(opts ...Option) *Config {
+	c := &Config{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// DefaultConfig returns a Config with the recommended defaults, rather than
+// the zero values of a Config literal, with the given options applied in
+// order:
+//
+//   - MaxRetries is DefaultMaxRetries, rather than no retries.
+//   - DefaultsMode is DefaultsModeStandard, with connect and TLS negotiation
+//     timeouts, regional endpoints, and the backoff of the standard retry
+//     mode, which is thereby the retry mode of the Config.
+//   - StsCallTimeout is DefaultStsCallTimeout, rather than no timeout.
+//
+// MetadataApiCheckAttempts is left unset, so that the
+// AWS_METADATA_SERVICE_NUM_ATTEMPTS environment variable, or else 3 attempts,
+// apply.
+func DefaultConfig(opts ...Option) *Config {
+	c := &Config{
+		DefaultsMode:   DefaultsModeStandard,
+		MaxRetries:     DefaultMaxRetries,
+		StsCallTimeout: DefaultStsCallTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithRegion sets the AWS region.
+func WithRegion(region string) Option {
+	return func(c *Config) {
+		c.Region = region
+	}
+}
+
+// WithProfile sets the shared configuration and credentials profile.
+func WithProfile(profile string) Option {
+	return func(c *Config) {
+		c.Profile = profile
+	}
+}
+
+// WithCredentials sets static credentials.
+func WithCredentials(accessKey, secretKey, token string) Option {
+	return func(c *Config) {
+		c.AccessKey = accessKey
+		c.SecretKey = secretKey
+		c.Token = token
+	}
+}
+
+// WithAssumeRole sets the ARN of the role to assume and the session name to
+// use when assuming it.
+func WithAssumeRole(roleARN, sessionName string) Option {
+	return func(c *Config) {
+		c.AssumeRole = &AssumeRole{
+			RoleARN:     roleARN,
+			SessionName: sessionName,
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for AWS API calls.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.HTTPClient = client
+	}
+}
+
+// WithMaxRetries sets the maximum number of retries for AWS API calls.
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Config) {
+		c.MaxRetries = maxRetries
+	}
+}
+
+// WithUserAgentProducts appends products to the User-Agent of AWS API calls.
+func WithUserAgentProducts(products ...*UserAgentProduct) Option {
+	return func(c *Config) {
+		c.UserAgentProducts = append(c.UserAgentProducts, products...)
+	}
+}
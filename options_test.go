@@ -0,0 +1,123 @@
+package awsbase
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNewConfig(t *testing.T) {
+	httpClient := &http.Client{}
+
+	testCases := []struct {
+		Description string
+		Options     []Option
+		Expected    *Config
+	}{
+		{
+			Description: "no options",
+			Expected:    &Config{},
+		},
+		{
+			Description: "region and profile",
+			Options:     []Option{WithRegion("us-west-2"), WithProfile("myprofile")},
+			Expected: &Config{
+				Profile: "myprofile",
+				Region:  "us-west-2",
+			},
+		},
+		{
+			Description: "credentials",
+			Options:     []Option{WithCredentials("accessKey", "secretKey", "token")},
+			Expected: &Config{
+				AccessKey: "accessKey",
+				SecretKey: "secretKey",
+				Token:     "token",
+			},
+		},
+		{
+			Description: "assume role",
+			Options:     []Option{WithAssumeRole("arn:aws:iam::555555555555:role/AssumeRole", "session")},
+			Expected: &Config{
+				AssumeRole: &AssumeRole{
+					RoleARN:     "arn:aws:iam::555555555555:role/AssumeRole",
+					SessionName: "session",
+				},
+			},
+		},
+		{
+			Description: "HTTP client and max retries",
+			Options:     []Option{WithHTTPClient(httpClient), WithMaxRetries(5)},
+			Expected: &Config{
+				HTTPClient: httpClient,
+				MaxRetries: 5,
+			},
+		},
+		{
+			Description: "user agent products are appended",
+			Options: []Option{
+				WithUserAgentProducts(&UserAgentProduct{Name: "first"}),
+				WithUserAgentProducts(&UserAgentProduct{Name: "second"}),
+			},
+			Expected: &Config{
+				UserAgentProducts: []*UserAgentProduct{{Name: "first"}, {Name: "second"}},
+			},
+		},
+		{
+			Description: "later options take precedence",
+			Options:     []Option{WithRegion("us-west-2"), WithRegion("us-east-1")},
+			Expected:    &Config{Region: "us-east-1"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			c := NewConfig(testCase.Options...)
+
+			if !reflect.DeepEqual(c, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, c)
+			}
+		})
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Options     []Option
+		Expected    *Config
+	}{
+		{
+			Description: "no options",
+			Expected: &Config{
+				DefaultsMode:   DefaultsModeStandard,
+				MaxRetries:     DefaultMaxRetries,
+				StsCallTimeout: DefaultStsCallTimeout,
+			},
+		},
+		{
+			Description: "options override defaults",
+			Options:     []Option{WithRegion("us-west-2"), WithMaxRetries(5)},
+			Expected: &Config{
+				DefaultsMode:   DefaultsModeStandard,
+				MaxRetries:     5,
+				Region:         "us-west-2",
+				StsCallTimeout: DefaultStsCallTimeout,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			c := DefaultConfig(testCase.Options...)
+
+			if !reflect.DeepEqual(c, testCase.Expected) {
+				t.Errorf("expected %+v, got %+v", testCase.Expected, c)
+			}
+		})
+	}
+}
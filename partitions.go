@@ -0,0 +1,112 @@
+package awsbase
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// partitionDNSSuffixes are the DNS suffixes of the endpoints of each
+// partition.
+var partitionDNSSuffixes = map[string]string{
+	endpoints.AwsPartitionID:      "amazonaws.com",
+	endpoints.AwsCnPartitionID:    "amazonaws.com.cn",
+	endpoints.AwsUsGovPartitionID: "amazonaws.com",
+	endpoints.AwsIsoPartitionID:   "c2s.ic.gov",
+	endpoints.AwsIsoBPartitionID:  "sc2s.sgov.gov",
+	endpoints.AwsIsoEPartitionID:  "cloud.adc-e.uk",
+	endpoints.AwsIsoFPartitionID:  "csp.hci.ic.gov",
+}
+
+// Partition is an AWS partition, e.g. aws or aws-cn, as known to the AWS Go
+// SDK endpoints model.
+type Partition struct {
+	// ID is the partition ID, e.g. aws-us-gov.
+	ID string
+	// DNSSuffix is the DNS suffix of the endpoints of the partition, e.g.
+	// amazonaws.com.cn, or an empty string if it is not known.
+	DNSSuffix string
+	// ServicePrincipalSuffix is the suffix of the principals of AWS services
+	// in trust policies in the partition, e.g. c2s.ic.gov in ec2.c2s.ic.gov.
+	ServicePrincipalSuffix string
+	// Regions are the regions of the partition, sorted by ID.
+	Regions []Region
+}
+
+// Region is an AWS region of a partition.
+type Region struct {
+	// ID is the region ID, e.g. us-west-2.
+	ID string
+	// Description is the human-readable description, e.g. US West (Oregon).
+	Description string
+}
+
+// Partitions returns the partitions known to the AWS Go SDK, with their
+// regions, e.g. to validate user input or build region pickers.
+func Partitions() []Partition {
+	var partitions []Partition
+	for _, p := range endpoints.DefaultPartitions() {
+		partitions = append(partitions, newPartition(p))
+	}
+	return partitions
+}
+
+// PartitionForRegion returns the partition of the region. Regions not yet
+// known to the AWS Go SDK are matched by their partition's naming pattern,
+// e.g. cn-* regions belong to the aws-cn partition.
+func PartitionForRegion(region string) (Partition, bool) {
+	id := partitionForRegion(region)
+	for _, p := range endpoints.DefaultPartitions() {
+		if p.ID() == id {
+			return newPartition(p), true
+		}
+	}
+	return Partition{}, false
+}
+
+func newPartition(p endpoints.Partition) Partition {
+	partition := Partition{
+		ID:                     p.ID(),
+		DNSSuffix:              partitionDNSSuffixes[p.ID()],
+		ServicePrincipalSuffix: servicePrincipalSuffix(p.ID()),
+	}
+	for _, r := range p.Regions() {
+		partition.Regions = append(partition.Regions, Region{
+			ID:          r.ID(),
+			Description: r.Description(),
+		})
+	}
+	sort.Slice(partition.Regions, func(i, j int) bool {
+		return partition.Regions[i].ID < partition.Regions[j].ID
+	})
+	return partition
+}
+
+// PartitionDNSSuffix returns the DNS suffix of the endpoints of the partition,
+// e.g. amazonaws.com.cn for aws-cn, or an empty string if it is not known.
+func PartitionDNSSuffix(partitionID string) string {
+	return partitionDNSSuffixes[partitionID]
+}
+
+// ServicePrincipal returns the principal of the AWS service in trust policies
+// in the partition, e.g. ec2.amazonaws.com for ec2 in aws, or an empty string
+// if the partition is not known.
+func ServicePrincipal(service, partitionID string) string {
+	suffix := servicePrincipalSuffix(partitionID)
+	if suffix == "" {
+		return ""
+	}
+	return service + "." + suffix
+}
+
+// servicePrincipalSuffix returns the suffix of service principals in the
+// partition. Service principals in aws-cn and aws-us-gov use amazonaws.com,
+// while those in the isolated partitions use their DNS suffix.
+func servicePrincipalSuffix(partitionID string) string {
+	switch partitionID {
+	case endpoints.AwsCnPartitionID, endpoints.AwsUsGovPartitionID:
+		return "amazonaws.com"
+	default:
+		return partitionDNSSuffixes[partitionID]
+	}
+}
@@ -0,0 +1,101 @@
+package awsbase
+
+import (
+	"testing"
+)
+
+func TestPartitions(t *testing.T) {
+	partitions := Partitions()
+
+	var found bool
+	for _, partition := range partitions {
+		if partition.ID != "aws-cn" {
+			continue
+		}
+		found = true
+
+		if len(partition.Regions) == 0 || partition.Regions[0].ID != "cn-north-1" {
+			t.Errorf("Expected regions sorted by ID starting with cn-north-1, got %v", partition.Regions)
+		}
+	}
+	if !found {
+		t.Fatal("Expected aws-cn partition, got none")
+	}
+}
+
+func TestPartitionForRegion_exported(t *testing.T) {
+	var testCases = []struct {
+		Region            string
+		ExpectedPartition string
+		ExpectedOK        bool
+	}{
+		{
+			Region:            "us-west-2",
+			ExpectedPartition: "aws",
+			ExpectedOK:        true,
+		},
+		{
+			Region:            "us-gov-west-1",
+			ExpectedPartition: "aws-us-gov",
+			ExpectedOK:        true,
+		},
+		{
+			Region:            "cn-south-9",
+			ExpectedPartition: "aws-cn",
+			ExpectedOK:        true,
+		},
+		{
+			Region: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Region, func(t *testing.T) {
+			partition, ok := PartitionForRegion(testCase.Region)
+			if ok != testCase.ExpectedOK {
+				t.Fatalf("Expected ok %t, got %t", testCase.ExpectedOK, ok)
+			}
+			if partition.ID != testCase.ExpectedPartition {
+				t.Errorf("Expected partition %q, got %q", testCase.ExpectedPartition, partition.ID)
+			}
+		})
+	}
+}
+
+func TestPartitionSuffixes(t *testing.T) {
+	var testCases = []struct {
+		Partition                string
+		ExpectedDNSSuffix        string
+		ExpectedServicePrincipal string
+	}{
+		{
+			Partition:                "aws",
+			ExpectedDNSSuffix:        "amazonaws.com",
+			ExpectedServicePrincipal: "ec2.amazonaws.com",
+		},
+		{
+			Partition:                "aws-cn",
+			ExpectedDNSSuffix:        "amazonaws.com.cn",
+			ExpectedServicePrincipal: "ec2.amazonaws.com",
+		},
+		{
+			Partition:                "aws-iso",
+			ExpectedDNSSuffix:        "c2s.ic.gov",
+			ExpectedServicePrincipal: "ec2.c2s.ic.gov",
+		},
+		{
+			Partition: "unknown",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Partition, func(t *testing.T) {
+			if got := PartitionDNSSuffix(testCase.Partition); got != testCase.ExpectedDNSSuffix {
+				t.Errorf("Expected DNS suffix %q, got %q", testCase.ExpectedDNSSuffix, got)
+			}
+			if got := ServicePrincipal("ec2", testCase.Partition); got != testCase.ExpectedServicePrincipal {
+				t.Errorf("Expected service principal %q, got %q", testCase.ExpectedServicePrincipal, got)
+			}
+		})
+	}
+}
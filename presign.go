@@ -0,0 +1,43 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// PresignGetCallerIdentity returns a presigned sts:GetCallerIdentity URL for
+// the session, built by GetSession from the Config, which is valid for the
+// given duration, and the headers which must be sent with it. The given
+// headers, e.g. x-k8s-aws-id for EKS authentication tokens, are signed, so
+// that systems verifying identities by calling the URL can bind it to their
+// audience.
+func PresignGetCallerIdentity(sess *session.Session, c *Config, headers map[string]string, expiry time.Duration) (string, http.Header, error) {
+	conn, err := NewClient(sess, c, sts.EndpointsID, sts.New)
+	if err != nil {
+		return "", nil, err
+	}
+
+	req, _ := conn.GetCallerIdentityRequest(&sts.GetCallerIdentityInput{})
+	for key, value := range headers {
+		req.HTTPRequest.Header.Set(key, value)
+	}
+
+	url, signedHeaders, err := req.PresignRequest(expiry)
+	if err != nil {
+		return "", nil, fmt.Errorf("error presigning STS GetCallerIdentity request: %w", err)
+	}
+
+	// The signer returns the headers keyed by their lowercase names.
+	header := make(http.Header, len(signedHeaders))
+	for key, values := range signedHeaders {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	return url, header, nil
+}
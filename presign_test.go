@@ -0,0 +1,54 @@
+package awsbase
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestPresignGetCallerIdentity(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Region:      aws.String("us-west-2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	presignedURL, headers, err := PresignGetCallerIdentity(sess, &Config{StsEndpoint: "https://sts.example.com"}, map[string]string{"x-k8s-aws-id": "example-cluster"}, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	u, err := url.Parse(presignedURL)
+	if err != nil {
+		t.Fatalf("Error parsing presigned URL %q: %s", presignedURL, err)
+	}
+
+	if u.Host != "sts.example.com" {
+		t.Errorf("Expected host %q, got %q", "sts.example.com", u.Host)
+	}
+
+	query := u.Query()
+	for key, expected := range map[string]string{
+		"Action":              "GetCallerIdentity",
+		"X-Amz-Expires":       "900",
+		"X-Amz-SignedHeaders": "host;x-k8s-aws-id",
+	} {
+		if got := query.Get(key); got != expected {
+			t.Errorf("Expected %s %q, got %q", key, expected, got)
+		}
+	}
+	if credential := query.Get("X-Amz-Credential"); !strings.HasPrefix(credential, "accessKey/") {
+		t.Errorf("Expected credential of access key %q, got %q", "accessKey", credential)
+	}
+
+	if got := headers.Get("x-k8s-aws-id"); got != "example-cluster" {
+		t.Errorf("Expected signed header x-k8s-aws-id %q, got %q", "example-cluster", got)
+	}
+}
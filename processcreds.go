@@ -0,0 +1,110 @@
+package awsbase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+)
+
+// credentialProcessExpiryWindow is how long before their expiration the
+// credentials of a credential process are refreshed.
+const credentialProcessExpiryWindow = 1 * time.Minute
+
+// CredentialProcess describes an external command which outputs credentials
+// in the format of the shared configuration credential_process setting.
+type CredentialProcess struct {
+	Args    []string      `json:"args,omitempty" hcl:"args,optional"`
+	Command string        `json:"command,omitempty" hcl:"command,optional"`
+	Timeout time.Duration `json:"timeout,omitempty" hcl:"timeout,optional"`
+}
+
+// credentialProcessProvider retrieves credentials by running a
+// CredentialProcess. Unlike the AWS Go SDK ProcessProvider, the command is run
+// directly rather than through a shell, so arguments are passed as given.
+type credentialProcessProvider struct {
+	process *CredentialProcess
+	clock   Clock
+
+	mu         sync.Mutex
+	expiration time.Time
+	retrieved  bool
+}
+
+func (p *credentialProcessProvider) Retrieve() (awsCredentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	timeout := p.process.Timeout
+	if timeout <= 0 {
+		timeout = processcreds.DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.process.Command, p.process.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		return awsCredentials.Value{ProviderName: processcreds.ProviderName}, fmt.Errorf("error running credential process %q: %w: %s",
+			p.process.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp processcreds.CredentialProcessResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return awsCredentials.Value{ProviderName: processcreds.ProviderName}, fmt.Errorf("error parsing credential process %q output: %w", p.process.Command, err)
+	}
+	if resp.Version != 1 {
+		return awsCredentials.Value{ProviderName: processcreds.ProviderName}, fmt.Errorf("unsupported credential process %q output version: %d", p.process.Command, resp.Version)
+	}
+	if resp.AccessKeyID == "" || resp.SecretAccessKey == "" {
+		return awsCredentials.Value{ProviderName: processcreds.ProviderName}, fmt.Errorf("credential process %q output is missing the access key or secret key", p.process.Command)
+	}
+
+	p.expiration = time.Time{}
+	if resp.Expiration != nil {
+		p.expiration = resp.Expiration.Add(-credentialProcessExpiryWindow)
+	}
+	p.retrieved = true
+
+	return awsCredentials.Value{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.SessionToken,
+		ProviderName:    processcreds.ProviderName,
+	}, nil
+}
+
+// IsExpired returns true if the credentials have not been retrieved or are
+// about to expire. Credentials without an expiration never expire.
+func (p *credentialProcessProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.retrieved {
+		return true
+	}
+	return !p.expiration.IsZero() && resolveClock(p.clock).Now().After(p.expiration)
+}
+
+// ExpiresAt returns the expiration of the credentials, or the zero time if
+// they don't expire.
+func (p *credentialProcessProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expiration
+}
@@ -0,0 +1,106 @@
+package awsbase
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestCredentialProcessHelper is run as the credential process by the tests
+// below, printing its last argument.
+func TestCredentialProcessHelper(t *testing.T) {
+	if os.Getenv("AWSBASE_CREDENTIAL_PROCESS_HELPER") != "1" {
+		return
+	}
+
+	switch output := os.Args[len(os.Args)-1]; output {
+	case "sleep":
+		time.Sleep(time.Minute)
+	case "fail":
+		fmt.Fprint(os.Stderr, "helper failed")
+		os.Exit(1)
+	default:
+		fmt.Print(output)
+	}
+	os.Exit(0)
+}
+
+func TestCredentialProcessProvider(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		Output            string
+		Timeout           time.Duration
+		ExpectedAccessKey string
+		ExpectedExpired   bool
+		ExpectedError     bool
+	}{
+		{
+			Description:       "static credentials",
+			Output:            `{"Version": 1, "AccessKeyId": "accessKey", "SecretAccessKey": "secretKey"}`,
+			ExpectedAccessKey: "accessKey",
+		},
+		{
+			Description:       "expiring credentials",
+			Output:            `{"Version": 1, "AccessKeyId": "accessKey", "SecretAccessKey": "secretKey", "SessionToken": "token", "Expiration": "2000-01-01T00:00:00Z"}`,
+			ExpectedAccessKey: "accessKey",
+			ExpectedExpired:   true,
+		},
+		{
+			Description:   "unsupported version",
+			Output:        `{"Version": 2, "AccessKeyId": "accessKey", "SecretAccessKey": "secretKey"}`,
+			ExpectedError: true,
+		},
+		{
+			Description:   "missing secret key",
+			Output:        `{"Version": 1, "AccessKeyId": "accessKey"}`,
+			ExpectedError: true,
+		},
+		{
+			Description:   "process failure",
+			Output:        "fail",
+			ExpectedError: true,
+		},
+		{
+			Description:   "timeout",
+			Output:        "sleep",
+			Timeout:       100 * time.Millisecond,
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			t.Setenv("AWSBASE_CREDENTIAL_PROCESS_HELPER", "1")
+
+			provider := &credentialProcessProvider{process: &CredentialProcess{
+				Args:    []string{"-test.run=TestCredentialProcessHelper", "--", testCase.Output},
+				Command: os.Args[0],
+				Timeout: testCase.Timeout,
+			}}
+
+			value, err := provider.Retrieve()
+			if testCase.ExpectedError {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if value.AccessKeyID != testCase.ExpectedAccessKey {
+				t.Errorf("Expected access key %q, got %q", testCase.ExpectedAccessKey, value.AccessKeyID)
+			}
+			if value.ProviderName != ProcessProviderName {
+				t.Errorf("Expected provider name %q, got %q", ProcessProviderName, value.ProviderName)
+			}
+			if expired := provider.IsExpired(); expired != testCase.ExpectedExpired {
+				t.Errorf("Expected expired to be %t, got %t", testCase.ExpectedExpired, expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package awsbase
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ProxyNegotiateTokenProvider returns a SPNEGO token for authenticating to the
+// given proxy with the Negotiate scheme, e.g. generated for the HTTP/<proxy
+// host> service principal with a Kerberos library or Windows SSPI.
+type ProxyNegotiateTokenProvider func(ctx context.Context, proxyURL *url.URL) ([]byte, error)
+
+// ProxyNegotiateConnectHeader returns a function suitable for
+// http.Transport.GetProxyConnectHeader which authenticates each proxy CONNECT
+// request with a token from the given provider.
+func ProxyNegotiateConnectHeader(tokenProvider ProxyNegotiateTokenProvider) func(context.Context, *url.URL, string) (http.Header, error) {
+	return func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+		token, err := tokenProvider(ctx, proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("error generating Negotiate token for proxy %s: %w", proxyURL.Host, err)
+		}
+
+		header := make(http.Header)
+		header.Set("Proxy-Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+		return header, nil
+	}
+}
+
+// configureProxyAuthentication configures the given transport, built by this
+// package, to authenticate to proxies as configured by the Config.
+func configureProxyAuthentication(c *Config, transport *http.Transport) {
+	if c.ProxyNegotiateTokenProvider != nil {
+		transport.GetProxyConnectHeader = ProxyNegotiateConnectHeader(c.ProxyNegotiateTokenProvider)
+	}
+}
+
+// ConfigureProxyClientCertificate configures the given transport to present
+// the proxy client certificate of the Config in the TLS handshake with HTTPS
+// proxies, as zero-trust proxy deployments require, independently of the
+// client certificate, if any, presented to AWS endpoints. It does nothing if
+// the Config sets no proxy client certificate.
+//
+// The transport would use its TLSClientConfig for both handshakes, so the
+// proxy is instead reported to it as an HTTP proxy and the connections to it
+// are wrapped in TLS by its DialContext.
+func ConfigureProxyClientCertificate(c *Config, transport *http.Transport) error {
+	if c.ProxyClientCertFilename == "" && c.ProxyClientKeyFilename == "" {
+		return nil
+	}
+	if c.ProxyClientCertFilename == "" || c.ProxyClientKeyFilename == "" {
+		return errors.New("both proxy client certificate and key files must be set")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.ProxyClientCertFilename, c.ProxyClientKeyFilename)
+	if err != nil {
+		return fmt.Errorf("error loading proxy client certificate: %w", err)
+	}
+
+	proxy := transport.Proxy
+	if proxy == nil {
+		return nil
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	// httpsProxies are the addresses of the HTTPS proxies returned by the
+	// proxy function, mapped to their host names.
+	var httpsProxies sync.Map
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.Scheme != "https" {
+			return proxyURL, err
+		}
+
+		address := proxyURL.Host
+		if proxyURL.Port() == "" {
+			address = net.JoinHostPort(proxyURL.Hostname(), "443")
+		}
+		httpsProxies.Store(address, proxyURL.Hostname())
+
+		tunnelURL := *proxyURL
+		tunnelURL.Scheme = "http"
+		tunnelURL.Host = address
+		return &tunnelURL, nil
+	}
+
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		serverName, ok := httpsProxies.Load(address)
+		if !ok {
+			return conn, nil
+		}
+
+		// The TLSClientConfig is read when dialing, as the AWS Go SDK sets
+		// the CA bundle on it after the transport is built.
+		tlsConfig := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			tlsConfig = transport.TLSClientConfig.Clone()
+		}
+		tlsConfig.ServerName = serverName.(string)
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+		tlsConfig.GetClientCertificate = nil
+		tlsConfig.NextProtos = nil
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to proxy %s: %w", address, err)
+		}
+		return tlsConn, nil
+	}
+
+	return nil
+}
+
+// ProxyRule routes HTTP requests to matching hosts through a proxy, or
+// directly, overriding the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+// variables, e.g. so that STS and IAM traffic uses a corporate proxy while
+// other endpoints are reached directly.
+type ProxyRule struct {
+	// Hosts is a comma-separated list of hosts the rule applies to, in the
+	// syntax of NO_PROXY: host names, which also match their subdomains,
+	// domains prefixed by "." or "*.", which only match subdomains, IP
+	// addresses, CIDR blocks, or "*" for all hosts.
+	Hosts string `json:"hosts,omitempty" hcl:"hosts,optional"`
+	// Proxy is the URL of the proxy for the hosts, or empty to connect to them
+	// directly.
+	Proxy string `json:"proxy,omitempty" hcl:"proxy,optional"`
+}
+
+// ec2MetadataIPv6Address is the IPv6 address of the EC2 metadata API.
+var ec2MetadataIPv6Address = net.ParseIP("fd00:ec2::254")
+
+// NewProxyFunc returns a function suitable for http.Transport.Proxy which
+// applies the ProxyRules of the Config in order, the first matching rule
+// winning, and otherwise the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+// variables. The EC2 metadata API and ECS container credentials endpoints,
+// which are link-local and unreachable through proxies, are connected to
+// directly unless a rule matches them.
+func NewProxyFunc(c *Config) (func(*http.Request) (*url.URL, error), error) {
+	type rule struct {
+		hosts    []string
+		proxyURL *url.URL
+	}
+
+	rules := make([]rule, 0, len(c.ProxyRules))
+	for _, r := range c.ProxyRules {
+		var proxyURL *url.URL
+		if r.Proxy != "" {
+			proxy := r.Proxy
+			if !strings.Contains(proxy, "://") {
+				proxy = "http://" + proxy
+			}
+
+			var err error
+			proxyURL, err = url.Parse(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy %q for hosts %q: %w", r.Proxy, r.Hosts, err)
+			}
+		}
+
+		var hosts []string
+		for _, host := range strings.Split(r.Hosts, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		rules = append(rules, rule{hosts: hosts, proxyURL: proxyURL})
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+
+		for _, r := range rules {
+			for _, pattern := range r.hosts {
+				if proxyHostMatches(pattern, host) {
+					return r.proxyURL, nil
+				}
+			}
+		}
+
+		if ip := net.ParseIP(host); ip != nil && (ip.IsLinkLocalUnicast() || ip.Equal(ec2MetadataIPv6Address)) {
+			return nil, nil
+		}
+
+		return http.ProxyFromEnvironment(req)
+	}, nil
+}
+
+// proxyHostMatches returns whether the lowercase host matches the pattern, in
+// the syntax of NO_PROXY.
+func proxyHostMatches(pattern, host string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	if _, network, err := net.ParseCIDR(pattern); err == nil {
+		ip := net.ParseIP(host)
+		return ip != nil && network.Contains(ip)
+	}
+	if ip := net.ParseIP(pattern); ip != nil {
+		return ip.Equal(net.ParseIP(host))
+	}
+
+	if strings.HasPrefix(pattern, "*.") {
+		pattern = pattern[1:]
+	}
+	if strings.HasPrefix(pattern, ".") {
+		return strings.HasSuffix(host, pattern)
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// configureProxy configures the given transport, built by this package, to
+// connect through the proxies of the Config, and to authenticate to them.
+func configureProxy(c *Config, transport *http.Transport) error {
+	proxy, err := NewProxyFunc(c)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = proxy
+
+	configureProxyAuthentication(c, transport)
+	return nil
+}
@@ -0,0 +1,305 @@
+package awsbase
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProxyNegotiateConnectHeader(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+
+	// proxy is a CONNECT proxy requiring the Negotiate token "token".
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Negotiate dG9rZW4=" {
+			w.Header().Set("Proxy-Authenticate", "Negotiate")
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		targetConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer targetConn.Close()
+
+		w.WriteHeader(http.StatusOK)
+		clientConn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer clientConn.Close()
+
+		go io.Copy(targetConn, clientConn)
+		io.Copy(clientConn, targetConn)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description   string
+		TokenProvider ProxyNegotiateTokenProvider
+		ExpectedError bool
+	}{
+		{
+			Description:   "no authentication",
+			ExpectedError: true,
+		},
+		{
+			Description: "valid token",
+			TokenProvider: func(ctx context.Context, proxyURL *url.URL) ([]byte, error) {
+				return []byte("token"), nil
+			},
+		},
+		{
+			Description: "token provider error",
+			TokenProvider: func(ctx context.Context, proxyURL *url.URL) ([]byte, error) {
+				return nil, errors.New("no Kerberos credentials")
+			},
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			transport := &http.Transport{
+				Proxy:           http.ProxyURL(proxyURL),
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+			configureProxyAuthentication(&Config{ProxyNegotiateTokenProvider: testCase.TokenProvider}, transport)
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(target.URL)
+			if testCase.ExpectedError {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("Expected status code %d, got %d", http.StatusNoContent, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestConfigureProxyClientCertificate(t *testing.T) {
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	var targetClientCertificates int32
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&targetClientCertificates, int32(len(r.TLS.PeerCertificates)))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	target.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	target.StartTLS()
+	defer target.Close()
+
+	// proxy is an HTTPS CONNECT proxy requiring a client certificate.
+	proxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		targetConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer targetConn.Close()
+
+		w.WriteHeader(http.StatusOK)
+		clientConn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer clientConn.Close()
+
+		go io.Copy(targetConn, clientConn)
+		io.Copy(clientConn, targetConn)
+	}))
+	proxy.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	proxy.StartTLS()
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFilename, keyFilename := writeClientCertificate(t)
+
+	var testCases = []struct {
+		Description   string
+		Config        *Config
+		ExpectedError bool
+	}{
+		{
+			Description:   "no client certificate",
+			Config:        &Config{},
+			ExpectedError: true,
+		},
+		{
+			Description: "client certificate",
+			Config: &Config{
+				ProxyClientCertFilename: certFilename,
+				ProxyClientKeyFilename:  keyFilename,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			transport := &http.Transport{
+				Proxy:           http.ProxyURL(proxyURL),
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}
+			if err := ConfigureProxyClientCertificate(testCase.Config, transport); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(target.URL)
+			if testCase.ExpectedError {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("Expected status code %d, got %d", http.StatusNoContent, resp.StatusCode)
+			}
+			if n := atomic.LoadInt32(&targetClientCertificates); n != 0 {
+				t.Errorf("Expected no client certificate presented to the target, got %d", n)
+			}
+		})
+	}
+}
+
+func TestConfigureProxyClientCertificate_missingKey(t *testing.T) {
+	certFilename, _ := writeClientCertificate(t)
+
+	err := ConfigureProxyClientCertificate(&Config{ProxyClientCertFilename: certFilename}, &http.Transport{})
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	config := &Config{
+		ProxyRules: []ProxyRule{
+			{Hosts: "iam.amazonaws.com, .api.aws", Proxy: "proxy.example.com:3128"},
+			{Hosts: "10.0.0.0/8,*.internal.example.com"},
+			{Hosts: "fd00:ec2::254", Proxy: "https://metadata-proxy.example.com"},
+			{Hosts: "amazonaws.com", Proxy: "http://default-proxy.example.com:8080"},
+		},
+	}
+
+	proxy, err := NewProxyFunc(config)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	var testCases = []struct {
+		URL           string
+		ExpectedProxy string
+	}{
+		{URL: "https://iam.amazonaws.com/", ExpectedProxy: "http://proxy.example.com:3128"},
+		{URL: "https://sts.us-west-2.api.aws/", ExpectedProxy: "http://proxy.example.com:3128"},
+		{URL: "https://STS.US-WEST-2.API.AWS/", ExpectedProxy: "http://proxy.example.com:3128"},
+		{URL: "https://sts.us-west-2.amazonaws.com/", ExpectedProxy: "http://default-proxy.example.com:8080"},
+		{URL: "https://amazonaws.com/", ExpectedProxy: "http://default-proxy.example.com:8080"},
+		{URL: "https://10.1.2.3:8443/"},
+		{URL: "https://api.internal.example.com/"},
+		{URL: "http://169.254.169.254/latest/meta-data/"},
+		{URL: "http://169.254.170.2/v2/credentials"},
+		{URL: "http://[fd00:ec2::254]/latest/meta-data/", ExpectedProxy: "https://metadata-proxy.example.com"},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.URL, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, testCase.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxyURL, err := proxy(req)
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			var got string
+			if proxyURL != nil {
+				got = proxyURL.String()
+			}
+			if got != testCase.ExpectedProxy {
+				t.Errorf("Expected proxy %q, got %q", testCase.ExpectedProxy, got)
+			}
+		})
+	}
+}
+
+func TestNewProxyFunc_allHosts(t *testing.T) {
+	proxy, err := NewProxyFunc(&Config{ProxyRules: []ProxyRule{{Hosts: "*", Proxy: "http://proxy.example.com"}}})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	// Rules override the direct connections to the EC2 metadata API.
+	req, err := http.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL, err := proxy(req)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if proxyURL == nil || proxyURL.Host != "proxy.example.com" {
+		t.Errorf("Expected proxy proxy.example.com, got %v", proxyURL)
+	}
+}
+
+func TestNewProxyFunc_invalidProxy(t *testing.T) {
+	_, err := NewProxyFunc(&Config{ProxyRules: []ProxyRule{{Hosts: "*", Proxy: "http://[::1"}}})
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+}
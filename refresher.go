@@ -0,0 +1,193 @@
+package awsbase
+
+import (
+	"sync"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+const (
+	// defaultCredentialsRefreshLead is how long before credentials expire a
+	// CredentialsRefresher refreshes them by default.
+	defaultCredentialsRefreshLead = 5 * time.Minute
+
+	// defaultCredentialsRefreshRetryInterval is how long a
+	// CredentialsRefresher waits by default before retrying a failed refresh.
+	defaultCredentialsRefreshRetryInterval = time.Minute
+)
+
+// CredentialsRefresher retrieves the credentials returned by GetCredentials
+// in the background, and refreshes them before they expire, e.g. assumed role
+// credentials, so that API calls neither wait for nor fail on their refresh.
+// If a refresh fails, the current credentials are used until they expire
+// while it is retried.
+//
+// Set Config.CredentialsRefresher to start refreshing the credentials once
+// they are resolved, read its Health to monitor it, and Stop it when the
+// credentials are no longer used. Resolving credentials again with the same
+// CredentialsRefresher stops refreshing the previous ones.
+type CredentialsRefresher struct {
+	// Lead is how long before the credentials expire they are refreshed,
+	// in addition to the expiry window of their provider. Defaults to 5
+	// minutes.
+	Lead time.Duration
+	// RetryInterval is how long to wait before retrying a failed refresh, and
+	// between checks of credentials which don't expire. Defaults to 1 minute.
+	RetryInterval time.Duration
+
+	mu     sync.Mutex
+	health CredentialsRefresherHealth
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// CredentialsRefresherHealth is the state of a CredentialsRefresher.
+type CredentialsRefresherHealth struct {
+	// Running is whether credentials are being refreshed.
+	Running bool
+	// ExpiresAt is when the current credentials expire, less the expiry
+	// window of their provider, or the zero time if they don't expire or
+	// haven't been retrieved.
+	ExpiresAt time.Time
+	// LastAttempt is when credentials were last retrieved.
+	LastAttempt time.Time
+	// LastRefresh is when credentials were last retrieved successfully.
+	LastRefresh time.Time
+	// LastError is the error of the last retrieval, or nil if it succeeded.
+	LastError error
+}
+
+// Healthy returns whether credentials are being refreshed, and the last
+// retrieval succeeded.
+func (h CredentialsRefresherHealth) Healthy() bool {
+	return h.Running && h.LastError == nil
+}
+
+// Health returns the current state of the CredentialsRefresher.
+func (r *CredentialsRefresher) Health() CredentialsRefresherHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.health
+}
+
+// Stop stops refreshing the credentials, waiting for a refresh in progress.
+// The credentials are then refreshed as they expire.
+func (r *CredentialsRefresher) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// start starts refreshing the credentials of the chain provider in the
+// background, stopping the refresh of previous credentials. It does nothing
+// if the CredentialsRefresher is nil.
+func (r *CredentialsRefresher) start(creds *awsCredentials.Credentials, chain *expiringChainProvider, clock Clock) {
+	if r == nil {
+		return
+	}
+	r.Stop()
+
+	lead := r.Lead
+	if lead <= 0 {
+		lead = defaultCredentialsRefreshLead
+	}
+	retryInterval := r.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultCredentialsRefreshRetryInterval
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	r.health = CredentialsRefresherHealth{Running: true}
+
+	logger.Printf("[DEBUG] Refreshing credentials in the background %s before they expire", lead)
+	go r.run(creds, chain, resolveClock(clock), lead, retryInterval, r.stop, r.done)
+}
+
+// run retrieves the credentials, unless they have been retrieved already, and
+// refreshes them the lead time before they expire until stopped.
+func (r *CredentialsRefresher) run(creds *awsCredentials.Credentials, chain *expiringChainProvider, clock Clock, lead, retryInterval time.Duration, stop, done chan struct{}) {
+	defer func() {
+		r.mu.Lock()
+		r.health.Running = false
+		r.mu.Unlock()
+		close(done)
+	}()
+
+	var err error
+	refreshed := false
+	for {
+		var wait time.Duration
+		refresh := true
+		expiresAt, expires := CredentialsExpiresAt(creds)
+		switch {
+		case err != nil:
+			wait = retryInterval
+		case expires:
+			wait = expiresAt.Add(-lead).Sub(clock.Now())
+			// Don't refresh continuously if the lead time exceeds the
+			// lifetime of the credentials.
+			if refreshed && wait < retryInterval {
+				wait = retryInterval
+			}
+		case refreshed:
+			// The credentials don't expire, but check them again in case
+			// they are retrieved from another provider later.
+			wait, refresh = retryInterval, false
+		}
+
+		if wait > 0 {
+			timer, stopTimer := newTimer(clock, wait)
+			select {
+			case <-timer:
+			case <-stop:
+				stopTimer()
+				return
+			}
+		}
+
+		if !refresh {
+			r.setExpiresAt(creds)
+			continue
+		}
+
+		logger.Print("[DEBUG] Refreshing credentials in the background")
+		attempt := clock.Now()
+		chain.requestRefresh()
+		if _, err = creds.Get(); err == nil {
+			err = chain.refreshError()
+		}
+		if err != nil {
+			logger.Printf("[WARN] Error refreshing credentials in the background, retrying in %s: %s", retryInterval, err)
+		}
+		refreshed = true
+
+		r.mu.Lock()
+		r.health.LastAttempt = attempt
+		r.health.LastError = err
+		if err == nil {
+			r.health.LastRefresh = attempt
+		}
+		r.mu.Unlock()
+		r.setExpiresAt(creds)
+	}
+}
+
+func (r *CredentialsRefresher) setExpiresAt(creds *awsCredentials.Credentials) {
+	expiresAt, _ := CredentialsExpiresAt(creds)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.health.ExpiresAt = expiresAt
+}
@@ -0,0 +1,148 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+func TestCredentialsRefresher(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	var calls int32
+	refresher := &CredentialsRefresher{
+		Lead:          2 * time.Hour,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	config := &Config{
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			atomic.AddInt32(&calls, 1)
+			return awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"}, time.Now().Add(time.Hour), nil
+		},
+		CredentialsRefresher: refresher,
+		SkipMetadataApiCheck: true,
+	}
+
+	creds, err := GetCredentials(config)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) >= 3 })
+
+	health := refresher.Health()
+	if !health.Healthy() {
+		t.Errorf("Expected healthy refresher, got %+v", health)
+	}
+	if health.LastRefresh.IsZero() {
+		t.Error("Expected last refresh time")
+	}
+	if health.ExpiresAt.IsZero() {
+		t.Error("Expected expiry time")
+	}
+
+	refresher.Stop()
+	if refresher.Health().Running {
+		t.Error("Expected refresher to be stopped")
+	}
+
+	stoppedCalls := atomic.LoadInt32(&calls)
+	if _, err := creds.Get(); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&calls); calls != stoppedCalls {
+		t.Errorf("Expected no refreshes after stopping, got %d", calls-stoppedCalls)
+	}
+}
+
+func TestCredentialsRefresher_keepsCurrentCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	var calls int32
+	refresher := &CredentialsRefresher{
+		Lead:          2 * time.Hour,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	defer refresher.Stop()
+	config := &Config{
+		CredentialsProviderFunc: func(ctx context.Context) (awsCredentials.Value, time.Time, error) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				return awsCredentials.Value{}, time.Time{}, errors.New("test error")
+			}
+			return awsCredentials.Value{AccessKeyID: "accessKey", SecretAccessKey: "secretKey"}, time.Now().Add(time.Hour), nil
+		},
+		CredentialsRefresher: refresher,
+		SkipMetadataApiCheck: true,
+	}
+
+	creds, err := GetCredentials(config)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	waitFor(t, func() bool { return refresher.Health().LastError != nil })
+
+	health := refresher.Health()
+	if health.Healthy() {
+		t.Errorf("Expected unhealthy refresher, got %+v", health)
+	}
+	if health.LastRefresh.IsZero() || !health.LastAttempt.After(health.LastRefresh) {
+		t.Errorf("Expected failed attempt after last refresh, got %+v", health)
+	}
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if value.AccessKeyID != "accessKey" {
+		t.Errorf("Expected current credentials, got access key %q", value.AccessKeyID)
+	}
+}
+
+func TestCredentialsRefresher_prefetch(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	refresher := &CredentialsRefresher{}
+	defer refresher.Stop()
+	config := &Config{
+		AccessKey:            "accessKey",
+		SecretKey:            "secretKey",
+		CredentialsRefresher: refresher,
+		SkipMetadataApiCheck: true,
+	}
+
+	if _, err := GetCredentials(config); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	waitFor(t, func() bool { return !refresher.Health().LastRefresh.IsZero() })
+
+	health := refresher.Health()
+	if !health.Healthy() {
+		t.Errorf("Expected healthy refresher, got %+v", health)
+	}
+	if !health.ExpiresAt.IsZero() {
+		t.Errorf("Expected no expiry time, got %s", health.ExpiresAt)
+	}
+}
+
+// waitFor fails the test if the condition doesn't become true within a few
+// seconds.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
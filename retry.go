@@ -0,0 +1,221 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// identityLookupMaxAttempts bounds the attempts of throttled identity lookups.
+	identityLookupMaxAttempts = 5
+	// identityLookupMaxBackoff caps the delay between identity lookup attempts.
+	identityLookupMaxBackoff = 5 * time.Second
+)
+
+// identityLookupBaseBackoff is the delay before the first retry of a
+// throttled identity lookup, doubled for each subsequent retry.
+var identityLookupBaseBackoff = 200 * time.Millisecond
+
+const (
+	// assumeRoleMaxAttempts bounds the attempts of sts:AssumeRole calls
+	// failing with transient errors.
+	assumeRoleMaxAttempts = 5
+	// assumeRoleMaxBackoff caps the delay between sts:AssumeRole attempts.
+	assumeRoleMaxBackoff = 10 * time.Second
+)
+
+// assumeRoleBaseBackoff is the delay before the first retry of an
+// sts:AssumeRole call, doubled for each subsequent retry.
+var assumeRoleBaseBackoff = 500 * time.Millisecond
+
+// retryOnThrottle calls fn, a request of the client, until it succeeds, fails
+// with an error other than throttling, the context is done, or
+// identityLookupMaxAttempts is reached, waiting with exponential backoff and
+// full jitter between attempts. It is only called once if the client retries
+// failed requests itself.
+func retryOnThrottle(ctx context.Context, clock Clock, client interface{}, operation string, fn func() error) error {
+	maxAttempts := identityLookupMaxAttempts
+	if clientRetries(client) {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := identityLookupBaseBackoff << uint(attempt-1)
+			if backoff > identityLookupMaxBackoff {
+				backoff = identityLookupMaxBackoff
+			}
+			delay := time.Duration(rand.Int63n(int64(backoff) + 1))
+			logger.Printf("[DEBUG] %s throttled, retrying in %s", operation, delay)
+			if !sleep(ctx, clock, delay) {
+				return err
+			}
+		}
+
+		err = fn()
+		if !IsThrottleError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryOnTransientError calls fn, a request of the client, until it succeeds,
+// fails with an error which isn't transient, the context is done, or
+// assumeRoleMaxAttempts is reached, waiting with exponential backoff and full
+// jitter between attempts. It is only called once if the client retries
+// failed requests itself.
+func retryOnTransientError(ctx context.Context, clock Clock, client interface{}, operation string, fn func() error) error {
+	maxAttempts := assumeRoleMaxAttempts
+	if clientRetries(client) {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := assumeRoleBaseBackoff << uint(attempt-1)
+			if backoff > assumeRoleMaxBackoff {
+				backoff = assumeRoleMaxBackoff
+			}
+			delay := time.Duration(rand.Int63n(int64(backoff) + 1))
+			logger.Printf("[DEBUG] %s failed with transient error, retrying in %s (attempt %d of %d): %s", operation, delay, attempt+1, maxAttempts, err)
+			if !sleep(ctx, clock, delay) {
+				return err
+			}
+		}
+
+		err = fn()
+		if !IsTransientError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// clientRetries returns whether the AWS Go SDK client retries failed requests
+// itself, e.g. with a MaxRetries greater than 0, in which case retrying them
+// again would multiply the attempts. Clients which don't report their
+// retries, e.g. mocks, are assumed not to.
+func clientRetries(client interface{}) bool {
+	retryer, ok := client.(interface{ MaxRetries() int })
+	return ok && retryer.MaxRetries() > 0
+}
+
+// sleep waits for the delay, with a timer of the Clock, returning false if the
+// context is done first.
+func sleep(ctx context.Context, clock Clock, delay time.Duration) bool {
+	timer, stop := newTimer(clock, delay)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer:
+		return true
+	}
+}
+
+// addServiceMaxRetriesHandlers overrides the maximum number of retries of
+// requests to the services in the ServiceMaxRetries of the Config, keeping the
+// other settings of the client retryer if it is the default retryer.
+func addServiceMaxRetriesHandlers(c *Config, handlers *request.Handlers) {
+	if len(c.ServiceMaxRetries) == 0 {
+		return
+	}
+	serviceMaxRetries := c.ServiceMaxRetries
+
+	handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.ServiceMaxRetries",
+		Fn: func(r *request.Request) {
+			maxRetries, ok := serviceMaxRetries[r.ClientInfo.ServiceName]
+			if !ok {
+				return
+			}
+
+			if retryer, ok := r.Retryer.(client.DefaultRetryer); ok {
+				retryer.NumMaxRetries = maxRetries
+				r.Retryer = retryer
+				return
+			}
+			r.Retryer = client.DefaultRetryer{NumMaxRetries: maxRetries}
+		},
+	})
+}
+
+// IsThrottleError returns whether the error, or an error it wraps, is an AWS
+// API error due to throttling, as retried by this package when looking up
+// account information.
+func IsThrottleError(err error) bool {
+	return anyCause(err, request.IsErrorThrottle)
+}
+
+// IsTransientError returns whether the error, or an error it wraps, is likely
+// to succeed when retried: an AWS API error due to throttling, a 5xx
+// response, or a network error. It is used by this package to retry
+// sts:AssumeRole calls.
+func IsTransientError(err error) bool {
+	return IsThrottleError(err) || IsNetworkError(err) || anyCause(err, func(err error) bool {
+		requestFailure, ok := err.(awserr.RequestFailure)
+		return ok && requestFailure.StatusCode() >= http.StatusInternalServerError
+	})
+}
+
+// IsExpiredCredentialsError returns whether the error, or an error it wraps,
+// is an AWS API error due to expired credentials, e.g. ExpiredToken, after
+// which credentials should be refreshed before retrying.
+func IsExpiredCredentialsError(err error) bool {
+	return anyCause(err, request.IsErrorExpiredCreds)
+}
+
+// IsNetworkError returns whether the error, or an error it wraps, is a
+// network error, e.g. a DNS lookup failure, a refused connection, or a
+// timeout.
+func IsNetworkError(err error) bool {
+	return anyCause(err, func(err error) bool {
+		var netErr net.Error
+		return errors.As(err, &netErr)
+	})
+}
+
+// anyCause returns whether fn returns true for the error or any error it was
+// caused by, following both standard wrapping and the original errors of AWS
+// Go SDK errors, which don't implement Unwrap.
+func anyCause(err error, fn func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if fn(err) {
+		return true
+	}
+
+	switch err := err.(type) {
+	case awserr.BatchedErrors:
+		for _, origErr := range err.OrigErrs() {
+			if anyCause(origErr, fn) {
+				return true
+			}
+		}
+		return false
+	case awserr.Error:
+		return anyCause(err.OrigErr(), fn)
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range err.Unwrap() {
+			if anyCause(wrapped, fn) {
+				return true
+			}
+		}
+		return false
+	default:
+		return anyCause(errors.Unwrap(err), fn)
+	}
+}
@@ -0,0 +1,278 @@
+package awsbase
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+func TestAddServiceMaxRetriesHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		ServiceMaxRetries map[string]int
+		ExpectedRequests  int32
+	}{
+		{
+			Description:      "session max retries",
+			ExpectedRequests: 2,
+		},
+		{
+			Description:       "other service",
+			ServiceMaxRetries: map[string]int{"s3": 5},
+			ExpectedRequests:  2,
+		},
+		{
+			Description:       "more retries",
+			ServiceMaxRetries: map[string]int{"sts": 3},
+			ExpectedRequests:  4,
+		},
+		{
+			Description:       "no retries",
+			ServiceMaxRetries: map[string]int{"sts": 0},
+			ExpectedRequests:  1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.Header().Set("Content-Type", "text/xml")
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, stsResponse_GetCallerIdentity_throttled)
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Endpoint:    aws.String(ts.URL),
+				MaxRetries:  aws.Int(1),
+				Region:      aws.String("us-east-1"),
+				SleepDelay:  func(time.Duration) {},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			addServiceMaxRetriesHandlers(&Config{ServiceMaxRetries: testCase.ServiceMaxRetries}, &sess.Handlers)
+
+			_, _ = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+
+			if requests != testCase.ExpectedRequests {
+				t.Errorf("Expected %d request(s), got %d", testCase.ExpectedRequests, requests)
+			}
+		})
+	}
+}
+
+func TestRetryabilityPredicates(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host", Name: "sts.amazonaws.com", IsNotFound: true}
+
+	var testCases = []struct {
+		Description          string
+		Err                  error
+		ExpectedThrottle     bool
+		ExpectedExpiredCreds bool
+		ExpectedNetwork      bool
+		ExpectedTransient    bool
+	}{
+		{
+			Description: "nil error",
+		},
+		{
+			Description: "other error",
+			Err:         errors.New("test"),
+		},
+		{
+			Description:       "throttling",
+			Err:               awserr.NewRequestFailure(awserr.New("Throttling", "Rate exceeded", nil), http.StatusBadRequest, "1234"),
+			ExpectedThrottle:  true,
+			ExpectedTransient: true,
+		},
+		{
+			Description:       "wrapped throttling",
+			Err:               wrapRequestError(awserr.New("ThrottlingException", "Rate exceeded", nil), "failed"),
+			ExpectedThrottle:  true,
+			ExpectedTransient: true,
+		},
+		{
+			Description:       "server error",
+			Err:               awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service unavailable", nil), http.StatusServiceUnavailable, "1234"),
+			ExpectedTransient: true,
+		},
+		{
+			Description: "access denied",
+			Err:         awserr.NewRequestFailure(awserr.New("AccessDenied", "Not authorized", nil), http.StatusForbidden, "1234"),
+		},
+		{
+			Description:          "expired token",
+			Err:                  fmt.Errorf("failed: %w", awserr.New("ExpiredToken", "The security token included in the request is expired", nil)),
+			ExpectedExpiredCreds: true,
+		},
+		{
+			Description:       "network error caused by AWS error",
+			Err:               awserr.New("RequestError", "send request failed", dnsErr),
+			ExpectedNetwork:   true,
+			ExpectedTransient: true,
+		},
+		{
+			Description:       "batched network error",
+			Err:               awserr.NewBatchError("NoCredentialProviders", "no valid providers in chain", []error{errors.New("test"), dnsErr}),
+			ExpectedNetwork:   true,
+			ExpectedTransient: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			if got := IsThrottleError(testCase.Err); got != testCase.ExpectedThrottle {
+				t.Errorf("Expected IsThrottleError %t, got %t", testCase.ExpectedThrottle, got)
+			}
+			if got := IsExpiredCredentialsError(testCase.Err); got != testCase.ExpectedExpiredCreds {
+				t.Errorf("Expected IsExpiredCredentialsError %t, got %t", testCase.ExpectedExpiredCreds, got)
+			}
+			if got := IsNetworkError(testCase.Err); got != testCase.ExpectedNetwork {
+				t.Errorf("Expected IsNetworkError %t, got %t", testCase.ExpectedNetwork, got)
+			}
+			if got := IsTransientError(testCase.Err); got != testCase.ExpectedTransient {
+				t.Errorf("Expected IsTransientError %t, got %t", testCase.ExpectedTransient, got)
+			}
+		})
+	}
+}
+
+const stsResponse_AssumeRole_unavailable = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Receiver</Type>
+    <Code>ServiceUnavailable</Code>
+    <Message>Service unavailable</Message>
+  </Error>
+  <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+</ErrorResponse>`
+
+const stsResponse_AssumeRole_accessDenied = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>Not authorized to perform sts:AssumeRole</Message>
+  </Error>
+  <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+</ErrorResponse>`
+
+func TestAssumeRoleRetryProvider(t *testing.T) {
+	defer func(backoff time.Duration) { assumeRoleBaseBackoff = backoff }(assumeRoleBaseBackoff)
+	assumeRoleBaseBackoff = time.Millisecond
+
+	var testCases = []struct {
+		Description      string
+		FailedRequests   int32
+		FailureStatus    int
+		FailureBody      string
+		MaxRetries       int
+		ExpectedRequests int32
+		ExpectedError    bool
+	}{
+		{
+			Description:      "service unavailable",
+			FailedRequests:   2,
+			FailureStatus:    http.StatusServiceUnavailable,
+			FailureBody:      stsResponse_AssumeRole_unavailable,
+			ExpectedRequests: 3,
+		},
+		{
+			Description:      "throttled",
+			FailedRequests:   1,
+			FailureStatus:    http.StatusBadRequest,
+			FailureBody:      stsResponse_GetCallerIdentity_throttled,
+			ExpectedRequests: 2,
+		},
+		{
+			Description:      "bounded",
+			FailedRequests:   assumeRoleMaxAttempts + 1,
+			FailureStatus:    http.StatusServiceUnavailable,
+			FailureBody:      stsResponse_AssumeRole_unavailable,
+			ExpectedRequests: assumeRoleMaxAttempts,
+			ExpectedError:    true,
+		},
+		{
+			Description:      "client retries",
+			FailedRequests:   assumeRoleMaxAttempts + 1,
+			FailureStatus:    http.StatusServiceUnavailable,
+			FailureBody:      stsResponse_AssumeRole_unavailable,
+			MaxRetries:       1,
+			ExpectedRequests: 2,
+			ExpectedError:    true,
+		},
+		{
+			Description:      "access denied",
+			FailedRequests:   1,
+			FailureStatus:    http.StatusForbidden,
+			FailureBody:      stsResponse_AssumeRole_accessDenied,
+			ExpectedRequests: 1,
+			ExpectedError:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/xml")
+				if atomic.AddInt32(&requests, 1) <= testCase.FailedRequests {
+					w.WriteHeader(testCase.FailureStatus)
+					fmt.Fprint(w, testCase.FailureBody)
+					return
+				}
+				fmt.Fprint(w, awsmocks.MockStsAssumeRoleValidResponseBody)
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Endpoint:    aws.String(ts.URL),
+				MaxRetries:  aws.Int(testCase.MaxRetries),
+				Region:      aws.String("us-east-1"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			provider := &assumeRoleRetryProvider{newAssumeRoleProvider(sts.New(sess), &AssumeRole{
+				RoleARN:     awsmocks.MockStsAssumeRoleArn,
+				SessionName: awsmocks.MockStsAssumeRoleSessionName,
+			}, nil), nil}
+
+			value, err := provider.Retrieve()
+			if testCase.ExpectedError {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			} else if value.AccessKeyID != awsmocks.MockStsAssumeRoleAccessKey {
+				t.Errorf("Expected access key %q, got %q", awsmocks.MockStsAssumeRoleAccessKey, value.AccessKeyID)
+			}
+
+			if requests != testCase.ExpectedRequests {
+				t.Errorf("Expected %d request(s), got %d", testCase.ExpectedRequests, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package awsbase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// DefaultRetryQuotaCapacity is the capacity of the retry quota of the AWS
+	// SDKs' standard retry mode.
+	DefaultRetryQuotaCapacity = 500
+
+	retryQuotaRetryCost        = 5
+	retryQuotaTimeoutCost      = 10
+	retryQuotaNoRetryIncrement = 1
+)
+
+var errRetryQuotaExceeded = errors.New("retry quota exceeded")
+
+// RetryQuota is a token bucket capping the retries of all clients built with
+// it, so that retry storms, e.g. during regional incidents, are limited. Each
+// retry costs tokens, retries of timeouts more so, and successful requests
+// return tokens. Once the quota is exhausted, failed requests are not retried.
+//
+// Set Config.RetryQuota to share a quota between sessions. RetryQuota
+// implements the RateLimiter interface of the AWS Go SDK v2 retry package, so
+// the same quota applies to configurations built by the awsv2 package.
+type RetryQuota struct {
+	mu        sync.Mutex
+	capacity  uint
+	available uint
+}
+
+// NewRetryQuota returns a full retry quota with the given capacity.
+func NewRetryQuota(capacity uint) *RetryQuota {
+	return &RetryQuota{
+		capacity:  capacity,
+		available: capacity,
+	}
+}
+
+// Available returns the number of tokens remaining in the quota.
+func (q *RetryQuota) Available() uint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.available
+}
+
+// GetToken takes the cost of a retry from the quota, returning a function to
+// return the tokens once the retry succeeds, or an error if the quota is
+// exhausted.
+func (q *RetryQuota) GetToken(ctx context.Context, cost uint) (func() error, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.available < cost {
+		return nil, errRetryQuotaExceeded
+	}
+	q.available -= cost
+
+	return func() error {
+		return q.AddTokens(cost)
+	}, nil
+}
+
+// AddTokens returns tokens to the quota, up to its capacity.
+func (q *RetryQuota) AddTokens(tokens uint) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.available += tokens
+	if q.available > q.capacity {
+		q.available = q.capacity
+	}
+	return nil
+}
+
+// addRetryQuotaHandlers takes the cost of each retry from the RetryQuota of
+// the Config, cancelling the retry if the quota is exhausted, and returns
+// tokens to the quota when requests succeed.
+func addRetryQuotaHandlers(c *Config, handlers *request.Handlers) {
+	if c.RetryQuota == nil {
+		return
+	}
+	quota := c.RetryQuota
+
+	// The release functions of the tokens taken by requests being retried.
+	var releases sync.Map
+
+	// The error is cleared by the core handler once a retry is decided, so the
+	// cost is taken here, before any other handler observes the retry.
+	handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.RetryQuota",
+		Fn: func(r *request.Request) {
+			if r.Retryable == nil {
+				r.Retryable = aws.Bool(r.ShouldRetry(r))
+			}
+			if !r.WillRetry() {
+				return
+			}
+
+			cost := uint(retryQuotaRetryCost)
+			if isTimeoutError(r.Error) {
+				cost = retryQuotaTimeoutCost
+			}
+
+			release, err := quota.GetToken(r.Context(), cost)
+			if err != nil {
+				logger.Printf("[WARN] Retry quota exhausted, not retrying %s.%s: %s", r.ClientInfo.ServiceName, r.Operation.Name, r.Error)
+				r.Retryable = aws.Bool(false)
+				return
+			}
+			releases.Store(r, release)
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.RetryQuotaRelease",
+		Fn: func(r *request.Request) {
+			release, retried := releases.LoadAndDelete(r)
+			if r.Error != nil {
+				return
+			}
+			if retried {
+				release.(func() error)()
+			} else {
+				quota.AddTokens(retryQuotaNoRetryIncrement)
+			}
+		},
+	})
+}
+
+// isTimeoutError returns whether the error, or an error it was caused by, is
+// a network timeout.
+func isTimeoutError(err error) bool {
+	return anyCause(err, func(err error) bool {
+		netErr, ok := err.(net.Error)
+		return ok && netErr.Timeout()
+	})
+}
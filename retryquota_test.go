@@ -0,0 +1,116 @@
+package awsbase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestRetryQuota(t *testing.T) {
+	quota := NewRetryQuota(10)
+
+	release, err := quota.GetToken(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if _, err := quota.GetToken(context.Background(), 10); err == nil {
+		t.Fatal("Expected error taking more tokens than available, received none")
+	}
+	if quota.Available() != 5 {
+		t.Errorf("Expected 5 tokens available, got %d", quota.Available())
+	}
+
+	if err := release(); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if err := quota.AddTokens(1); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if quota.Available() != 10 {
+		t.Errorf("Expected tokens capped at capacity 10, got %d", quota.Available())
+	}
+}
+
+func TestAddRetryQuotaHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		ThrottledRequests int32
+		Calls             int
+		ExpectedRequests  int32
+		ExpectedAvailable uint
+	}{
+		{
+			Description:       "success",
+			Calls:             1,
+			ExpectedRequests:  1,
+			ExpectedAvailable: retryQuotaRetryCost,
+		},
+		{
+			Description:       "success after retry",
+			ThrottledRequests: 1,
+			Calls:             1,
+			ExpectedRequests:  2,
+			ExpectedAvailable: retryQuotaRetryCost,
+		},
+		{
+			Description:       "quota exhausted",
+			ThrottledRequests: 10,
+			Calls:             2,
+			ExpectedRequests:  3,
+			ExpectedAvailable: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/xml")
+				if atomic.AddInt32(&requests, 1) <= testCase.ThrottledRequests {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, stsResponse_GetCallerIdentity_throttled)
+					return
+				}
+				fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Endpoint:    aws.String(ts.URL),
+				MaxRetries:  aws.Int(1),
+				Region:      aws.String("us-east-1"),
+				SleepDelay:  func(time.Duration) {},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The quota allows a single retry.
+			quota := NewRetryQuota(retryQuotaRetryCost)
+			addRetryQuotaHandlers(&Config{RetryQuota: quota}, &sess.Handlers)
+
+			for i := 0; i < testCase.Calls; i++ {
+				_, _ = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+			}
+
+			if requests != testCase.ExpectedRequests {
+				t.Errorf("Expected %d request(s), got %d", testCase.ExpectedRequests, requests)
+			}
+			if quota.Available() != testCase.ExpectedAvailable {
+				t.Errorf("Expected %d token(s) available, got %d", testCase.ExpectedAvailable, quota.Available())
+			}
+		})
+	}
+}
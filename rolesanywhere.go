@@ -0,0 +1,340 @@
+package awsbase
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// RolesAnywhereProviderName is the name of the provider of credentials
+// obtained with IAM Roles Anywhere.
+const RolesAnywhereProviderName = "RolesAnywhereProvider"
+
+// rolesAnywhereExpiryWindow is how long before their expiration the
+// credentials obtained with IAM Roles Anywhere are refreshed.
+const rolesAnywhereExpiryWindow = 1 * time.Minute
+
+// RolesAnywhere contains the settings for obtaining the credentials of a role
+// with IAM Roles Anywhere, by signing rolesanywhere:CreateSession requests
+// with an X.509 certificate issued by the certificate authority of the trust
+// anchor, e.g. on hosts outside of AWS without IAM users.
+//
+// The certificate file may contain intermediate certificates following the
+// certificate, which are sent along with it. The certificate and private key
+// are read for each session, so that they can be rotated. RSA and ECDSA keys
+// are supported.
+//
+// The region of the trust anchor is used, unless Endpoint is set.
+type RolesAnywhere struct {
+	CertificateFilename string        `json:"certificate_file,omitempty" hcl:"certificate_file,optional"`
+	Duration            time.Duration `json:"duration,omitempty" hcl:"duration,optional"`
+	Endpoint            string        `json:"endpoint,omitempty" hcl:"endpoint,optional"`
+	PrivateKeyFilename  string        `json:"private_key_file,omitempty" hcl:"private_key_file,optional"`
+	ProfileARN          string        `json:"profile_arn,omitempty" hcl:"profile_arn,optional"`
+	RoleARN             string        `json:"role_arn,omitempty" hcl:"role_arn,optional"`
+	SessionName         string        `json:"session_name,omitempty" hcl:"session_name,optional"`
+	TrustAnchorARN      string        `json:"trust_anchor_arn,omitempty" hcl:"trust_anchor_arn,optional"`
+}
+
+var rolesAnywhereSessionNameRegexp = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// Validate checks the RolesAnywhere settings against the constraints of the
+// CreateSession API, so that misconfiguration is reported before any API
+// calls are made.
+func (r *RolesAnywhere) Validate() error {
+	var errs []error
+
+	if r.CertificateFilename == "" || r.PrivateKeyFilename == "" {
+		errs = append(errs, errors.New("both certificate and private key files must be set"))
+	}
+
+	for _, a := range []struct {
+		name  string
+		value string
+	}{
+		{"trust anchor", r.TrustAnchorARN},
+		{"profile", r.ProfileARN},
+		{"role", r.RoleARN},
+	} {
+		if a.value == "" {
+			errs = append(errs, fmt.Errorf("%s ARN must be set", a.name))
+		} else if _, err := arn.Parse(a.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s ARN %q: %w", a.name, a.value, err))
+		}
+	}
+
+	if r.Duration != 0 && (r.Duration < 15*time.Minute || r.Duration > 12*time.Hour) {
+		errs = append(errs, fmt.Errorf("duration must be between 15m and 12h, got %s", r.Duration))
+	}
+
+	if r.SessionName != "" && !rolesAnywhereSessionNameRegexp.MatchString(r.SessionName) {
+		errs = append(errs, fmt.Errorf("invalid session name %q", r.SessionName))
+	}
+
+	return errors.Join(errs...)
+}
+
+// endpoint returns the URL of the IAM Roles Anywhere endpoint of the region
+// of the trust anchor.
+func (r *RolesAnywhere) endpoint() (string, string, error) {
+	trustAnchorARN, err := arn.Parse(r.TrustAnchorARN)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid trust anchor ARN %q: %w", r.TrustAnchorARN, err)
+	}
+	region := trustAnchorARN.Region
+
+	if r.Endpoint != "" {
+		return r.Endpoint, region, nil
+	}
+
+	endpoint, err := endpoints.DefaultResolver().EndpointFor("rolesanywhere", region)
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving IAM Roles Anywhere endpoint of region %q: %w", region, err)
+	}
+	return endpoint.URL, region, nil
+}
+
+// rolesAnywhereProvider retrieves credentials with IAM Roles Anywhere.
+type rolesAnywhereProvider struct {
+	settings *RolesAnywhere
+	client   *http.Client
+	clock    Clock
+
+	mu         sync.Mutex
+	expiration time.Time
+	retrieved  bool
+}
+
+// rolesAnywhereSessionInput is the request body of CreateSession.
+type rolesAnywhereSessionInput struct {
+	DurationSeconds int64  `json:"durationSeconds,omitempty"`
+	ProfileARN      string `json:"profileArn"`
+	RoleARN         string `json:"roleArn"`
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	TrustAnchorARN  string `json:"trustAnchorArn"`
+}
+
+// rolesAnywhereSessionOutput is the response body of CreateSession.
+type rolesAnywhereSessionOutput struct {
+	CredentialSet []struct {
+		Credentials struct {
+			AccessKeyID     string    `json:"accessKeyId"`
+			Expiration      time.Time `json:"expiration"`
+			SecretAccessKey string    `json:"secretAccessKey"`
+			SessionToken    string    `json:"sessionToken"`
+		} `json:"credentials"`
+	} `json:"credentialSet"`
+}
+
+func (p *rolesAnywhereProvider) Retrieve() (awsCredentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+func (p *rolesAnywhereProvider) RetrieveWithContext(ctx awsCredentials.Context) (awsCredentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	output, err := p.createSession(ctx)
+	if err != nil {
+		return awsCredentials.Value{ProviderName: RolesAnywhereProviderName}, fmt.Errorf("error creating IAM Roles Anywhere session of role %s: %w", p.settings.RoleARN, err)
+	}
+	if len(output.CredentialSet) == 0 {
+		return awsCredentials.Value{ProviderName: RolesAnywhereProviderName}, fmt.Errorf("IAM Roles Anywhere session of role %s has no credentials", p.settings.RoleARN)
+	}
+	credentials := output.CredentialSet[0].Credentials
+
+	p.expiration = credentials.Expiration.Add(-rolesAnywhereExpiryWindow)
+	p.retrieved = true
+
+	return awsCredentials.Value{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+		ProviderName:    RolesAnywhereProviderName,
+	}, nil
+}
+
+// IsExpired returns true if the credentials have not been retrieved or are
+// about to expire.
+func (p *rolesAnywhereProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !p.retrieved || resolveClock(p.clock).Now().After(p.expiration)
+}
+
+// ExpiresAt returns the expiration of the credentials.
+func (p *rolesAnywhereProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expiration
+}
+
+// createSession calls CreateSession, signed with the certificate and private
+// key.
+func (p *rolesAnywhereProvider) createSession(ctx context.Context) (*rolesAnywhereSessionOutput, error) {
+	keyPair, err := tls.LoadX509KeyPair(p.settings.CertificateFilename, p.settings.PrivateKeyFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %w", err)
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	endpoint, region, err := p.settings.endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(rolesAnywhereSessionInput{
+		DurationSeconds: int64(p.settings.Duration / time.Second),
+		ProfileARN:      p.settings.ProfileARN,
+		RoleARN:         p.settings.RoleARN,
+		RoleSessionName: p.settings.SessionName,
+		TrustAnchorARN:  p.settings.TrustAnchorARN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/sessions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := signRolesAnywhereRequest(req, body, certificate, keyPair.Certificate[1:], signer, region, resolveClock(p.clock).Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &errResp)
+		return nil, fmt.Errorf("%s: status code: %d, %s", resp.Header.Get("X-Amzn-Errortype"), resp.StatusCode, errResp.Message)
+	}
+
+	var output rolesAnywhereSessionOutput
+	if err := json.Unmarshal(respBody, &output); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	return &output, nil
+}
+
+// signRolesAnywhereRequest signs the request with the Signature Version 4
+// variant of IAM Roles Anywhere, using the private key of the certificate in
+// place of a secret access key.
+func signRolesAnywhereRequest(req *http.Request, body []byte, certificate *x509.Certificate, chain [][]byte, signer crypto.Signer, region string, now time.Time) error {
+	var algorithm string
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		algorithm = "AWS4-X509-RSA-SHA256"
+	case *ecdsa.PublicKey:
+		algorithm = "AWS4-X509-ECDSA-SHA256"
+	default:
+		return fmt.Errorf("unsupported private key type %T", signer.Public())
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/rolesanywhere/aws4_request", amzDate[:8], region)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-X509", base64.StdEncoding.EncodeToString(certificate.Raw))
+	signedHeaders := []string{"content-type", "host", "x-amz-date", "x-amz-x509"}
+	if len(chain) > 0 {
+		encoded := make([]string, len(chain))
+		for i, der := range chain {
+			encoded[i] = base64.StdEncoding.EncodeToString(der)
+		}
+		req.Header.Set("X-Amz-X509-Chain", strings.Join(encoded, ","))
+		signedHeaders = append(signedHeaders, "x-amz-x509-chain")
+	}
+
+	// Both RSA (PKCS #1 v1.5) and ECDSA (ASN.1) signatures are made over the
+	// SHA-256 digest of the string to sign.
+	digest := sha256.Sum256([]byte(rolesAnywhereStringToSign(req, body, signedHeaders, algorithm, amzDate, scope)))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("error signing request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, certificate.SerialNumber.String(), scope, strings.Join(signedHeaders, ";"), hex.EncodeToString(signature)))
+	return nil
+}
+
+// rolesAnywhereStringToSign returns the string to sign of the request, with
+// the given signed headers in lower case and sorted.
+func rolesAnywhereStringToSign(req *http.Request, body []byte, signedHeaders []string, algorithm, amzDate, scope string) string {
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+}
@@ -0,0 +1,294 @@
+package awsbase
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	rolesAnywhereTestTrustAnchorARN = "arn:aws:rolesanywhere:us-west-2:111111111111:trust-anchor/01234567-89ab-cdef-0123-456789abcdef"
+	rolesAnywhereTestProfileARN     = "arn:aws:rolesanywhere:us-west-2:111111111111:profile/01234567-89ab-cdef-0123-456789abcdef"
+	rolesAnywhereTestRoleARN        = "arn:aws:iam::111111111111:role/OnPremises"
+)
+
+var rolesAnywhereAuthorizationRegexp = regexp.MustCompile(`^(AWS4-X509-(?:RSA|ECDSA)-SHA256) Credential=(\d+)/(\d{8}/us-west-2/rolesanywhere/aws4_request), SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]+)$`)
+
+// writeRolesAnywhereCertificate writes a self-signed certificate with the
+// serial number and its private key in PEM files, returning their names.
+func writeRolesAnywhereCertificate(t *testing.T, key crypto.Signer, serialNumber int64) (string, string) {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "on-premises"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFilename := filepath.Join(dir, "certificate.pem")
+	keyFilename := filepath.Join(dir, "private-key.pem")
+	if err := os.WriteFile(certFilename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFilename, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFilename, keyFilename
+}
+
+func TestRolesAnywhereValidate(t *testing.T) {
+	var testCases = []struct {
+		Description    string
+		RolesAnywhere  RolesAnywhere
+		ExpectedErrors []string
+	}{
+		{
+			Description: "valid",
+			RolesAnywhere: RolesAnywhere{
+				CertificateFilename: "certificate.pem",
+				PrivateKeyFilename:  "private-key.pem",
+				ProfileARN:          rolesAnywhereTestProfileARN,
+				RoleARN:             rolesAnywhereTestRoleARN,
+				TrustAnchorARN:      rolesAnywhereTestTrustAnchorARN,
+			},
+		},
+		{
+			Description:   "missing",
+			RolesAnywhere: RolesAnywhere{},
+			ExpectedErrors: []string{
+				"both certificate and private key files must be set",
+				"trust anchor ARN must be set",
+				"profile ARN must be set",
+				"role ARN must be set",
+			},
+		},
+		{
+			Description: "invalid",
+			RolesAnywhere: RolesAnywhere{
+				CertificateFilename: "certificate.pem",
+				Duration:            13 * time.Hour,
+				PrivateKeyFilename:  "private-key.pem",
+				ProfileARN:          "profile",
+				RoleARN:             rolesAnywhereTestRoleARN,
+				SessionName:         "on premises",
+				TrustAnchorARN:      rolesAnywhereTestTrustAnchorARN,
+			},
+			ExpectedErrors: []string{
+				`invalid profile ARN "profile"`,
+				"duration must be between 15m and 12h",
+				`invalid session name "on premises"`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			err := testCase.RolesAnywhere.Validate()
+			if len(testCase.ExpectedErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, received error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error, received none")
+			}
+			for _, expected := range testCase.ExpectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error containing %q, got %q", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRolesAnywhereEndpoint(t *testing.T) {
+	r := &RolesAnywhere{TrustAnchorARN: rolesAnywhereTestTrustAnchorARN}
+
+	endpoint, region, err := r.endpoint()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+	if expected := "https://rolesanywhere.us-west-2.amazonaws.com"; endpoint != expected {
+		t.Errorf("Expected endpoint %q, got %q", expected, endpoint)
+	}
+	if region != "us-west-2" {
+		t.Errorf("Expected region %q, got %q", "us-west-2", region)
+	}
+}
+
+func TestGetCredentials_rolesAnywhere(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		Description       string
+		Key               crypto.Signer
+		ExpectedAlgorithm string
+	}{
+		{
+			Description:       "RSA",
+			Key:               rsaKey,
+			ExpectedAlgorithm: "AWS4-X509-RSA-SHA256",
+		},
+		{
+			Description:       "ECDSA",
+			Key:               ecdsaKey,
+			ExpectedAlgorithm: "AWS4-X509-ECDSA-SHA256",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			certFilename, keyFilename := writeRolesAnywhereCertificate(t, testCase.Key, 4242)
+
+			var input rolesAnywhereSessionInput
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.URL.Path != "/sessions" {
+					t.Errorf("Expected request to /sessions, got %s", r.URL.Path)
+				}
+				if err := json.Unmarshal(body, &input); err != nil {
+					t.Errorf("Error parsing request: %s", err)
+				}
+
+				match := rolesAnywhereAuthorizationRegexp.FindStringSubmatch(r.Header.Get("Authorization"))
+				if match == nil {
+					t.Errorf("Unexpected Authorization header %q", r.Header.Get("Authorization"))
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				algorithm, serialNumber, scope, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+				if algorithm != testCase.ExpectedAlgorithm {
+					t.Errorf("Expected algorithm %q, got %q", testCase.ExpectedAlgorithm, algorithm)
+				}
+				if serialNumber != "4242" {
+					t.Errorf("Expected serial number 4242, got %s", serialNumber)
+				}
+
+				digest := sha256.Sum256([]byte(rolesAnywhereStringToSign(r, body, strings.Split(signedHeaders, ";"), algorithm, r.Header.Get("X-Amz-Date"), scope)))
+				sig, _ := hex.DecodeString(signature)
+				var verified bool
+				switch key := testCase.Key.Public().(type) {
+				case *rsa.PublicKey:
+					verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+				case *ecdsa.PublicKey:
+					verified = ecdsa.VerifyASN1(key, digest[:], sig)
+				}
+				if !verified {
+					t.Error("Expected valid signature")
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, rolesAnywhereResponse_CreateSession_valid, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			}))
+			defer ts.Close()
+
+			creds, err := GetCredentials(&Config{
+				RolesAnywhere: &RolesAnywhere{
+					CertificateFilename: certFilename,
+					Duration:            time.Hour,
+					Endpoint:            ts.URL,
+					PrivateKeyFilename:  keyFilename,
+					ProfileARN:          rolesAnywhereTestProfileARN,
+					RoleARN:             rolesAnywhereTestRoleARN,
+					TrustAnchorARN:      rolesAnywhereTestTrustAnchorARN,
+				},
+				SkipMetadataApiCheck: true,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if value.AccessKeyID != "RolesAnywhereAccessKey" {
+				t.Errorf("Expected access key %q, got %q", "RolesAnywhereAccessKey", value.AccessKeyID)
+			}
+			if value.ProviderName != RolesAnywhereProviderName {
+				t.Errorf("Expected provider name %q, got %q", RolesAnywhereProviderName, value.ProviderName)
+			}
+			if expiresAt, _ := CredentialsExpiresAt(creds); expiresAt.IsZero() {
+				t.Error("Expected credentials to expire")
+			}
+
+			expectedInput := rolesAnywhereSessionInput{
+				DurationSeconds: 3600,
+				ProfileARN:      rolesAnywhereTestProfileARN,
+				RoleARN:         rolesAnywhereTestRoleARN,
+				TrustAnchorARN:  rolesAnywhereTestTrustAnchorARN,
+			}
+			if input != expectedInput {
+				t.Errorf("Expected request %+v, got %+v", expectedInput, input)
+			}
+		})
+	}
+}
+
+const rolesAnywhereResponse_CreateSession_valid = `{
+  "credentialSet": [
+    {
+      "assumedRoleUser": {
+        "arn": "arn:aws:sts::111111111111:assumed-role/OnPremises/4242",
+        "assumedRoleId": "AROAEXAMPLE:4242"
+      },
+      "credentials": {
+        "accessKeyId": "RolesAnywhereAccessKey",
+        "expiration": "%s",
+        "secretAccessKey": "RolesAnywhereSecretKey",
+        "sessionToken": "RolesAnywhereSessionToken"
+      },
+      "packedPolicySize": 0,
+      "roleArn": "arn:aws:iam::111111111111:role/OnPremises",
+      "sourceIdentity": "CN=on-premises"
+    }
+  ],
+  "subjectArn": "arn:aws:rolesanywhere:us-west-2:111111111111:subject/01234567-89ab-cdef-0123-456789abcdef"
+}`
@@ -0,0 +1,257 @@
+package awsbase
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestNewS3Client(t *testing.T) {
+	t.Setenv("TEST_ENDPOINT_HOST", "minio.example.com:9000")
+
+	testCases := []struct {
+		Description  string
+		Config       *Config
+		ExpectedHost string
+		ExpectedPath string
+	}{
+		{
+			Description:  "default",
+			Config:       &Config{},
+			ExpectedHost: "bucket.s3.us-west-2.amazonaws.com",
+			ExpectedPath: "/key",
+		},
+		{
+			Description: "path style",
+			Config: &Config{
+				S3ForcePathStyle: true,
+			},
+			ExpectedHost: "s3.us-west-2.amazonaws.com",
+			ExpectedPath: "/bucket/key",
+		},
+		{
+			Description: "custom endpoint",
+			Config: &Config{
+				S3Endpoint:       "https://minio.example.com:9000",
+				S3ForcePathStyle: true,
+			},
+			ExpectedHost: "minio.example.com:9000",
+			ExpectedPath: "/bucket/key",
+		},
+		{
+			Description: "custom endpoint from environment variable",
+			Config: &Config{
+				S3Endpoint:       "https://${TEST_ENDPOINT_HOST}",
+				S3ForcePathStyle: true,
+			},
+			ExpectedHost: "minio.example.com:9000",
+			ExpectedPath: "/bucket/key",
+		},
+		{
+			Description: "accelerate",
+			Config: &Config{
+				S3UseAccelerate: true,
+			},
+			ExpectedHost: "bucket.s3-accelerate.amazonaws.com",
+			ExpectedPath: "/key",
+		},
+		{
+			Description: "dual-stack",
+			Config: &Config{
+				S3UseDualStack: true,
+			},
+			ExpectedHost: "bucket.s3.dualstack.us-west-2.amazonaws.com",
+			ExpectedPath: "/key",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Region:      aws.String("us-west-2"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := NewS3Client(sess, testCase.Config).GetObjectRequest(&s3.GetObjectInput{
+				Bucket: aws.String("bucket"),
+				Key:    aws.String("key"),
+			})
+			if err := req.Build(); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if req.HTTPRequest.URL.Host != testCase.ExpectedHost {
+				t.Errorf("Expected host %q, got %q", testCase.ExpectedHost, req.HTTPRequest.URL.Host)
+			}
+			if req.HTTPRequest.URL.Path != testCase.ExpectedPath {
+				t.Errorf("Expected path %q, got %q", testCase.ExpectedPath, req.HTTPRequest.URL.Path)
+			}
+		})
+	}
+}
+
+func TestPresignS3Object(t *testing.T) {
+	t.Setenv("TEST_ENDPOINT_HOST", "minio.example.com:9000")
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+		Region:      aws.String("us-west-2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Description  string
+		Config       *Config
+		Presign      func(*session.Session, *Config, string, string, time.Duration) (string, error)
+		ExpectedHost string
+		ExpectedPath string
+	}{
+		{
+			Description:  "get",
+			Config:       &Config{},
+			Presign:      PresignS3GetObject,
+			ExpectedHost: "bucket.s3.us-west-2.amazonaws.com",
+			ExpectedPath: "/key",
+		},
+		{
+			Description: "put with custom endpoint",
+			Config: &Config{
+				S3Endpoint:       "https://minio.example.com:9000",
+				S3ForcePathStyle: true,
+			},
+			Presign:      PresignS3PutObject,
+			ExpectedHost: "minio.example.com:9000",
+			ExpectedPath: "/bucket/key",
+		},
+		{
+			Description: "get with custom endpoint from environment variable",
+			Config: &Config{
+				S3Endpoint:       "https://${TEST_ENDPOINT_HOST}",
+				S3ForcePathStyle: true,
+			},
+			Presign:      PresignS3GetObject,
+			ExpectedHost: "minio.example.com:9000",
+			ExpectedPath: "/bucket/key",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			presignedURL, err := testCase.Presign(sess, testCase.Config, "bucket", "key", time.Hour)
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			u, err := url.Parse(presignedURL)
+			if err != nil {
+				t.Fatalf("Error parsing presigned URL %q: %s", presignedURL, err)
+			}
+
+			if u.Host != testCase.ExpectedHost {
+				t.Errorf("Expected host %q, got %q", testCase.ExpectedHost, u.Host)
+			}
+			if u.Path != testCase.ExpectedPath {
+				t.Errorf("Expected path %q, got %q", testCase.ExpectedPath, u.Path)
+			}
+			if expires := u.Query().Get("X-Amz-Expires"); expires != "3600" {
+				t.Errorf("Expected X-Amz-Expires %q, got %q", "3600", expires)
+			}
+		})
+	}
+
+	t.Run("unset environment variable", func(t *testing.T) {
+		os.Unsetenv("TEST_UNSET_ENDPOINT")
+
+		if _, err := PresignS3GetObject(sess, &Config{S3Endpoint: "${TEST_UNSET_ENDPOINT}"}, "bucket", "key", time.Hour); err == nil {
+			t.Error("Expected error for unset environment variable, received none")
+		}
+	})
+}
+
+func TestAddS3Handlers(t *testing.T) {
+	testCases := []struct {
+		Description         string
+		Config              *Config
+		Presign             bool
+		ExpectedContentHash string
+	}{
+		{
+			Description: "signed payload",
+			Config:      &Config{},
+		},
+		{
+			Description: "unsigned payload",
+			Config: &Config{
+				S3UnsignedPayload: true,
+			},
+			ExpectedContentHash: "UNSIGNED-PAYLOAD",
+		},
+		{
+			Description: "unsigned payload presigned",
+			Config: &Config{
+				S3UnsignedPayload: true,
+			},
+			Presign: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: credentials.NewStaticCredentials("accessKey", "secretKey", ""),
+				Region:      aws.String("us-west-2"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			addS3Handlers(testCase.Config, &sess.Handlers)
+
+			req, _ := NewS3Client(sess, testCase.Config).PutObjectRequest(&s3.PutObjectInput{
+				Body:   strings.NewReader("body"),
+				Bucket: aws.String("bucket"),
+				Key:    aws.String("key"),
+			})
+			if testCase.Presign {
+				if _, err := req.Presign(time.Hour); err != nil {
+					t.Fatalf("Expected no error, received error: %s", err)
+				}
+				if contentHash := req.HTTPRequest.Header.Get("X-Amz-Content-Sha256"); contentHash != "" {
+					t.Errorf("Expected no content hash header, got %q", contentHash)
+				}
+				return
+			}
+			if err := req.Sign(); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			contentHash := req.HTTPRequest.Header.Get("X-Amz-Content-Sha256")
+			if testCase.ExpectedContentHash == "" {
+				if contentHash == "UNSIGNED-PAYLOAD" {
+					t.Errorf("Expected signed payload, got %q", contentHash)
+				}
+				return
+			}
+			if contentHash != testCase.ExpectedContentHash {
+				t.Errorf("Expected content hash %q, got %q", testCase.ExpectedContentHash, contentHash)
+			}
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package awsbase
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -20,59 +20,125 @@ import (
 // options based on pre-existing credential provider, configured profile, or
 // fallback to automatically a determined session via the AWS Go SDK.
 func GetSessionOptions(c *Config) (*session.Options, error) {
+	c, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return getSessionOptions(context.Background(), c)
+}
+
+// getSessionOptions is GetSessionOptions for a Config whose references to
+// environment variables were expanded.
+func getSessionOptions(ctx context.Context, c *Config) (*session.Options, error) {
+	if c.Region != "" {
+		if err := ValidateRegionFormat(c.Region); err != nil {
+			return nil, err
+		}
+	}
+
+	stsRegionalEndpoint, s3UsEast1RegionalEndpoint, err := defaultsModeEndpoints(c)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = cleanhttp.DefaultClient()
+		if err := configureProxy(c, httpClient.Transport.(*http.Transport)); err != nil {
+			return nil, err
+		}
+		if err := configureDefaultsMode(c, httpClient.Transport.(*http.Transport)); err != nil {
+			return nil, err
+		}
+	}
+
 	options := &session.Options{
 		Config: aws.Config{
-			HTTPClient: cleanhttp.DefaultClient(),
-			MaxRetries: aws.Int(0),
-			Region:     aws.String(c.Region),
+			HTTPClient:                httpClient,
+			MaxRetries:                aws.Int(0),
+			Region:                    aws.String(c.Region),
+			S3UsEast1RegionalEndpoint: s3UsEast1RegionalEndpoint,
+			STSRegionalEndpoint:       stsRegionalEndpoint,
 		},
 	}
 
-	creds, err := GetCredentials(c)
+	if c.EndpointResolver != nil {
+		options.Config.EndpointResolver = c.EndpointResolver
+	}
+
+	if c.S3ForcePathStyle {
+		options.Config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	if c.S3UseAccelerate {
+		options.Config.S3UseAccelerate = aws.Bool(true)
+	}
+
+	if c.S3UseARNRegion {
+		options.Config.S3UseARNRegion = aws.Bool(true)
+	}
+
+	if c.S3UsEast1RegionalEndpoint != "" {
+		s3UsEast1RegionalEndpoint, err := endpoints.GetS3UsEast1RegionalEndpoint(c.S3UsEast1RegionalEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing S3 us-east-1 regional endpoint: %w", err)
+		}
+		options.Config.S3UsEast1RegionalEndpoint = s3UsEast1RegionalEndpoint
+	}
+
+	if err := applyInheritedSharedConfig(c, options); err != nil {
+		return nil, err
+	}
+
+	creds, _, err := getCredentialsWithAuditTrail(ctx, c)
 	if err != nil {
 		return nil, err
 	}
 
 	// Call Get to check for credential provider. If nothing found, we'll get an
 	// error, and we can present it nicely to the user
-	cp, err := creds.Get()
+	cp, err := creds.GetWithContext(ctx)
 	if err != nil {
 		if IsAWSErr(err, "NoCredentialProviders", "") {
 			// If a profile wasn't specified, the session may still be able to resolve credentials from shared config.
 			if c.Profile == "" {
 				sess, err := session.NewSession()
 				if err != nil {
-					return nil, errors.New(`No valid credential sources found for AWS Provider.
-	Please see https://terraform.io/docs/providers/aws/index.html for more information on
-	providing credentials for the AWS Provider`)
+					// Surface configuration errors, such as malformed shared
+					// configuration, rather than reporting missing credentials.
+					return nil, fmt.Errorf("Error creating AWS session: %w", err)
 				}
-				_, err = sess.Config.Credentials.Get()
-				if err != nil {
+				value, err := sess.Config.Credentials.GetWithContext(ctx)
+				if ssoErr := ssoTokenError(err, sharedCredentialsProfile(c.Profile), c.Clock); ssoErr != nil {
+					return nil, ssoErr
+				}
+				if err != nil || ignoredCredentialsProvider(c, value.ProviderName) {
 					return nil, errors.New(`No valid credential sources found for AWS Provider.
 	Please see https://terraform.io/docs/providers/aws/index.html for more information on
 	providing credentials for the AWS Provider`)
 				}
-				log.Printf("[INFO] Using session-derived AWS Auth")
+				logger.Printf("[INFO] Using session-derived AWS Auth")
 				options.Config.Credentials = sess.Config.Credentials
 			} else {
-				log.Printf("[INFO] AWS Auth using Profile: %q", c.Profile)
+				logger.Printf("[INFO] AWS Auth using Profile: %q", c.Profile)
 				options.Profile = c.Profile
 				options.SharedConfigState = session.SharedConfigEnable
+				if c.IgnoreSharedCredentialsFile {
+					options.SharedConfigFiles = []string{sharedConfigFilename()}
+				}
 			}
 		} else {
-			return nil, fmt.Errorf("Error loading credentials for AWS Provider: %s", err)
+			return nil, fmt.Errorf("Error loading credentials for AWS Provider: %w", err)
 		}
 	} else {
 		// add the validated credentials to the session options
-		log.Printf("[INFO] AWS Auth provider used: %q", cp.ProviderName)
+		logger.With(logFields{"provider": cp.ProviderName}).Printf("[INFO] AWS Auth provider used: %q", cp.ProviderName)
 		options.Config.Credentials = creds
 	}
 
 	if c.Insecure {
-		transport := options.Config.HTTPClient.Transport.(*http.Transport)
-		transport.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
+		options.Config.HTTPClient = insecureHTTPClient(options.Config.HTTPClient)
 	}
 
 	if c.DebugLogging {
@@ -83,9 +149,154 @@ func GetSessionOptions(c *Config) (*session.Options, error) {
 	return options, nil
 }
 
-// GetSession attempts to return valid AWS Go SDK session
+// insecureHTTPClient returns a copy of the given client which skips TLS
+// certificate verification. The transport of the given client is cloned rather
+// than modified, as it may be shared with the caller.
+func insecureHTTPClient(client *http.Client) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		logger.Printf("[WARN] Unable to disable TLS certificate verification for HTTP client transport %T", client.Transport)
+		return client
+	}
+
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+
+	insecureClient := *client
+	insecureClient.Transport = transport
+	return &insecureClient
+}
+
+// clientCertificateHTTPClient returns a copy of the given client which presents
+// the client certificate in the given files, e.g. to proxies which enforce
+// mutual TLS. The transport of the given client is cloned rather than
+// modified, as it may be shared with the caller.
+func clientCertificateHTTPClient(client *http.Client, certFilename, keyFilename string) (*http.Client, error) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unable to configure client certificate for HTTP client transport %T", client.Transport)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFilename, keyFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+
+	certificateClient := *client
+	certificateClient.Transport = transport
+	return &certificateClient, nil
+}
+
+// stsConfig returns the configuration of STS clients which use the given HTTP
+// client, with the STS endpoint, client certificate, and call timeout of the
+// Config applied.
+func stsConfig(c *Config, client *http.Client) (*aws.Config, error) {
+	config := &aws.Config{
+		Endpoint: aws.String(c.StsEndpoint),
+	}
+
+	if c.StsClientCertFilename != "" || c.StsClientKeyFilename != "" {
+		if c.StsClientCertFilename == "" || c.StsClientKeyFilename == "" {
+			return nil, errors.New("both STS client certificate and key files must be set")
+		}
+
+		certificateClient, err := clientCertificateHTTPClient(client, c.StsClientCertFilename, c.StsClientKeyFilename)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring STS client: %w", err)
+		}
+		config.HTTPClient = certificateClient
+	}
+
+	if c.StsCallTimeout > 0 {
+		timeoutClient := *client
+		if config.HTTPClient != nil {
+			timeoutClient = *config.HTTPClient
+		}
+		timeoutClient.Timeout = c.StsCallTimeout
+		config.HTTPClient = &timeoutClient
+	}
+
+	return config, nil
+}
+
+// addHandlers installs the handlers configured by the Config into the handlers
+// of sessions built by this package.
+func addHandlers(c *Config, handlers *request.Handlers) {
+	addSigningHandlers(c, handlers)
+	addClockSkewHandlers(c, handlers)
+	addSigV4AHandlers(c, handlers)
+	addS3Handlers(c, handlers)
+	addTracingHandlers(c, handlers)
+	addMetricsHandlers(c, handlers)
+	addRequestLoggingHandlers(c, handlers)
+	addHookHandlers(c, handlers)
+	addRetryQuotaHandlers(c, handlers)
+	addServiceMaxRetriesHandlers(c, handlers)
+}
+
+// GetSession attempts to return valid AWS Go SDK session. Unless
+// SkipCredsValidation is set, the credentials are validated with
+// sts:GetCallerIdentity, which principals denied access to STS can skip.
+//
+// When MemoizeSession is set, the session is cached for the lifetime of the
+// process and copies of it are returned for subsequent calls with an equal
+// Config and AWS_ environment variables, so that they share its connections
+// and credentials, e.g. provider aliases with identical settings, but not
+// changes to its handlers. Configs containing functions, e.g. OnRequest, are
+// not memoized.
 func GetSession(c *Config) (*session.Session, error) {
-	options, err := GetSessionOptions(c)
+	return GetSessionWithContext(context.Background(), c)
+}
+
+// GetSessionWithContext is GetSession with a context for the requests made to
+// build and validate the session, such as the credentials checks and
+// sts:GetCallerIdentity. When tracing is configured, the GetSession span is a
+// child of the span of the context.
+func GetSessionWithContext(ctx context.Context, c *Config) (*session.Session, error) {
+	c, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveSession(ctx, c)
+}
+
+// resolveSession is GetSessionWithContext for a Config whose references to
+// environment variables were expanded.
+func resolveSession(ctx context.Context, c *Config) (*session.Session, error) {
+	var cacheKey string
+	if c.MemoizeSession {
+		var ok bool
+		if cacheKey, ok = configHash(c); !ok {
+			logger.Print("[DEBUG] Config contains functions, not memoizing session")
+		} else if sess, ok := cachedSession(cacheKey); ok {
+			logger.Print("[DEBUG] Using memoized session")
+			return sess.Copy(), nil
+		}
+	}
+
+	ctx, span := startSpan(ctx, c, "GetSession")
+	sess, err := getSession(ctx, c)
+	endSpan(span, err)
+
+	if err == nil && cacheKey != "" {
+		cacheSession(cacheKey, sess)
+		return sess.Copy(), nil
+	}
+	return sess, err
+}
+
+func getSession(ctx context.Context, c *Config) (*session.Session, error) {
+	options, err := getSessionOptions(ctx, c)
 
 	if err != nil {
 		return nil, err
@@ -98,13 +309,27 @@ func GetSession(c *Config) (*session.Session, error) {
   Please see https://terraform.io/docs/providers/aws/index.html for more information on
   providing credentials for the AWS Provider`)
 		}
-		return nil, fmt.Errorf("Error creating AWS session: %s", err)
+		return nil, fmt.Errorf("Error creating AWS session: %w", err)
 	}
 
-	if c.MaxRetries > 0 {
+	retryer, err := defaultsModeRetryer(c)
+	if err != nil {
+		return nil, err
+	}
+	if retryer != nil {
+		sess = sess.Copy(request.WithRetryer(&aws.Config{}, retryer))
+	} else if c.MaxRetries > 0 {
 		sess = sess.Copy(&aws.Config{MaxRetries: aws.Int(c.MaxRetries)})
 	}
 
+	addHandlers(c, &sess.Handlers)
+
+	// The session is instrumented before the credentials are validated, so
+	// that the validation is, too.
+	if c.OnSession != nil {
+		sess = c.OnSession(sess)
+	}
+
 	for _, product := range c.UserAgentProducts {
 		sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(product.Name, product.Version, product.Extra...))
 	}
@@ -124,21 +349,31 @@ func GetSession(c *Config) (*session.Session, error) {
 		// RequestError: send request failed
 		// caused by: Post https://FQDN/: dial tcp: lookup FQDN: no such host
 		if IsAWSErrExtended(r.Error, "RequestError", "send request failed", "no such host") {
-			log.Printf("[WARN] Disabling retries after next request due to networking issue")
+			logger.Printf("[WARN] Disabling retries after next request due to networking issue")
 			r.Retryable = aws.Bool(false)
 		}
 		// RequestError: send request failed
 		// caused by: Post https://FQDN/: dial tcp IPADDRESS:443: connect: connection refused
 		if IsAWSErrExtended(r.Error, "RequestError", "send request failed", "connection refused") {
-			log.Printf("[WARN] Disabling retries after next request due to networking issue")
+			logger.Printf("[WARN] Disabling retries after next request due to networking issue")
 			r.Retryable = aws.Bool(false)
 		}
 	})
 
 	if !c.SkipCredsValidation {
-		stsClient := sts.New(sess.Copy(&aws.Config{Endpoint: aws.String(c.StsEndpoint)}))
-		if _, _, err := GetAccountIDAndPartitionFromSTSGetCallerIdentity(stsClient); err != nil {
-			return nil, fmt.Errorf("error validating provider credentials: %s", err)
+		stsConfig, err := stsConfig(c, sess.Config.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		stsClient := sts.New(sess.Copy(stsConfig))
+		_, _, err = accountIDAndPartitionFromSTSGetCallerIdentity(ctx, c.Clock, stsClient, func() (*sts.GetCallerIdentityOutput, error) {
+			return stsClient.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+		})
+		if err != nil {
+			if ssoErr := ssoTokenError(err, sharedCredentialsProfile(c.Profile), c.Clock); ssoErr != nil {
+				return nil, ssoErr
+			}
+			return nil, fmt.Errorf("error validating provider credentials: %w", err)
 		}
 	}
 
@@ -148,25 +383,37 @@ func GetSession(c *Config) (*session.Session, error) {
 // GetSessionWithAccountIDAndPartition attempts to return valid AWS Go SDK session
 // along with account ID and partition information if available
 func GetSessionWithAccountIDAndPartition(c *Config) (*session.Session, string, string, error) {
-	sess, err := GetSession(c)
+	c, err := ExpandConfigEnv(c)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	sess, err := resolveSession(context.Background(), c)
 
 	if err != nil {
 		return nil, "", "", err
 	}
 
-	if c.AssumeRoleARN != "" {
-		accountID, partition, _ := parseAccountIDAndPartitionFromARN(c.AssumeRoleARN)
+	if assumeRole := ResolveAssumeRole(c); assumeRole != nil {
+		accountID, partition, _ := parseAccountIDAndPartitionFromARN(assumeRole.RoleARN)
 		return sess, accountID, partition, nil
 	}
 
 	iamClient := iam.New(sess.Copy(&aws.Config{Endpoint: aws.String(c.IamEndpoint)}))
-	stsClient := sts.New(sess.Copy(&aws.Config{Endpoint: aws.String(c.StsEndpoint)}))
+	stsConfig, err := stsConfig(c, sess.Config.HTTPClient)
+	if err != nil {
+		return nil, "", "", err
+	}
+	stsClient := sts.New(sess.Copy(stsConfig))
 
 	if !c.SkipCredsValidation {
 		accountID, partition, err := GetAccountIDAndPartitionFromSTSGetCallerIdentity(stsClient)
 
 		if err != nil {
-			return nil, "", "", fmt.Errorf("error validating provider credentials: %s", err)
+			if ssoErr := ssoTokenError(err, sharedCredentialsProfile(c.Profile), c.Clock); ssoErr != nil {
+				return nil, "", "", ssoErr
+			}
+			return nil, "", "", fmt.Errorf("error validating provider credentials: %w", err)
 		}
 
 		return sess, accountID, partition, nil
@@ -188,13 +435,8 @@ func GetSessionWithAccountIDAndPartition(c *Config) (*session.Session, string, s
 		return nil, "", "", fmt.Errorf(
 			"AWS account ID not previously found and failed retrieving via all available methods. "+
 				"See https://www.terraform.io/docs/providers/aws/index.html#skip_requesting_account_id for workaround and implications. "+
-				"Errors: %s", err)
-	}
-
-	var partition string
-	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), c.Region); ok {
-		partition = p.ID()
+				"Errors: %w", err)
 	}
 
-	return sess, "", partition, nil
+	return sess, "", partitionForRegion(c.Region), nil
 }
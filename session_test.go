@@ -0,0 +1,832 @@
+package awsbase
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+func TestGetSessionWithAccountIDAndPartition(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		Config            *Config
+		STSEndpoints      []*awsmocks.MockEndpoint
+		ExpectedAccountID string
+		ExpectedPartition string
+		ExpectedError     bool
+	}{
+		{
+			Description: "static credentials validated with sts:GetCallerIdentity",
+			Config: &Config{
+				AccessKey:            "StaticAccessKey",
+				SecretKey:            "StaticSecretKey",
+				Region:               "us-east-1",
+				SkipMetadataApiCheck: true,
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockStsGetCallerIdentityValidEndpoint,
+			},
+			ExpectedAccountID: awsmocks.MockStsGetCallerIdentityAccountID,
+			ExpectedPartition: awsmocks.MockStsGetCallerIdentityPartition,
+		},
+		{
+			Description: "static credentials with assume role",
+			Config: &Config{
+				AccessKey: "StaticAccessKey",
+				AssumeRole: &AssumeRole{
+					RoleARN:     awsmocks.MockStsAssumeRoleArn,
+					SessionName: awsmocks.MockStsAssumeRoleSessionName,
+				},
+				SecretKey:            "StaticSecretKey",
+				Region:               "us-east-1",
+				SkipMetadataApiCheck: true,
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockStsAssumeRoleValidEndpoint,
+				awsmocks.MockStsGetCallerIdentityValidEndpoint,
+			},
+			ExpectedAccountID: "555555555555",
+			ExpectedPartition: "aws",
+		},
+		{
+			Description: "AWS GovCloud (US) credentials",
+			Config: &Config{
+				AccessKey:            "StaticAccessKey",
+				SecretKey:            "StaticSecretKey",
+				Region:               "us-gov-west-1",
+				SkipMetadataApiCheck: true,
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.GetCallerIdentityResponse{Arn: "arn:aws-us-gov:iam::123456789012:user/Alice"}.Endpoint(),
+			},
+			ExpectedAccountID: "123456789012",
+			ExpectedPartition: "aws-us-gov",
+		},
+		{
+			Description: "AWS China credentials",
+			Config: &Config{
+				AccessKey:            "StaticAccessKey",
+				SecretKey:            "StaticSecretKey",
+				Region:               "cn-north-1",
+				SkipMetadataApiCheck: true,
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.GetCallerIdentityResponse{Arn: "arn:aws-cn:iam::123456789012:user/Alice"}.Endpoint(),
+			},
+			ExpectedAccountID: "123456789012",
+			ExpectedPartition: "aws-cn",
+		},
+		{
+			Description: "partition from region when skipping account ID lookup",
+			Config: &Config{
+				AccessKey:               "StaticAccessKey",
+				SecretKey:               "StaticSecretKey",
+				Region:                  "cn-northwest-1",
+				SkipCredsValidation:     true,
+				SkipMetadataApiCheck:    true,
+				SkipRequestingAccountId: true,
+			},
+			ExpectedPartition: "aws-cn",
+		},
+		{
+			Description: "sts:GetCallerIdentity unauthorized",
+			Config: &Config{
+				AccessKey:            "StaticAccessKey",
+				SecretKey:            "StaticSecretKey",
+				Region:               "us-east-1",
+				SkipMetadataApiCheck: true,
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockStsGetCallerIdentityUnauthorizedEndpoint,
+			},
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			servers := awsmocks.NewServers(nil, testCase.STSEndpoints, nil)
+			defer servers.Close()
+
+			restoreEnv := ConfigureMockedAwsApi(testCase.Config, servers)
+			defer restoreEnv()
+
+			_, accountID, partition, err := GetSessionWithAccountIDAndPartition(testCase.Config)
+			if err != nil && !testCase.ExpectedError {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if err == nil && testCase.ExpectedError {
+				t.Fatal("Expected error, received none")
+			}
+			if accountID != testCase.ExpectedAccountID {
+				t.Errorf("Expected account ID %q, got %q", testCase.ExpectedAccountID, accountID)
+			}
+			if partition != testCase.ExpectedPartition {
+				t.Errorf("Expected partition %q, got %q", testCase.ExpectedPartition, partition)
+			}
+		})
+	}
+}
+
+func TestGetSessionWithAccountIDAndPartition_expandsEnv(t *testing.T) {
+	var testCases = []struct {
+		Description       string
+		Config            *Config
+		IAMEndpoints      []*awsmocks.MockEndpoint
+		STSEndpoints      []*awsmocks.MockEndpoint
+		ExpectedAccountID string
+	}{
+		{
+			Description: "sts endpoint",
+			Config: &Config{
+				AccessKey:            staticAccessKey,
+				SecretKey:            staticSecretKey,
+				Region:               "us-east-1",
+				SkipMetadataApiCheck: true,
+				StsEndpoint:          "${TEST_STS_ENDPOINT}",
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockStsGetCallerIdentityValidEndpoint,
+			},
+			ExpectedAccountID: awsmocks.MockStsGetCallerIdentityAccountID,
+		},
+		{
+			Description: "iam endpoint",
+			Config: &Config{
+				AccessKey:            staticAccessKey,
+				IamEndpoint:          "${TEST_IAM_ENDPOINT}",
+				SecretKey:            staticSecretKey,
+				Region:               "us-east-1",
+				SkipCredsValidation:  true,
+				SkipMetadataApiCheck: true,
+				StsEndpoint:          "${TEST_STS_ENDPOINT}",
+			},
+			IAMEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockIamGetUserValidEndpoint,
+			},
+			ExpectedAccountID: awsmocks.MockIamGetUserAccountID,
+		},
+		{
+			Description: "assume role sts endpoint",
+			Config: &Config{
+				AccessKey: staticAccessKey,
+				AssumeRole: &AssumeRole{
+					RoleARN:     awsmocks.MockStsAssumeRoleArn,
+					SessionName: awsmocks.MockStsAssumeRoleSessionName,
+					StsEndpoint: "${TEST_ASSUME_ROLE_STS_ENDPOINT}",
+				},
+				SecretKey:            staticSecretKey,
+				Region:               "us-east-1",
+				SkipMetadataApiCheck: true,
+				StsEndpoint:          "${TEST_STS_ENDPOINT}",
+			},
+			STSEndpoints: []*awsmocks.MockEndpoint{
+				awsmocks.MockStsAssumeRoleValidEndpoint,
+				awsmocks.MockStsGetCallerIdentityValidEndpoint,
+			},
+			ExpectedAccountID: "555555555555",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			servers := awsmocks.NewServers(testCase.IAMEndpoints, testCase.STSEndpoints, nil)
+			defer servers.Close()
+
+			t.Setenv("AWS_METADATA_URL", servers.EC2MetadataEndpoint())
+			t.Setenv("TEST_ASSUME_ROLE_STS_ENDPOINT", servers.StsEndpoint())
+			t.Setenv("TEST_IAM_ENDPOINT", servers.IamEndpoint())
+			t.Setenv("TEST_STS_ENDPOINT", servers.StsEndpoint())
+
+			_, accountID, _, err := GetSessionWithAccountIDAndPartition(testCase.Config)
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if accountID != testCase.ExpectedAccountID {
+				t.Errorf("Expected account ID %q, got %q", testCase.ExpectedAccountID, accountID)
+			}
+		})
+	}
+}
+
+func TestGetSession_requestError(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{awsmocks.MockStsGetCallerIdentityUnauthorizedEndpoint}, nil)
+	defer servers.Close()
+
+	config := &Config{
+		AccessKey:            "StaticAccessKey",
+		SecretKey:            "StaticSecretKey",
+		Region:               "us-east-1",
+		SkipMetadataApiCheck: true,
+	}
+	restoreEnv := ConfigureMockedAwsApi(config, servers)
+	defer restoreEnv()
+
+	_, err := GetSession(config)
+
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("Expected RequestError, received: %v", err)
+	}
+	if requestErr.StatusCode != 403 {
+		t.Errorf("Expected status code 403, got %d", requestErr.StatusCode)
+	}
+	if expected := "01234567-89ab-cdef-0123-456789abcdef"; requestErr.RequestID != expected {
+		t.Errorf("Expected request ID %q, got %q", expected, requestErr.RequestID)
+	}
+
+	var requestFailure awserr.RequestFailure
+	if !errors.As(err, &requestFailure) {
+		t.Fatalf("Expected awserr.RequestFailure, received: %v", err)
+	}
+	if requestFailure.Code() != "AccessDenied" {
+		t.Errorf("Expected code AccessDenied, got %q", requestFailure.Code())
+	}
+}
+
+func TestGetSessionOptions_httpClient(t *testing.T) {
+	transport := &http.Transport{}
+	httpClient := &http.Client{Transport: transport}
+
+	options, err := GetSessionOptions(&Config{
+		AccessKey:  "MockAccessKey",
+		SecretKey:  "MockSecretKey",
+		HTTPClient: httpClient,
+		Insecure:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	insecureTransport, ok := options.Config.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", options.Config.HTTPClient.Transport)
+	}
+	if insecureTransport.TLSClientConfig == nil || !insecureTransport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected TLS certificate verification to be disabled")
+	}
+	if transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected the given transport not to be modified")
+	}
+}
+
+func TestGetSessionOptions_defaultsMode(t *testing.T) {
+	var testCases = []struct {
+		Description                       string
+		DefaultsMode                      DefaultsMode
+		ExpectedTLSHandshakeTimeout       time.Duration
+		ExpectedSTSRegionalEndpoint       endpoints.STSRegionalEndpoint
+		ExpectedS3UsEast1RegionalEndpoint endpoints.S3UsEast1RegionalEndpoint
+		ExpectedError                     bool
+	}{
+		{
+			Description:                 "unset",
+			ExpectedTLSHandshakeTimeout: cleanhttp.DefaultTransport().TLSHandshakeTimeout,
+		},
+		{
+			Description:                 "legacy",
+			DefaultsMode:                DefaultsModeLegacy,
+			ExpectedTLSHandshakeTimeout: cleanhttp.DefaultTransport().TLSHandshakeTimeout,
+		},
+		{
+			Description:                       "in-region",
+			DefaultsMode:                      DefaultsModeInRegion,
+			ExpectedTLSHandshakeTimeout:       1100 * time.Millisecond,
+			ExpectedSTSRegionalEndpoint:       endpoints.RegionalSTSEndpoint,
+			ExpectedS3UsEast1RegionalEndpoint: endpoints.RegionalS3UsEast1Endpoint,
+		},
+		{
+			Description:                       "cross-region",
+			DefaultsMode:                      DefaultsModeCrossRegion,
+			ExpectedTLSHandshakeTimeout:       3100 * time.Millisecond,
+			ExpectedSTSRegionalEndpoint:       endpoints.RegionalSTSEndpoint,
+			ExpectedS3UsEast1RegionalEndpoint: endpoints.RegionalS3UsEast1Endpoint,
+		},
+		{
+			Description:   "invalid",
+			DefaultsMode:  DefaultsMode("fast"),
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			options, err := GetSessionOptions(&Config{
+				AccessKey:            "MockAccessKey",
+				DefaultsMode:         testCase.DefaultsMode,
+				SecretKey:            "MockSecretKey",
+				SkipMetadataApiCheck: true,
+			})
+			if testCase.ExpectedError {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			transport := options.Config.HTTPClient.Transport.(*http.Transport)
+			if transport.TLSHandshakeTimeout != testCase.ExpectedTLSHandshakeTimeout {
+				t.Errorf("Expected TLS handshake timeout %s, got %s", testCase.ExpectedTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+			}
+			if options.Config.STSRegionalEndpoint != testCase.ExpectedSTSRegionalEndpoint {
+				t.Errorf("Expected STS regional endpoint %s, got %s", testCase.ExpectedSTSRegionalEndpoint, options.Config.STSRegionalEndpoint)
+			}
+			if options.Config.S3UsEast1RegionalEndpoint != testCase.ExpectedS3UsEast1RegionalEndpoint {
+				t.Errorf("Expected S3 us-east-1 regional endpoint %s, got %s", testCase.ExpectedS3UsEast1RegionalEndpoint, options.Config.S3UsEast1RegionalEndpoint)
+			}
+		})
+	}
+}
+
+func TestGetSession_defaultsModeRetries(t *testing.T) {
+	var testCases = []struct {
+		Description           string
+		DefaultsMode          DefaultsMode
+		MaxRetries            int
+		ExpectedMaxRetries    int
+		ExpectedMaxRetryDelay time.Duration
+	}{
+		{
+			Description:           "legacy",
+			DefaultsMode:          DefaultsModeLegacy,
+			MaxRetries:            5,
+			ExpectedMaxRetries:    5,
+			ExpectedMaxRetryDelay: client.DefaultRetryerMaxRetryDelay,
+		},
+		{
+			Description:           "standard",
+			DefaultsMode:          DefaultsModeStandard,
+			ExpectedMaxRetries:    2,
+			ExpectedMaxRetryDelay: standardRetryMaxBackoff,
+		},
+		{
+			Description:           "mobile",
+			DefaultsMode:          DefaultsModeMobile,
+			ExpectedMaxRetries:    2,
+			ExpectedMaxRetryDelay: standardRetryMaxBackoff,
+		},
+		{
+			Description:           "standard with max retries",
+			DefaultsMode:          DefaultsModeStandard,
+			MaxRetries:            5,
+			ExpectedMaxRetries:    5,
+			ExpectedMaxRetryDelay: standardRetryMaxBackoff,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			sess, err := GetSession(&Config{
+				AccessKey:            "MockAccessKey",
+				DefaultsMode:         testCase.DefaultsMode,
+				MaxRetries:           testCase.MaxRetries,
+				Region:               "us-east-1",
+				SecretKey:            "MockSecretKey",
+				SkipCredsValidation:  true,
+				SkipMetadataApiCheck: true,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			retryer, ok := sts.New(sess).Retryer.(client.DefaultRetryer)
+			if !ok {
+				t.Fatalf("Expected default retryer, got %T", sts.New(sess).Retryer)
+			}
+			if retryer.MaxRetries() != testCase.ExpectedMaxRetries {
+				t.Errorf("Expected %d max retries, got %d", testCase.ExpectedMaxRetries, retryer.MaxRetries())
+			}
+			maxRetryDelay := retryer.MaxRetryDelay
+			if maxRetryDelay == 0 {
+				maxRetryDelay = client.DefaultRetryerMaxRetryDelay
+			}
+			if maxRetryDelay != testCase.ExpectedMaxRetryDelay {
+				t.Errorf("Expected max retry delay %s, got %s", testCase.ExpectedMaxRetryDelay, maxRetryDelay)
+			}
+		})
+	}
+}
+
+func TestGetSessionOptions_s3(t *testing.T) {
+	var testCases = []struct {
+		Description                       string
+		Config                            *Config
+		ExpectedS3ForcePathStyle          bool
+		ExpectedS3UseAccelerate           bool
+		ExpectedS3UseARNRegion            bool
+		ExpectedS3UsEast1RegionalEndpoint endpoints.S3UsEast1RegionalEndpoint
+		ExpectedError                     bool
+	}{
+		{
+			Description: "unset",
+			Config:      &Config{},
+		},
+		{
+			Description:              "S3ForcePathStyle",
+			Config:                   &Config{S3ForcePathStyle: true},
+			ExpectedS3ForcePathStyle: true,
+		},
+		{
+			Description:             "S3UseAccelerate",
+			Config:                  &Config{S3UseAccelerate: true},
+			ExpectedS3UseAccelerate: true,
+		},
+		{
+			Description:            "S3UseARNRegion",
+			Config:                 &Config{S3UseARNRegion: true},
+			ExpectedS3UseARNRegion: true,
+		},
+		{
+			Description:                       "S3UsEast1RegionalEndpoint regional",
+			Config:                            &Config{S3UsEast1RegionalEndpoint: "regional"},
+			ExpectedS3UsEast1RegionalEndpoint: endpoints.RegionalS3UsEast1Endpoint,
+		},
+		{
+			Description:                       "S3UsEast1RegionalEndpoint legacy",
+			Config:                            &Config{S3UsEast1RegionalEndpoint: "legacy"},
+			ExpectedS3UsEast1RegionalEndpoint: endpoints.LegacyS3UsEast1Endpoint,
+		},
+		{
+			Description:   "S3UsEast1RegionalEndpoint invalid",
+			Config:        &Config{S3UsEast1RegionalEndpoint: "global"},
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			testCase.Config.AccessKey = "MockAccessKey"
+			testCase.Config.SecretKey = "MockSecretKey"
+			testCase.Config.SkipMetadataApiCheck = true
+
+			options, err := GetSessionOptions(testCase.Config)
+			if testCase.ExpectedError {
+				if err == nil {
+					t.Fatal("Expected error, received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if v := aws.BoolValue(options.Config.S3ForcePathStyle); v != testCase.ExpectedS3ForcePathStyle {
+				t.Errorf("Expected S3ForcePathStyle %t, got %t", testCase.ExpectedS3ForcePathStyle, v)
+			}
+			if v := aws.BoolValue(options.Config.S3UseAccelerate); v != testCase.ExpectedS3UseAccelerate {
+				t.Errorf("Expected S3UseAccelerate %t, got %t", testCase.ExpectedS3UseAccelerate, v)
+			}
+			if v := aws.BoolValue(options.Config.S3UseARNRegion); v != testCase.ExpectedS3UseARNRegion {
+				t.Errorf("Expected S3UseARNRegion %t, got %t", testCase.ExpectedS3UseARNRegion, v)
+			}
+			if options.Config.S3UsEast1RegionalEndpoint != testCase.ExpectedS3UsEast1RegionalEndpoint {
+				t.Errorf("Expected S3 us-east-1 regional endpoint %s, got %s", testCase.ExpectedS3UsEast1RegionalEndpoint, options.Config.S3UsEast1RegionalEndpoint)
+			}
+		})
+	}
+}
+
+func TestGetSession_skipCredsValidation(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{awsmocks.MockStsGetCallerIdentityUnauthorizedEndpoint}, nil)
+	defer servers.Close()
+
+	config := &Config{
+		AccessKey:            "StaticAccessKey",
+		SecretKey:            "StaticSecretKey",
+		Region:               "us-east-1",
+		SkipCredsValidation:  true,
+		SkipMetadataApiCheck: true,
+	}
+	restoreEnv := ConfigureMockedAwsApi(config, servers)
+	defer restoreEnv()
+
+	if _, err := GetSession(config); err != nil {
+		t.Fatalf("Expected no error when skipping credentials validation, received: %s", err)
+	}
+
+	config.SkipCredsValidation = false
+
+	if _, err := GetSession(config); err == nil {
+		t.Fatal("Expected error when validating credentials denied access to STS, received none")
+	}
+}
+
+func TestGetSession_endpointResolver(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{awsmocks.MockStsGetCallerIdentityValidEndpoint}, nil)
+	defer servers.Close()
+
+	var resolved []string
+	config := &Config{
+		AccessKey: "StaticAccessKey",
+		EndpointResolver: endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+			resolved = append(resolved, service)
+			if service == sts.EndpointsID {
+				return endpoints.ResolvedEndpoint{
+					URL:           servers.StsEndpoint(),
+					SigningRegion: region,
+				}, nil
+			}
+			return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		}),
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+	}
+
+	if _, err := GetSession(config); err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if len(resolved) == 0 || resolved[len(resolved)-1] != sts.EndpointsID {
+		t.Errorf("Expected the %s endpoint to be resolved with the custom resolver, resolved: %v", sts.EndpointsID, resolved)
+	}
+}
+
+func TestGetSession_stsClientCertificate(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	certFilename, keyFilename := writeClientCertificate(t)
+
+	config := &Config{
+		AccessKey:            "StaticAccessKey",
+		Insecure:             true,
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          ts.URL,
+	}
+
+	if _, err := GetSession(config); err == nil {
+		t.Fatal("Expected error without client certificate, received none")
+	}
+
+	config.StsClientCertFilename = certFilename
+	config.StsClientKeyFilename = keyFilename
+
+	if _, err := GetSession(config); err != nil {
+		t.Fatalf("Expected no error with client certificate, received error: %s", err)
+	}
+
+	config.StsClientKeyFilename = ""
+
+	if _, err := GetSession(config); err == nil {
+		t.Fatal("Expected error without client key, received none")
+	}
+}
+
+func TestGetSession_stsCallTimeout(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	config := &Config{
+		AccessKey:            "StaticAccessKey",
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+		StsCallTimeout:       100 * time.Millisecond,
+		StsEndpoint:          ts.URL,
+	}
+
+	start := time.Now()
+	_, err := GetSession(config)
+	if err == nil {
+		t.Fatal("Expected error from hung STS endpoint, received none")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected STS call to time out, took %s", elapsed)
+	}
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key
+// to temporary files, returning their names.
+func writeClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %s", err)
+	}
+
+	dir := t.TempDir()
+	certFilename := filepath.Join(dir, "client.crt")
+	keyFilename := filepath.Join(dir, "client.key")
+
+	if err := ioutil.WriteFile(certFilename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(keyFilename, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+
+	return certFilename, keyFilename
+}
+
+func TestGetSession_malformedSharedConfig(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	home := t.TempDir()
+	configFile := filepath.Join(home, "config")
+	if err := ioutil.WriteFile(configFile, []byte(`[profile malformed
+region = us-east-1
+`), 0600); err != nil {
+		t.Fatalf("Error writing shared configuration file: %s", err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SDK_LOAD_CONFIG", "1")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "credentials"))
+
+	_, err := GetSession(&Config{
+		Region:               "us-east-1",
+		SkipMetadataApiCheck: true,
+	})
+	if err == nil {
+		t.Fatal("Expected error, received none")
+	}
+	if strings.Contains(err.Error(), "No valid credential sources") {
+		t.Errorf("Expected configuration error rather than missing credentials, got: %s", err)
+	}
+	var loadErr session.SharedConfigLoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("Expected wrapped SharedConfigLoadError, received: %v", err)
+	}
+	if loadErr.Filename != configFile {
+		t.Errorf("Expected error of file %q, got %q", configFile, loadErr.Filename)
+	}
+}
+
+func TestGetSession_ssoTokenError(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	home := t.TempDir()
+	configFile := filepath.Join(home, "config")
+	if err := ioutil.WriteFile(configFile, []byte(`[profile sso]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = Example
+region = us-east-1
+`), 0600); err != nil {
+		t.Fatalf("Error writing shared configuration file: %s", err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "credentials"))
+	t.Setenv("HOME", home)
+
+	_, err := GetSession(&Config{
+		Profile:              "sso",
+		Region:               "us-east-1",
+		SkipMetadataApiCheck: true,
+	})
+
+	var ssoErr *SSOTokenError
+	if !errors.As(err, &ssoErr) {
+		t.Fatalf("Expected SSOTokenError, received: %v", err)
+	}
+	if ssoErr.Profile != "sso" {
+		t.Errorf("Expected profile %q, got %q", "sso", ssoErr.Profile)
+	}
+	// The cached token of legacy SSO profiles is keyed by the SHA-1 of the start URL.
+	expectedPath := filepath.Join(home, ".aws", "sso", "cache", "e8be5486177c5b5392bd9aa76563515b29358e6e.json")
+	if ssoErr.TokenCachePath != expectedPath {
+		t.Errorf("Expected token cache path %q, got %q", expectedPath, ssoErr.TokenCachePath)
+	}
+	if expected := "aws sso login --profile sso"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error to contain %q, got: %s", expected, err)
+	}
+}
+
+func TestGetSession_ssoSessionTokenError(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	home := t.TempDir()
+	configFile := filepath.Join(home, "config")
+	if err := ioutil.WriteFile(configFile, []byte(`[profile sso]
+sso_session = corporate
+sso_account_id = 123456789012
+sso_role_name = Example
+region = us-east-1
+
+[sso-session corporate]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_registration_scopes = sso:account:access
+`), 0600); err != nil {
+		t.Fatalf("Error writing shared configuration file: %s", err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "credentials"))
+	t.Setenv("HOME", home)
+
+	_, err := GetSession(&Config{
+		Profile:              "sso",
+		Region:               "us-east-1",
+		SkipMetadataApiCheck: true,
+	})
+
+	var ssoErr *SSOTokenError
+	if !errors.As(err, &ssoErr) {
+		t.Fatalf("Expected SSOTokenError, received: %v", err)
+	}
+	if ssoErr.SSOSession != "corporate" {
+		t.Errorf("Expected sso-session %q, got %q", "corporate", ssoErr.SSOSession)
+	}
+	// The cached token of an sso-session is keyed by the SHA-1 of its name.
+	expectedPath := filepath.Join(home, ".aws", "sso", "cache", "e276d18d65653fa285155bfd3e11ab029306830c.json")
+	if ssoErr.TokenCachePath != expectedPath {
+		t.Errorf("Expected token cache path %q, got %q", expectedPath, ssoErr.TokenCachePath)
+	}
+	if expected := "aws sso login --sso-session corporate"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error to contain %q, got: %s", expected, err)
+	}
+}
@@ -0,0 +1,312 @@
+package awsbase
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const (
+	sharedConfigFileEnvVar      = "AWS_CONFIG_FILE"
+	sharedCredentialsFileEnvVar = "AWS_SHARED_CREDENTIALS_FILE"
+
+	// includeProfileKey is the shared configuration setting naming a profile
+	// whose settings a profile inherits, as supported by tools such as
+	// aws-vault. The settings of the profile override inherited ones.
+	includeProfileKey = "include_profile"
+)
+
+// sharedConfigSections are the sections of shared configuration or
+// credentials files, keyed by section name, e.g. "default" or "profile prod".
+type sharedConfigSections map[string]map[string]string
+
+// ResolveSharedCredentialsFilenames returns the shared credentials files of
+// the Config, or of the AWS_SHARED_CREDENTIALS_FILE environment variable if
+// CredsFilename is not set. Either may be a list of files separated by the OS
+// path list separator, e.g. a colon on Linux. It returns nil if neither is
+// set, in which case the AWS SDK default file is used.
+func ResolveSharedCredentialsFilenames(c *Config) []string {
+	filenames := c.CredsFilename
+	if filenames == "" {
+		filenames = os.Getenv(sharedCredentialsFileEnvVar)
+	}
+
+	var result []string
+	for _, filename := range filepath.SplitList(filenames) {
+		if filename != "" {
+			result = append(result, filename)
+		}
+	}
+	return result
+}
+
+// sharedConfigFilename returns the shared configuration file, as set by the
+// AWS_CONFIG_FILE environment variable or the AWS SDK default, ~/.aws/config.
+func sharedConfigFilename() string {
+	if filename := os.Getenv(sharedConfigFileEnvVar); filename != "" {
+		return filename
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", "config")
+}
+
+// loadSharedConfigProfile returns the settings of the profile in the shared
+// configuration file, including those it inherits, and the inherited settings
+// alone. It returns no settings if the file or profile doesn't exist.
+func loadSharedConfigProfile(profile string) (map[string]string, map[string]string, error) {
+	filename := sharedConfigFilename()
+	if filename == "" {
+		return map[string]string{}, map[string]string{}, nil
+	}
+
+	sections, err := loadSharedConfigFiles([]string{filename})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings, err := sections.profile(profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	own, _ := sections.profileSection(profile)
+	inherited := make(map[string]string)
+	for key, value := range settings {
+		if _, ok := own[key]; !ok {
+			inherited[key] = value
+		}
+	}
+
+	return settings, inherited, nil
+}
+
+// applyInheritedSharedConfig applies the region and CA bundle settings the
+// shared configuration profile of the Config inherits with include_profile,
+// which the AWS Go SDK doesn't read, unless they are set otherwise.
+func applyInheritedSharedConfig(c *Config, options *session.Options) error {
+	profile := sharedCredentialsProfile(c.Profile)
+
+	_, inherited, err := loadSharedConfigProfile(profile)
+	if err != nil {
+		logger.Printf("[WARN] Unable to read shared configuration profile %q: %s", profile, err)
+		return nil
+	}
+
+	if region := inherited["region"]; region != "" && c.Region == "" && os.Getenv("AWS_REGION") == "" && os.Getenv("AWS_DEFAULT_REGION") == "" {
+		logger.Printf("[DEBUG] Using region %q inherited by shared configuration profile %q", region, profile)
+		options.Config.Region = aws.String(region)
+	}
+
+	if caBundle := inherited["ca_bundle"]; caBundle != "" && os.Getenv("AWS_CA_BUNDLE") == "" {
+		contents, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return fmt.Errorf("error reading CA bundle of shared configuration profile %q: %w", profile, err)
+		}
+		options.CustomCABundle = bytes.NewReader(contents)
+	}
+
+	return nil
+}
+
+// profile returns the settings of the profile of a shared configuration file,
+// whose section is named "profile <name>", or "default" for the default
+// profile. Settings of profiles named by include_profile are inherited,
+// recursively.
+func (s sharedConfigSections) profile(name string) (map[string]string, error) {
+	settings := make(map[string]string)
+	visited := make(map[string]bool)
+
+	// The included profiles are applied after the profile, without overriding
+	// its settings.
+	for name != "" {
+		if visited[name] {
+			return nil, fmt.Errorf("shared configuration profile %q is included recursively", name)
+		}
+		visited[name] = true
+
+		section, ok := s.profileSection(name)
+		if !ok {
+			if len(visited) > 1 {
+				return nil, fmt.Errorf("included shared configuration profile %q not found", name)
+			}
+			return settings, nil
+		}
+
+		for key, value := range section {
+			if _, ok := settings[key]; !ok {
+				settings[key] = value
+			}
+		}
+		name = section[includeProfileKey]
+	}
+
+	delete(settings, includeProfileKey)
+	return settings, nil
+}
+
+func (s sharedConfigSections) profileSection(name string) (map[string]string, bool) {
+	if section, ok := s["profile "+name]; ok {
+		return section, true
+	}
+	if name == "default" {
+		section, ok := s["default"]
+		return section, ok
+	}
+	return nil, false
+}
+
+// newSharedCredentialsProvider returns the provider of credentials from the
+// shared credentials files of the Config.
+func newSharedCredentialsProvider(c *Config) awsCredentials.Provider {
+	filenames := ResolveSharedCredentialsFilenames(c)
+	if len(filenames) > 1 {
+		return &sharedCredentialsFilesProvider{
+			Filenames: filenames,
+			Profile:   sharedCredentialsProfile(c.Profile),
+		}
+	}
+
+	provider := &awsCredentials.SharedCredentialsProvider{
+		Profile: c.Profile,
+	}
+	if len(filenames) == 1 {
+		provider.Filename = filenames[0]
+	}
+	return provider
+}
+
+// sharedCredentialsFilesProvider retrieves credentials for a profile from
+// multiple shared credentials files, whose profiles are merged in order. Files
+// which don't exist are skipped. Settings of a profile in later files override
+// those in earlier files, as in the AWS Go SDK v2, e.g. so that a file written
+// by a corporate tool can be combined with one maintained by the user.
+type sharedCredentialsFilesProvider struct {
+	Filenames []string
+	Profile   string
+
+	retrieved bool
+}
+
+func (p *sharedCredentialsFilesProvider) Retrieve() (awsCredentials.Value, error) {
+	p.retrieved = false
+
+	sections, err := loadSharedConfigFiles(p.Filenames)
+	if err != nil {
+		return awsCredentials.Value{ProviderName: awsCredentials.SharedCredsProviderName}, err
+	}
+
+	section, ok := sections[p.Profile]
+	if !ok {
+		return awsCredentials.Value{ProviderName: awsCredentials.SharedCredsProviderName},
+			fmt.Errorf("profile %q not found in shared credentials files %s", p.Profile, strings.Join(p.Filenames, ", "))
+	}
+
+	value := awsCredentials.Value{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+		ProviderName:    awsCredentials.SharedCredsProviderName,
+	}
+	if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+		return awsCredentials.Value{ProviderName: awsCredentials.SharedCredsProviderName},
+			fmt.Errorf("shared credentials profile %q is missing the access key or secret key", p.Profile)
+	}
+
+	p.retrieved = true
+	return value, nil
+}
+
+func (p *sharedCredentialsFilesProvider) IsExpired() bool {
+	return !p.retrieved
+}
+
+// loadSharedConfigFiles parses the shared configuration or credentials files,
+// merging their sections in order. Settings in later files override those in
+// earlier files. Files which don't exist are skipped.
+func loadSharedConfigFiles(filenames []string) (sharedConfigSections, error) {
+	sections := make(sharedConfigSections)
+
+	for _, filename := range filenames {
+		fileSections, err := parseSharedConfigFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for name, section := range fileSections {
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			for key, value := range section {
+				sections[name][key] = value
+			}
+		}
+	}
+
+	return sections, nil
+}
+
+// parseSharedConfigFile parses the sections of a shared configuration or
+// credentials file. Nested settings, e.g. those of s3 in a config file, are
+// ignored.
+func parseSharedConfigFile(filename string) (sharedConfigSections, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(sharedConfigSections)
+	var section map[string]string
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("error parsing %s line %d: invalid section %q", filename, lineNumber, trimmed)
+			}
+			name := strings.Join(strings.Fields(trimmed[1:len(trimmed)-1]), " ")
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+
+		// Indented lines are nested settings of the preceding setting.
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok || section == nil {
+			return nil, fmt.Errorf("error parsing %s line %d: expected a setting in a section", filename, lineNumber)
+		}
+		section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,217 @@
+package awsbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestResolveSharedCredentialsFilenames(t *testing.T) {
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", strings.Join([]string{"/env/a", "/env/b"}, string(os.PathListSeparator)))
+
+	var testCases = []struct {
+		Description string
+		Config      *Config
+		Expected    []string
+	}{
+		{
+			Description: "environment variable",
+			Config:      &Config{},
+			Expected:    []string{"/env/a", "/env/b"},
+		},
+		{
+			Description: "single file",
+			Config:      &Config{CredsFilename: "/config/a"},
+			Expected:    []string{"/config/a"},
+		},
+		{
+			Description: "multiple files",
+			Config:      &Config{CredsFilename: strings.Join([]string{"/config/a", "/config/b"}, string(os.PathListSeparator))},
+			Expected:    []string{"/config/a", "/config/b"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			actual := ResolveSharedCredentialsFilenames(testCase.Config)
+			if strings.Join(actual, ",") != strings.Join(testCase.Expected, ",") {
+				t.Errorf("Expected %q, got %q", testCase.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestAWSGetCredentials_multipleSharedCredentialsFiles(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	dir := t.TempDir()
+	corporate := filepath.Join(dir, "corporate")
+	user := filepath.Join(dir, "user")
+	missing := filepath.Join(dir, "missing")
+
+	if err := os.WriteFile(corporate, []byte(`[myprofile]
+aws_access_key_id = corporateAccessKey
+aws_secret_access_key = corporateSecretKey
+aws_session_token = corporateToken
+
+[other]
+aws_access_key_id = otherAccessKey
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(user, []byte(`# maintained by the user
+[myprofile]
+aws_access_key_id = userAccessKey
+aws_secret_access_key = userSecretKey
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := GetCredentials(&Config{
+		CredsFilename:        strings.Join([]string{corporate, missing, user}, string(os.PathListSeparator)),
+		Profile:              "myprofile",
+		SkipMetadataApiCheck: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Expected no error getting credentials, received error: %s", err)
+	}
+	if value.AccessKeyID != "userAccessKey" || value.SecretAccessKey != "userSecretKey" {
+		t.Errorf("Expected credentials of the later file, got %q/%q", value.AccessKeyID, value.SecretAccessKey)
+	}
+	if value.SessionToken != "corporateToken" {
+		t.Errorf("Expected session token of the earlier file, got %q", value.SessionToken)
+	}
+	if value.ProviderName != SharedCredsProviderName {
+		t.Errorf("Expected provider name %q, got %q", SharedCredsProviderName, value.ProviderName)
+	}
+}
+
+func TestSharedConfigSectionsProfile(t *testing.T) {
+	sections := sharedConfigSections{
+		"default": {
+			"region": "us-east-1",
+		},
+		"profile base": {
+			"ca_bundle": "/etc/ssl/corporate.pem",
+			"region":    "us-west-2",
+		},
+		"profile account": {
+			includeProfileKey: "base",
+			"role_arn":        "arn:aws:iam::123456789012:role/example",
+		},
+		"profile override": {
+			includeProfileKey: "account",
+			"region":          "eu-west-1",
+		},
+		"profile loop": {
+			includeProfileKey: "loop",
+		},
+		"profile dangling": {
+			includeProfileKey: "missing",
+		},
+	}
+
+	var testCases = []struct {
+		Profile     string
+		Expected    map[string]string
+		ExpectedErr string
+	}{
+		{
+			Profile:  "default",
+			Expected: map[string]string{"region": "us-east-1"},
+		},
+		{
+			Profile: "account",
+			Expected: map[string]string{
+				"ca_bundle": "/etc/ssl/corporate.pem",
+				"region":    "us-west-2",
+				"role_arn":  "arn:aws:iam::123456789012:role/example",
+			},
+		},
+		{
+			Profile: "override",
+			Expected: map[string]string{
+				"ca_bundle": "/etc/ssl/corporate.pem",
+				"region":    "eu-west-1",
+				"role_arn":  "arn:aws:iam::123456789012:role/example",
+			},
+		},
+		{
+			Profile:  "missing",
+			Expected: map[string]string{},
+		},
+		{
+			Profile:     "loop",
+			ExpectedErr: "included recursively",
+		},
+		{
+			Profile:     "dangling",
+			ExpectedErr: `included shared configuration profile "missing" not found`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Profile, func(t *testing.T) {
+			settings, err := sections.profile(testCase.Profile)
+			if testCase.ExpectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.ExpectedErr) {
+					t.Fatalf("Expected error containing %q, received: %v", testCase.ExpectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			if len(settings) != len(testCase.Expected) {
+				t.Fatalf("Expected settings %v, got %v", testCase.Expected, settings)
+			}
+			for key, expected := range testCase.Expected {
+				if settings[key] != expected {
+					t.Errorf("Expected %s to be %q, got %q", key, expected, settings[key])
+				}
+			}
+		})
+	}
+}
+
+func TestGetSessionOptions_inheritedRegion(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	configFile := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(configFile, []byte(`[profile base]
+region = us-west-2
+
+[profile account]
+include_profile = base
+`), 0600); err != nil {
+		t.Fatalf("Error writing shared configuration file: %s", err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	options, err := GetSessionOptions(&Config{
+		AccessKey:            "accessKey",
+		SecretKey:            "secretKey",
+		Profile:              "account",
+		SkipMetadataApiCheck: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	if region := aws.StringValue(options.Config.Region); region != "us-west-2" {
+		t.Errorf("Expected inherited region %q, got %q", "us-west-2", region)
+	}
+}
@@ -0,0 +1,191 @@
+package awsbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsCredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+)
+
+func TestAddSigningHandlers(t *testing.T) {
+	var testCases = []struct {
+		Description           string
+		Config                *Config
+		ExpectedCredentialEnd string
+	}{
+		{
+			Description:           "no override",
+			Config:                &Config{},
+			ExpectedCredentialEnd: "/us-east-1/sts/aws4_request",
+		},
+		{
+			Description: "signing region override",
+			Config: &Config{
+				StsSigningRegion: "eu-west-1",
+			},
+			ExpectedCredentialEnd: "/eu-west-1/sts/aws4_request",
+		},
+		{
+			Description: "signing name and region override",
+			Config: &Config{
+				StsSigningName:   "execute-api",
+				StsSigningRegion: "eu-west-1",
+			},
+			ExpectedCredentialEnd: "/eu-west-1/execute-api/aws4_request",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			var authorization string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				w.Header().Set("Content-Type", "text/xml")
+				fmt.Fprint(w, stsResponse_GetCallerIdentity_valid)
+			}))
+			defer ts.Close()
+
+			config := testCase.Config
+			config.AccessKey = "StaticAccessKey"
+			config.Region = "us-east-1"
+			config.SecretKey = "StaticSecretKey"
+			config.SkipMetadataApiCheck = true
+			config.StsEndpoint = ts.URL
+
+			if _, err := GetSession(config); err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			credential := strings.SplitN(strings.TrimPrefix(authorization, "AWS4-HMAC-SHA256 Credential="), ",", 2)[0]
+			if !strings.HasSuffix(credential, testCase.ExpectedCredentialEnd) {
+				t.Errorf("Expected credential scope ending with %q, got %q", testCase.ExpectedCredentialEnd, credential)
+			}
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	signer, err := NewSigner(&Config{
+		AccessKey:            "StaticAccessKey",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://search-example.us-west-2.es.amazonaws.com/_search", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := signer.Sign(req, nil, "es", "us-west-2", signTime); err != nil {
+		t.Fatalf("Expected no error signing request, received error: %s", err)
+	}
+
+	expected := "AWS4-HMAC-SHA256 Credential=StaticAccessKey/20210102/us-west-2/es/aws4_request,"
+	if authorization := req.Header.Get("Authorization"); !strings.HasPrefix(authorization, expected) {
+		t.Errorf("Expected Authorization header starting with %q, got %q", expected, authorization)
+	}
+}
+
+func TestNewSigner_noCredentials(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	if _, err := NewSigner(&Config{SkipMetadataApiCheck: true, Profile: "nonexistent"}); err == nil {
+		t.Fatal("Expected error, received none")
+	}
+}
+
+func TestAddSigningHandlers_endpoints(t *testing.T) {
+	var testCases = []struct {
+		Description           string
+		Config                func(endpoint string) *Config
+		Request               func(sess *session.Session, endpoint string) *request.Request
+		ExpectedCredentialEnd string
+	}{
+		{
+			Description: "S3 endpoint",
+			Config: func(endpoint string) *Config {
+				return &Config{
+					S3Endpoint:      endpoint,
+					S3SigningRegion: "eu-west-1",
+				}
+			},
+			Request: func(sess *session.Session, endpoint string) *request.Request {
+				req, _ := s3.New(sess, &aws.Config{Endpoint: aws.String(endpoint)}).ListBucketsRequest(&s3.ListBucketsInput{})
+				return req
+			},
+			ExpectedCredentialEnd: "/eu-west-1/s3/aws4_request",
+		},
+		{
+			Description: "assume role STS endpoint",
+			Config: func(endpoint string) *Config {
+				return &Config{
+					AssumeRole: &AssumeRole{
+						RoleARN:     awsmocks.MockStsAssumeRoleArn,
+						SessionName: awsmocks.MockStsAssumeRoleSessionName,
+						StsEndpoint: endpoint,
+					},
+					StsSigningRegion: "eu-west-1",
+				}
+			},
+			Request: func(sess *session.Session, endpoint string) *request.Request {
+				req, _ := sts.New(sess, &aws.Config{Endpoint: aws.String(endpoint)}).GetCallerIdentityRequest(&sts.GetCallerIdentityInput{})
+				return req
+			},
+			ExpectedCredentialEnd: "/eu-west-1/sts/aws4_request",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+
+			var authorization string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+			}))
+			defer ts.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Credentials: awsCredentials.NewStaticCredentials("StaticAccessKey", "StaticSecretKey", ""),
+				Region:      aws.String("us-east-1"),
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+			addSigningHandlers(testCase.Config(ts.URL), &sess.Handlers)
+
+			// Only the signature of the request matters, not its response.
+			_ = testCase.Request(sess, ts.URL).Send()
+
+			credential := strings.SplitN(strings.TrimPrefix(authorization, "AWS4-HMAC-SHA256 Credential="), ",", 2)[0]
+			if !strings.HasSuffix(credential, testCase.ExpectedCredentialEnd) {
+				t.Errorf("Expected credential scope ending with %q, got %q", testCase.ExpectedCredentialEnd, credential)
+			}
+		})
+	}
+}
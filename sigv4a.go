@@ -0,0 +1,378 @@
+package awsbase
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/rest"
+)
+
+const (
+	sigV4AAlgorithm       = "AWS4-ECDSA-P256-SHA256"
+	sigV4ATimeFormat      = "20060102T150405Z"
+	sigV4AShortTimeFormat = "20060102"
+	sigV4AUnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sigV4AIgnoredHeaders are the headers which are not signed, as they may be
+// changed by proxies or the HTTP client.
+var sigV4AIgnoredHeaders = map[string]bool{
+	"Authorization":     true,
+	"Transfer-Encoding": true,
+	"User-Agent":        true,
+	"X-Amzn-Trace-Id":   true,
+}
+
+// SigV4ASigner signs HTTP requests with SigV4A, the asymmetric variant of
+// SigV4 whose signatures are valid in a set of regions, as required by S3
+// Multi-Region Access Points and some global APIs. Its signing key is derived
+// from the credentials, which are refreshed as they expire.
+type SigV4ASigner struct {
+	Credentials *credentials.Credentials
+
+	// DisableURIPathEscaping disables escaping the request path in the
+	// canonical request, as required for S3.
+	DisableURIPathEscaping bool
+}
+
+// NewSigV4ASigner returns a SigV4A signer using the credentials resolved for
+// the Config by GetCredentials, as NewSigner does for SigV4.
+func NewSigV4ASigner(c *Config) (*SigV4ASigner, error) {
+	creds, err := GetCredentials(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := creds.Get(); err != nil {
+		return nil, fmt.Errorf("error retrieving credentials for signer: %w", err)
+	}
+
+	return &SigV4ASigner{Credentials: creds}, nil
+}
+
+// Sign signs the request for the service in the given regions, e.g. "*" for
+// all regions, at the given time, setting its Authorization header. The body,
+// if any, is hashed and rewound. The signed headers are returned.
+func (s *SigV4ASigner) Sign(r *http.Request, body io.ReadSeeker, service string, regionSet []string, signTime time.Time) (http.Header, error) {
+	return s.sign(r, body, service, regionSet, signTime, 0)
+}
+
+// Presign signs the request for the service in the given regions, as Sign
+// does, adding the signature to its query string instead, valid for the
+// given duration. Headers are not moved to the query string, so the returned
+// signed headers must be sent with the presigned request.
+func (s *SigV4ASigner) Presign(r *http.Request, body io.ReadSeeker, service string, regionSet []string, expiry time.Duration, signTime time.Time) (http.Header, error) {
+	if expiry <= 0 {
+		return nil, errors.New("presigned request expiry must be positive")
+	}
+	return s.sign(r, body, service, regionSet, signTime, expiry)
+}
+
+func (s *SigV4ASigner) sign(r *http.Request, body io.ReadSeeker, service string, regionSet []string, signTime time.Time, expiry time.Duration) (http.Header, error) {
+	value, err := s.Credentials.GetWithContext(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveSigV4AKey(value.AccessKeyID, value.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signTime = signTime.UTC()
+	presign := expiry > 0
+	credentialScope := strings.Join([]string{signTime.Format(sigV4AShortTimeFormat), service, "aws4_request"}, "/")
+	credential := value.AccessKeyID + "/" + credentialScope
+
+	query := r.URL.Query()
+	if presign {
+		query.Set("X-Amz-Algorithm", sigV4AAlgorithm)
+		query.Set("X-Amz-Credential", credential)
+		query.Set("X-Amz-Date", signTime.Format(sigV4ATimeFormat))
+		query.Set("X-Amz-Expires", strconv.FormatInt(int64(expiry/time.Second), 10))
+		query.Set("X-Amz-Region-Set", strings.Join(regionSet, ","))
+		query.Del("X-Amz-Signature")
+		if value.SessionToken != "" {
+			query.Set("X-Amz-Security-Token", value.SessionToken)
+		}
+	} else {
+		r.Header.Set("X-Amz-Date", signTime.Format(sigV4ATimeFormat))
+		r.Header.Set("X-Amz-Region-Set", strings.Join(regionSet, ","))
+		if value.SessionToken != "" {
+			r.Header.Set("X-Amz-Security-Token", value.SessionToken)
+		}
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		if presign && service == "s3" {
+			payloadHash = sigV4AUnsignedPayload
+		} else {
+			if payloadHash, err = hashBody(body); err != nil {
+				return nil, err
+			}
+			if service == "s3" {
+				r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+			}
+		}
+	}
+
+	signedHeaders, signedHeaderNames, canonicalHeaders := sigV4ACanonicalHeaders(r)
+	if presign {
+		query.Set("X-Amz-SignedHeaders", signedHeaderNames)
+	}
+
+	for key := range query {
+		sort.Strings(query[key])
+	}
+	rawQuery := strings.Replace(query.Encode(), "+", "%20", -1)
+
+	canonicalURI := sigV4ACanonicalURIPath(r.URL)
+	if !s.DisableURIPathEscaping {
+		canonicalURI = rest.EscapePath(canonicalURI, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI,
+		rawQuery,
+		canonicalHeaders,
+		signedHeaderNames,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		sigV4AAlgorithm,
+		signTime.Format(sigV4ATimeFormat),
+		credentialScope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+	stringToSignHash := sha256.Sum256([]byte(stringToSign))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, key, stringToSignHash[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing request: %w", err)
+	}
+
+	if presign {
+		r.URL.RawQuery = rawQuery + "&X-Amz-Signature=" + hex.EncodeToString(signature)
+	} else {
+		r.URL.RawQuery = rawQuery
+		r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+			sigV4AAlgorithm, credential, signedHeaderNames, hex.EncodeToString(signature)))
+	}
+
+	return signedHeaders, nil
+}
+
+// hashBody returns the hex-encoded SHA-256 hash of the body, which is rewound.
+func hashBody(body io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if body == nil {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", fmt.Errorf("error hashing request body: %w", err)
+	}
+	if _, err := io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("error hashing request body: %w", err)
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return "", fmt.Errorf("error hashing request body: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sigV4ACanonicalHeaders returns the signed headers of the request, keyed by
+// lowercase name, their names, and their canonical form.
+func sigV4ACanonicalHeaders(r *http.Request) (http.Header, string, string) {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	if port := r.URL.Port(); (r.URL.Scheme == "https" && port == "443") || (r.URL.Scheme == "http" && port == "80") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+
+	signed := http.Header{"host": {host}}
+	if r.ContentLength > 0 && r.Header.Get("Content-Length") == "" {
+		signed["content-length"] = []string{strconv.FormatInt(r.ContentLength, 10)}
+	}
+	for key, values := range r.Header {
+		if sigV4AIgnoredHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		lowerKey := strings.ToLower(key)
+		signed[lowerKey] = append(signed[lowerKey], values...)
+	}
+
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		values := make([]string, len(signed[name]))
+		for i, value := range signed[name] {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		fmt.Fprintf(&canonical, "%s:%s\n", name, strings.Join(values, ","))
+	}
+
+	return signed, strings.Join(names, ";"), canonical.String()
+}
+
+// sigV4ACanonicalURIPath returns the path of the URL, as sent by the HTTP
+// client.
+func sigV4ACanonicalURIPath(u *url.URL) string {
+	var uri string
+	if u.Opaque != "" {
+		uri = "/" + strings.Join(strings.Split(u.Opaque, "/")[3:], "/")
+	} else {
+		uri = u.EscapedPath()
+	}
+	if uri == "" {
+		uri = "/"
+	}
+	return uri
+}
+
+// deriveSigV4AKey derives the NIST P-256 signing key of the access key from
+// the secret key, as specified by FIPS 186-4 Appendix B.4.2 with the NIST SP
+// 800-108 HMAC-SHA256 counter mode KDF.
+func deriveSigV4AKey(accessKey, secretKey string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2)).Bytes()
+	bitLen := curve.Params().BitSize
+
+	mac := hmac.New(sha256.New, []byte("AWS4A"+secretKey))
+
+	for counter := 1; counter <= 0xFF; counter++ {
+		var fixedInput bytes.Buffer
+		fixedInput.WriteString(sigV4AAlgorithm)
+		fixedInput.WriteByte(0x00)
+		fixedInput.WriteString(accessKey)
+		fixedInput.WriteByte(byte(counter))
+		binary.Write(&fixedInput, binary.BigEndian, int32(bitLen))
+
+		// A single iteration of the KDF yields the 256 bits required.
+		mac.Reset()
+		binary.Write(mac, binary.BigEndian, int32(1))
+		mac.Write(fixedInput.Bytes())
+		candidate := mac.Sum(nil)
+
+		if constantTimeCompare(candidate, nMinusTwo) < 0 {
+			d := new(big.Int).SetBytes(candidate)
+			d.Add(d, big.NewInt(1))
+
+			key := &ecdsa.PrivateKey{D: d}
+			key.PublicKey.Curve = curve
+			key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("error deriving SigV4A signing key: exhausted counter")
+}
+
+// constantTimeCompare compares the big-endian numbers of equal length in
+// constant time, returning -1, 0, or 1 as bytes.Compare does.
+func constantTimeCompare(x, y []byte) int {
+	xLarger, yLarger := 0, 0
+
+	for i := range x {
+		xByte, yByte := int(x[i]), int(y[i])
+
+		xGreater := ((yByte - xByte) >> 8) & 1
+		yGreater := ((xByte - yByte) >> 8) & 1
+
+		xLarger |= xGreater &^ yLarger
+		yLarger |= yGreater &^ xLarger
+	}
+
+	return xLarger - yLarger
+}
+
+// addSigV4AHandlers signs requests to the services of SigV4AServices of the
+// Config with SigV4A, for the regions of SigV4ARegionSet, or all regions,
+// instead of SigV4.
+func addSigV4AHandlers(c *Config, handlers *request.Handlers) {
+	if len(c.SigV4AServices) == 0 {
+		return
+	}
+
+	services := make(map[string]bool, len(c.SigV4AServices))
+	for _, service := range c.SigV4AServices {
+		services[service] = true
+	}
+
+	regionSet := c.SigV4ARegionSet
+	if len(regionSet) == 0 {
+		regionSet = []string{"*"}
+	}
+	clock := resolveClock(c.Clock)
+
+	signer := request.NamedHandler{
+		Name: v4.SignRequestHandler.Name,
+		Fn: func(r *request.Request) {
+			if r.Config.Credentials == credentials.AnonymousCredentials {
+				return
+			}
+
+			name := r.ClientInfo.SigningName
+			if name == "" {
+				name = r.ClientInfo.ServiceName
+			}
+
+			s := &SigV4ASigner{
+				Credentials:            r.Config.Credentials,
+				DisableURIPathEscaping: name == "s3",
+			}
+			signTime := clock.Now()
+
+			signedHeaders, err := s.sign(r.HTTPRequest, r.GetBody(), name, regionSet, signTime, r.ExpireTime)
+			if err != nil {
+				r.Error = err
+				r.SignedHeaderVals = nil
+				return
+			}
+
+			r.SignedHeaderVals = signedHeaders
+			r.LastSignedAt = signTime
+		},
+	}
+
+	handlers.Build.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.SigV4ASigner",
+		Fn: func(r *request.Request) {
+			if services[r.ClientInfo.SigningName] || services[r.ClientInfo.ServiceName] {
+				r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, signer)
+			}
+		},
+	})
+}
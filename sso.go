@@ -0,0 +1,128 @@
+package awsbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
+)
+
+// ssoSessionSectionPrefix is the prefix of the names of the sso-session
+// sections of shared configuration files, e.g. [sso-session example].
+const ssoSessionSectionPrefix = "sso-session "
+
+// ssoConfig is the AWS SSO configuration of a shared configuration profile,
+// either set in the profile or, as by current versions of the AWS CLI, in an
+// sso-session section the profile references with sso_session.
+type ssoConfig struct {
+	AccountID          string
+	Region             string
+	RegistrationScopes []string
+	RoleName           string
+	SessionName        string
+	StartURL           string
+}
+
+// loadSSOConfig returns the AWS SSO configuration of the profile in the shared
+// configuration file, or nil if the profile doesn't use AWS SSO.
+func loadSSOConfig(profile string) (*ssoConfig, error) {
+	filename := sharedConfigFilename()
+	if filename == "" {
+		return nil, nil
+	}
+
+	sections, err := loadSharedConfigFiles([]string{filename})
+	if err != nil {
+		return nil, err
+	}
+	return sections.ssoConfig(profile)
+}
+
+// ssoConfig returns the AWS SSO configuration of the profile, or nil if the
+// profile doesn't use AWS SSO. As in the AWS CLI, SSO settings set in both the
+// profile and its sso-session must match.
+func (s sharedConfigSections) ssoConfig(profile string) (*ssoConfig, error) {
+	settings, err := s.profile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssoConfig{
+		AccountID:   settings["sso_account_id"],
+		Region:      settings["sso_region"],
+		RoleName:    settings["sso_role_name"],
+		SessionName: settings["sso_session"],
+		StartURL:    settings["sso_start_url"],
+	}
+
+	if config.SessionName == "" {
+		if config.StartURL == "" {
+			return nil, nil
+		}
+		return config, nil
+	}
+
+	session, ok := s[ssoSessionSectionPrefix+config.SessionName]
+	if !ok {
+		return nil, fmt.Errorf("sso-session %q of profile %q not found", config.SessionName, profile)
+	}
+
+	for key, value := range map[string]*string{
+		"sso_region":    &config.Region,
+		"sso_start_url": &config.StartURL,
+	} {
+		if *value != "" && *value != session[key] {
+			return nil, fmt.Errorf("%s of profile %q must match %s of sso-session %q", key, profile, key, config.SessionName)
+		}
+		*value = session[key]
+	}
+
+	for _, scope := range strings.Split(session["sso_registration_scopes"], ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			config.RegistrationScopes = append(config.RegistrationScopes, scope)
+		}
+	}
+
+	return config, nil
+}
+
+// tokenCachePath returns the path of the cached AWS SSO token of the
+// configuration, which is keyed by the sso-session name, or by the start URL
+// for profiles with legacy inline SSO settings. The cache is written by the
+// AWS CLI and read by the AWS SDKs in its plaintext format, so it can't be
+// encrypted here; this package doesn't write SSO or assume role credentials to
+// disk itself.
+func (c *ssoConfig) tokenCachePath() (string, error) {
+	key := c.StartURL
+	if c.SessionName != "" {
+		key = c.SessionName
+	}
+	return ssocreds.StandardCachedTokenFilepath(key)
+}
+
+// cachedTokenValid returns whether the cached AWS SSO token of the
+// configuration exists and has not expired at the time of the Clock.
+func (c *ssoConfig) cachedTokenValid(clock Clock) bool {
+	path, err := c.tokenCachePath()
+	if err != nil {
+		return false
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var token struct {
+		AccessToken string    `json:"accessToken"`
+		ExpiresAt   time.Time `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(contents, &token); err != nil {
+		return false
+	}
+
+	return token.AccessToken != "" && resolveClock(clock).Now().Before(token.ExpiresAt)
+}
@@ -0,0 +1,117 @@
+package awsbase
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSharedConfigSectionsSSOConfig(t *testing.T) {
+	sections := sharedConfigSections{
+		"profile static": {
+			"region": "us-east-1",
+		},
+		"profile legacy": {
+			"sso_account_id": "123456789012",
+			"sso_region":     "us-east-1",
+			"sso_role_name":  "Example",
+			"sso_start_url":  "https://example.awsapps.com/start",
+		},
+		"profile session": {
+			"sso_account_id": "123456789012",
+			"sso_role_name":  "Example",
+			"sso_session":    "example",
+		},
+		"profile mismatch": {
+			"sso_region":  "eu-west-1",
+			"sso_session": "example",
+		},
+		"profile dangling": {
+			"sso_session": "missing",
+		},
+		"sso-session example": {
+			"sso_region":              "us-east-1",
+			"sso_registration_scopes": "sso:account:access, codewhisperer:completions",
+			"sso_start_url":           "https://example.awsapps.com/start",
+		},
+	}
+
+	var testCases = []struct {
+		Profile     string
+		Expected    *ssoConfig
+		ExpectedErr string
+	}{
+		{
+			Profile: "static",
+		},
+		{
+			Profile: "legacy",
+			Expected: &ssoConfig{
+				AccountID: "123456789012",
+				Region:    "us-east-1",
+				RoleName:  "Example",
+				StartURL:  "https://example.awsapps.com/start",
+			},
+		},
+		{
+			Profile: "session",
+			Expected: &ssoConfig{
+				AccountID:          "123456789012",
+				Region:             "us-east-1",
+				RegistrationScopes: []string{"sso:account:access", "codewhisperer:completions"},
+				RoleName:           "Example",
+				SessionName:        "example",
+				StartURL:           "https://example.awsapps.com/start",
+			},
+		},
+		{
+			Profile:     "mismatch",
+			ExpectedErr: `sso_region of profile "mismatch" must match sso_region of sso-session "example"`,
+		},
+		{
+			Profile:     "dangling",
+			ExpectedErr: `sso-session "missing" of profile "dangling" not found`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Profile, func(t *testing.T) {
+			config, err := sections.ssoConfig(testCase.Profile)
+			if testCase.ExpectedErr != "" {
+				if err == nil || err.Error() != testCase.ExpectedErr {
+					t.Fatalf("Expected error %q, received: %v", testCase.ExpectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			if testCase.Expected == nil {
+				if config != nil {
+					t.Fatalf("Expected no SSO configuration, got %+v", config)
+				}
+				return
+			}
+			if config == nil {
+				t.Fatal("Expected SSO configuration, got none")
+			}
+			if !reflect.DeepEqual(config, testCase.Expected) {
+				t.Errorf("Expected %+v, got %+v", testCase.Expected, config)
+			}
+		})
+	}
+}
+
+func TestSSOTokenError_ssoSession(t *testing.T) {
+	err := &SSOTokenError{
+		Profile:    "example",
+		SSOSession: "corporate",
+		Err:        errors.New("token expired"),
+	}
+
+	if expected := "aws sso login --sso-session corporate"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error to contain %q, got: %s", expected, err)
+	}
+}
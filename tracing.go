@@ -0,0 +1,85 @@
+package awsbase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hashicorp/aws-sdk-go-base"
+
+// startSpan starts a span for a credential resolution phase, as a child of
+// the span of the context, if any, and returns it along with a context
+// carrying it, for the spans of the requests of the phase. If tracing is not
+// configured, the context is returned as is, with a no-op span.
+func startSpan(ctx context.Context, c *Config, name string) (context.Context, trace.Span) {
+	if c.TracerProvider == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+
+	return c.TracerProvider.Tracer(tracerName).Start(ctx, name)
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// requestSpanKey is the context key of the client span of a request, so that
+// only spans started by the tracing handlers are ended by them, rather than
+// the span of the context of the request.
+type requestSpanKey struct{}
+
+// addTracingHandlers instruments each request made with the handlers with a
+// client span, if tracing is configured. The span is a child of the span of
+// the context of the request, if any.
+func addTracingHandlers(c *Config, handlers *request.Handlers) {
+	if c.TracerProvider == nil {
+		return
+	}
+
+	tracer := c.TracerProvider.Tracer(tracerName)
+
+	// The span is started by a Build handler, as some clients, e.g. the EC2
+	// metadata client, replace the Validate handlers.
+	handlers.Build.PushFrontNamed(request.NamedHandler{
+		Name: "awsbase.StartTracingSpan",
+		Fn: func(r *request.Request) {
+			ctx, span := tracer.Start(r.Context(), fmt.Sprintf("%s.%s", r.ClientInfo.ServiceName, r.Operation.Name),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", r.ClientInfo.ServiceName),
+					attribute.String("rpc.method", r.Operation.Name),
+				),
+			)
+			r.SetContext(context.WithValue(ctx, requestSpanKey{}, span))
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "awsbase.EndTracingSpan",
+		Fn: func(r *request.Request) {
+			span, ok := r.Context().Value(requestSpanKey{}).(trace.Span)
+			if !ok {
+				return
+			}
+			span.SetAttributes(
+				attribute.String("aws.request_id", r.RequestID),
+				attribute.Int("aws.retry_count", r.RetryCount),
+			)
+			if r.HTTPResponse != nil {
+				span.SetAttributes(attribute.Int("http.status_code", r.HTTPResponse.StatusCode))
+			}
+			endSpan(span, r.Error)
+		},
+	})
+}
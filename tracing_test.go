@@ -0,0 +1,139 @@
+package awsbase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/aws-sdk-go-base/awsmocks"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanParents returns the names of the ended spans recorded by the recorder,
+// mapped to the name of their parent span, or to the empty string for spans
+// whose parent wasn't recorded.
+func spanParents(recorder *tracetest.SpanRecorder) map[string]string {
+	names := make(map[trace.SpanID]string)
+	for _, span := range recorder.Ended() {
+		names[span.SpanContext().SpanID()] = span.Name()
+	}
+
+	parents := make(map[string]string)
+	for _, span := range recorder.Ended() {
+		parents[span.Name()] = names[span.Parent().SpanID()]
+	}
+	return parents
+}
+
+func TestGetSessionWithContext_tracing(t *testing.T) {
+	resetEnv := unsetEnv(t)
+	defer resetEnv()
+
+	servers := awsmocks.NewServers(nil, []*awsmocks.MockEndpoint{awsmocks.MockStsGetCallerIdentityValidEndpoint}, nil)
+	defer servers.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	_, err := GetSessionWithContext(ctx, &Config{
+		AccessKey:            "StaticAccessKey",
+		Region:               "us-east-1",
+		SecretKey:            "StaticSecretKey",
+		SkipMetadataApiCheck: true,
+		StsEndpoint:          servers.StsEndpoint(),
+		TracerProvider:       tracerProvider,
+	})
+	parent.End()
+	if err != nil {
+		t.Fatalf("Expected no error, received error: %s", err)
+	}
+
+	parents := spanParents(recorder)
+	for name, expectedParent := range map[string]string{
+		"GetSession":            "parent",
+		"GetCredentials":        "GetSession",
+		"sts.GetCallerIdentity": "GetSession",
+	} {
+		if parent, ok := parents[name]; !ok {
+			t.Errorf("Expected %s span, got spans %v", name, parents)
+		} else if parent != expectedParent {
+			t.Errorf("Expected %s span to be a child of %q, got %q", name, expectedParent, parent)
+		}
+	}
+}
+
+func TestGetCredentialsWithContext_tracing(t *testing.T) {
+	var testCases = []struct {
+		Description   string
+		Config        *Config
+		StsEndpoints  []*awsmocks.MockEndpoint
+		EC2Metadata   bool
+		ExpectedSpans []string
+	}{
+		{
+			Description: "assume role",
+			Config: &Config{
+				AccessKey: "StaticAccessKey",
+				AssumeRole: &AssumeRole{
+					RoleARN:     awsmocks.MockStsAssumeRoleArn,
+					SessionName: awsmocks.MockStsAssumeRoleSessionName,
+				},
+				SecretKey:            "StaticSecretKey",
+				SkipMetadataApiCheck: true,
+			},
+			StsEndpoints:  []*awsmocks.MockEndpoint{awsmocks.MockStsAssumeRoleValidEndpoint},
+			ExpectedSpans: []string{"sts.AssumeRole"},
+		},
+		{
+			Description:   "EC2 metadata API",
+			Config:        &Config{},
+			EC2Metadata:   true,
+			ExpectedSpans: []string{"ec2metadata.GetMetadata"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Description, func(t *testing.T) {
+			resetEnv := unsetEnv(t)
+			defer resetEnv()
+			ResetMetadataApiCheck()
+			defer ResetMetadataApiCheck()
+
+			servers := awsmocks.NewServers(nil, testCase.StsEndpoints, awsmocks.MockEc2MetadataSecurityCredentialsEndpoints)
+			defer servers.Close()
+			if testCase.EC2Metadata {
+				t.Setenv("AWS_METADATA_URL", servers.EC2MetadataEndpoint())
+			}
+
+			recorder := tracetest.NewSpanRecorder()
+			tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			testCase.Config.Region = "us-east-1"
+			testCase.Config.StsEndpoint = servers.StsEndpoint()
+			testCase.Config.TracerProvider = tracerProvider
+
+			ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+			_, err := GetCredentialsWithContext(ctx, testCase.Config)
+			parent.End()
+			if err != nil {
+				t.Fatalf("Expected no error, received error: %s", err)
+			}
+
+			parents := spanParents(recorder)
+			if parent := parents["GetCredentials"]; parent != "parent" {
+				t.Errorf("Expected GetCredentials span to be a child of %q, got %q", "parent", parent)
+			}
+			for _, name := range testCase.ExpectedSpans {
+				if parent, ok := parents[name]; !ok {
+					t.Errorf("Expected %s span, got spans %v", name, parents)
+				} else if parent != "GetCredentials" {
+					t.Errorf("Expected %s span to be a child of %q, got %q", name, "GetCredentials", parent)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package awsbase
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 )
@@ -30,6 +33,35 @@ func ValidateAccountID(accountID string, allowedAccountIDs, forbiddenAccountIDs
 	return nil
 }
 
+var (
+	// regionPattern matches well-formed region names, e.g. us-east-1.
+	regionPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// availabilityZonePattern matches availability zone names, e.g.
+	// us-east-1a, which are mistaken for region names.
+	availabilityZonePattern = regexp.MustCompile(`^([a-z]{2}(-[a-z]+)+-[0-9]+)[a-z]$`)
+)
+
+// ValidateRegionFormat checks that the given region is well-formed, rejecting
+// values which are obviously not region names, e.g. with whitespace or
+// uppercase letters, endpoint URLs, or availability zones. Unlike
+// ValidateRegion, it accepts regions unknown to the AWS Go SDK, e.g. regions
+// launched after its release.
+func ValidateRegionFormat(region string) error {
+	switch {
+	case strings.Contains(region, "://") || strings.Contains(region, "."):
+		return fmt.Errorf("invalid AWS region %q: expected a region name, e.g. us-east-1, not an endpoint", region)
+	case strings.IndexFunc(region, unicode.IsSpace) != -1:
+		return fmt.Errorf("invalid AWS region %q: contains whitespace", region)
+	case strings.ToLower(region) != region:
+		return fmt.Errorf("invalid AWS region %q: must be lowercase, e.g. %q", region, strings.ToLower(region))
+	case availabilityZonePattern.MatchString(region):
+		return fmt.Errorf("invalid AWS region %q: expected a region name, not an availability zone, e.g. %q", region, availabilityZonePattern.FindStringSubmatch(region)[1])
+	case !regionPattern.MatchString(region):
+		return fmt.Errorf("invalid AWS region %q: expected lowercase letters and digits separated by hyphens, e.g. us-east-1", region)
+	}
+	return nil
+}
+
 // ValidateRegion checks if the given region is a valid AWS region.
 func ValidateRegion(region string) error {
 	for _, partition := range endpoints.DefaultPartitions() {
@@ -42,3 +74,80 @@ func ValidateRegion(region string) error {
 
 	return fmt.Errorf("Invalid AWS Region: %s", region)
 }
+
+const (
+	// secretKeyLength is the length of AWS secret access keys.
+	secretKeyLength = 40
+	// minSessionTokenLength is less than the length of any session token
+	// issued by AWS STS, which are hundreds of characters long.
+	minSessionTokenLength = 100
+)
+
+// accessKeyIDPattern matches AWS access key IDs: long-term keys of IAM users
+// start with AKIA, temporary keys issued by AWS STS with ASIA.
+var accessKeyIDPattern = regexp.MustCompile(`^A(KIA|SIA)[A-Z0-9]{16}$`)
+
+// ValidateStaticCredentials checks the format of static credentials, e.g.
+// from Config.AccessKey, SecretKey, and Token, so that credentials mangled
+// when copied, such as truncated keys or keys with stray whitespace, are
+// reported before requests signed with them fail. Empty values are not
+// checked.
+func ValidateStaticCredentials(accessKey, secretKey, token string) error {
+	for _, credential := range []struct {
+		name, value string
+	}{
+		{"access key", accessKey},
+		{"secret key", secretKey},
+		{"session token", token},
+	} {
+		if strings.IndexFunc(credential.value, unicode.IsSpace) != -1 {
+			return fmt.Errorf("invalid AWS %s: contains whitespace", credential.name)
+		}
+	}
+
+	if accessKey != "" && !accessKeyIDPattern.MatchString(accessKey) {
+		return fmt.Errorf("invalid AWS access key: expected 20 uppercase letters and digits starting with AKIA or ASIA, got %d characters", len(accessKey))
+	}
+	if secretKey != "" && len(secretKey) != secretKeyLength {
+		return fmt.Errorf("invalid AWS secret key: expected %d characters, got %d", secretKeyLength, len(secretKey))
+	}
+	if strings.HasPrefix(accessKey, "ASIA") && token == "" {
+		return fmt.Errorf("invalid AWS credentials: temporary access key %s requires a session token", accessKey)
+	}
+	if token != "" && len(token) < minSessionTokenLength {
+		return fmt.Errorf("invalid AWS session token: expected at least %d characters, got %d", minSessionTokenLength, len(token))
+	}
+
+	return nil
+}
+
+// CheckStaticCredentials checks the format of the static credentials of the
+// Config with ValidateStaticCredentials, as GetCredentials does. Malformed
+// credentials are logged as a warning, or returned as an error with
+// StrictCredentialsFormat set. Credentials are not checked if custom
+// endpoints are configured, e.g. for S3-compatible services or local
+// emulators, whose credentials needn't be in the format of AWS credentials.
+func (c *Config) CheckStaticCredentials() error {
+	if customEndpointsConfigured(c) {
+		return nil
+	}
+
+	err := ValidateStaticCredentials(c.AccessKey, c.SecretKey, c.Token)
+	if err == nil || c.StrictCredentialsFormat {
+		return err
+	}
+	logger.Printf("[WARN] %s", err)
+	return nil
+}
+
+// customEndpointsConfigured returns whether requests of the Config may be sent
+// to endpoints other than those of AWS.
+func customEndpointsConfigured(c *Config) bool {
+	if c.DynamoDBEndpoint != "" || c.IamEndpoint != "" || c.S3Endpoint != "" || c.StsEndpoint != "" || c.EndpointResolver != nil {
+		return true
+	}
+	if c.AssumeRole != nil && c.AssumeRole.StsEndpoint != "" {
+		return true
+	}
+	return c.RolesAnywhere != nil && c.RolesAnywhere.Endpoint != ""
+}
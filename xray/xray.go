@@ -0,0 +1,24 @@
+// Package xray instruments sessions built by awsbase.GetSession with AWS
+// X-Ray, so that the AWS API calls made with them appear in X-Ray service
+// maps. It is a separate package so that only applications using X-Ray import
+// the X-Ray SDK.
+package xray
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsxray "github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// OnSession instruments the session with AWS X-Ray, as the OnSession hook of
+// the Config:
+//
+//	c.OnSession = xray.OnSession
+//	sess, err := awsbase.GetSessionWithContext(ctx, c)
+//
+// Calls made with a context containing an X-Ray segment, including the
+// credentials validation of GetSessionWithContext, are recorded as its
+// subsegments. Calls made outside of a segment are handled according to the
+// context missing strategy of the X-Ray SDK, which logs an error by default.
+func OnSession(sess *session.Session) *session.Session {
+	return awsxray.AWSSession(sess)
+}